@@ -1,6 +1,9 @@
 package config
 
 import (
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -8,3 +11,445 @@ const (
 	JWTSecret    = "your-secret-key" // In production, use environment variables
 	JWTExpiresIn = time.Hour * 24    // 24 hours
 )
+
+// Stream tokens only open the user's event stream. EventSource cannot send
+// headers, so they travel in the URL and are kept short-lived.
+const (
+	StreamTokenAudience  = "events"
+	StreamTokenExpiresIn = time.Minute
+)
+
+// Config holds the runtime settings read from the environment.
+type Config struct {
+	Site       SiteConfig
+	Theme      ThemeConfig
+	Feeds      FeedConfig
+	Sitemap    SitemapConfig
+	Moderation ModerationConfig
+	Spam       SpamConfig
+	// Reactions is the set of reactions users may leave on posts and comments.
+	Reactions []string
+	Webhooks  WebhookConfig
+	Events    EventsConfig
+	Jobs      JobConfig
+	Storage   StorageConfig
+	Images    ImageConfig
+	UploadGC  UploadGCConfig
+	Uploads   UploadConfig
+	// PrivateMedia is served from signed, expiring URLs.
+	PrivateMedia PrivateMediaConfig
+}
+
+// SiteConfig describes the public blog.
+type SiteConfig struct {
+	// BaseURL is the absolute URL the blog is served from, without a
+	// trailing slash. It is used wherever absolute links are required.
+	BaseURL     string
+	Title       string
+	Description string
+	Language    string
+	// Twitter is the site's Twitter handle, e.g. @scribana, used in cards.
+	Twitter string
+}
+
+// URL makes a site path absolute. Absolute URLs are returned unchanged.
+func (s SiteConfig) URL(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return s.BaseURL + path
+}
+
+// ThemeConfig controls the server-rendered HTML site.
+type ThemeConfig struct {
+	Enabled bool
+	// Dir is a theme directory whose templates/ and static/ files override
+	// the built-in theme's. Empty uses the built-in theme as is.
+	Dir string
+	// Reload re-reads templates on every request, for theme development.
+	Reload bool
+	// PageSize is how many posts list pages show.
+	PageSize int
+}
+
+// FeedConfig controls the syndication feeds.
+type FeedConfig struct {
+	// Items is how many posts each feed lists.
+	Items int
+	// FullContent includes whole posts in feeds rather than excerpts.
+	FullContent bool
+	Podcast     PodcastConfig
+}
+
+// PodcastConfig describes the podcast published from the posts with audio
+// in one category.
+type PodcastConfig struct {
+	// Category is the slug of the podcast's category; empty disables the
+	// podcast feed.
+	Category string
+	// Title and Description default to those of the category's feed.
+	Title       string
+	Description string
+	Author      string
+	OwnerName   string
+	OwnerEmail  string
+	// Image is the show's artwork, a square image of 1400 to 3000 pixels.
+	Image string
+	// ITunesCategory is an Apple Podcasts category, with an optional
+	// subcategory after " > ", e.g. "Technology > Tech News".
+	ITunesCategory string
+	Explicit       bool
+	// Type is episodic, for episodes listened to in any order, or serial.
+	Type string
+}
+
+// SitemapConfig controls the sitemap and robots.txt.
+type SitemapConfig struct {
+	// CacheTTL bounds how long a generated sitemap is served; content
+	// changes invalidate it sooner.
+	CacheTTL       time.Duration
+	RobotsAllow    []string
+	RobotsDisallow []string
+}
+
+// ModerationConfig controls how new comments are published.
+type ModerationConfig struct {
+	// RequireApproval holds comments as pending until a moderator approves them.
+	RequireApproval bool
+	// TrustedAfter auto-approves users with at least this many approved comments (0 disables).
+	TrustedAfter int
+	// AutoApproveRoles are roles whose comments are always approved.
+	AutoApproveRoles []string
+}
+
+// SpamConfig controls the comment spam filter.
+type SpamConfig struct {
+	// Threshold is the score at or above which a comment goes straight to spam.
+	Threshold      float64
+	MaxLinks       int
+	BlockedDomains []string
+	// VelocityLimit is how many comments a user may post within VelocityWindow
+	// before further ones count against them.
+	VelocityLimit  int
+	VelocityWindow time.Duration
+}
+
+// WebhookConfig controls outbound webhook delivery. Retries follow the job
+// queue's backoff.
+type WebhookConfig struct {
+	MaxAttempts int
+	Timeout     time.Duration
+}
+
+// EventsConfig controls the relay that hands domain events to asynchronous
+// subscribers.
+type EventsConfig struct {
+	// PollInterval is how often the outbox is checked for events written by
+	// other replicas or due for a retry.
+	PollInterval time.Duration
+}
+
+// JobConfig controls the background job workers.
+type JobConfig struct {
+	// Workers is how many jobs each replica runs at once.
+	Workers      int
+	PollInterval time.Duration
+	// Timeout bounds a single attempt of a job.
+	Timeout     time.Duration
+	MaxAttempts int
+	// RetryBase is the delay before the first retry; it doubles on every
+	// attempt up to MaxBackoff.
+	RetryBase  time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long succeeded jobs are kept.
+	Retention time.Duration
+}
+
+// StorageConfig selects where uploaded files are kept.
+type StorageConfig struct {
+	// Driver is local or s3.
+	Driver string
+	// PublicURL is the URL prefix stored files are served from. For the local
+	// driver it defaults to /uploads, served by the application; for S3 it
+	// defaults to the bucket's URL and may point at a CDN instead.
+	PublicURL string
+	// LocalDir is the directory the local driver keeps files in.
+	LocalDir string
+	S3       S3Config
+}
+
+// S3Config locates the bucket of the s3 storage driver. Any S3-compatible
+// store works, e.g. MinIO with PathStyle set.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Prefix is prepended to every stored key.
+	Prefix    string
+	PathStyle bool
+}
+
+// ImageConfig controls the variants generated for uploaded images.
+type ImageConfig struct {
+	// Widths are the responsive variants generated, in pixels.
+	Widths        []int
+	ThumbnailSize int
+	// MaxWidth bounds the stored original; wider uploads are scaled down.
+	MaxWidth int
+	// Quality is the JPEG quality, 1 to 100.
+	Quality int
+	// MaxDimension and MaxPixels bound the width or height and the area of
+	// accepted uploads, so small files cannot decode into huge bitmaps.
+	MaxDimension int
+	MaxPixels    int64
+}
+
+// UploadGCConfig controls the collection of uploaded files nothing refers to.
+type UploadGCConfig struct {
+	// Schedule is the cron schedule of the collection; empty disables it.
+	Schedule string
+	// GracePeriod spares files uploaded recently, which the post they were
+	// uploaded for may not reference yet.
+	GracePeriod time.Duration
+	// QuarantinePeriod is how long unreferenced files are held aside,
+	// restorable, before they are deleted.
+	QuarantinePeriod time.Duration
+}
+
+// UploadConfig controls resumable uploads.
+type UploadConfig struct {
+	// MaxSize is the largest file each role may upload, in bytes. Roles
+	// missing from it may not upload at all.
+	MaxSize map[string]int64
+	// MaxImageSize bounds images regardless of role, since they are
+	// processed in memory.
+	MaxImageSize int64
+	// Quota is the storage each user of a role may fill, in bytes. Roles
+	// missing from it, or with 0, are not limited.
+	Quota map[string]int64
+	// Expiry is how long an unfinished upload is kept after its last chunk.
+	Expiry time.Duration
+}
+
+// PrivateMediaConfig controls the signed URLs private media is served from.
+// Private media is refused when the storage serves it publicly itself, as
+// an s3 bucket readable by anyone would; this is checked at startup.
+type PrivateMediaConfig struct {
+	// SigningKey signs the URLs. Every replica needs the same key, and
+	// private media is refused without one.
+	SigningKey string
+	// URLExpiry is how long a signed URL works.
+	URLExpiry time.Duration
+	// FeedURLExpiry is how long signed URLs in feeds work, as feed readers
+	// and podcast apps fetch media long after reading the feed.
+	FeedURLExpiry time.Duration
+}
+
+// Load reads the configuration from environment variables, falling back to defaults.
+func Load() *Config {
+	return &Config{
+		Site: SiteConfig{
+			BaseURL:     strings.TrimRight(getEnv("SITE_URL", "http://localhost:8080"), "/"),
+			Title:       getEnv("SITE_TITLE", "Scribana"),
+			Description: getEnv("SITE_DESCRIPTION", "A blog powered by Scribana"),
+			Language:    getEnv("SITE_LANGUAGE", "en"),
+			Twitter:     getEnv("SITE_TWITTER", ""),
+		},
+		Theme: ThemeConfig{
+			Enabled:  getEnvBool("THEME_ENABLED", true),
+			Dir:      getEnv("THEME_DIR", ""),
+			Reload:   getEnvBool("THEME_RELOAD", false),
+			PageSize: getEnvInt("THEME_PAGE_SIZE", 10),
+		},
+		Feeds: FeedConfig{
+			Items:       getEnvInt("FEED_ITEMS", 20),
+			FullContent: getEnvBool("FEED_FULL_CONTENT", true),
+			Podcast: PodcastConfig{
+				Category:       getEnv("PODCAST_CATEGORY", ""),
+				Title:          getEnv("PODCAST_TITLE", ""),
+				Description:    getEnv("PODCAST_DESCRIPTION", ""),
+				Author:         getEnv("PODCAST_AUTHOR", ""),
+				OwnerName:      getEnv("PODCAST_OWNER_NAME", ""),
+				OwnerEmail:     getEnv("PODCAST_OWNER_EMAIL", ""),
+				Image:          getEnv("PODCAST_IMAGE", ""),
+				ITunesCategory: getEnv("PODCAST_ITUNES_CATEGORY", "Technology"),
+				Explicit:       getEnvBool("PODCAST_EXPLICIT", false),
+				Type:           getEnv("PODCAST_TYPE", "episodic"),
+			},
+		},
+		Sitemap: SitemapConfig{
+			CacheTTL:       getEnvDuration("SITEMAP_CACHE_TTL", time.Hour),
+			RobotsAllow:    getEnvList("ROBOTS_ALLOW", nil),
+			RobotsDisallow: getEnvList("ROBOTS_DISALLOW", []string{"/api/", "/swagger/"}),
+		},
+		Moderation: ModerationConfig{
+			RequireApproval:  getEnvBool("COMMENT_REQUIRE_APPROVAL", true),
+			TrustedAfter:     getEnvInt("COMMENT_TRUSTED_AFTER", 3),
+			AutoApproveRoles: getEnvList("COMMENT_AUTO_APPROVE_ROLES", []string{"admin", "moderator", "author"}),
+		},
+		Spam: SpamConfig{
+			Threshold:      getEnvFloat("SPAM_THRESHOLD", 0.9),
+			MaxLinks:       getEnvInt("SPAM_MAX_LINKS", 2),
+			BlockedDomains: getEnvList("SPAM_BLOCKED_DOMAINS", nil),
+			VelocityLimit:  getEnvInt("SPAM_VELOCITY_LIMIT", 5),
+			VelocityWindow: getEnvDuration("SPAM_VELOCITY_WINDOW", 10*time.Minute),
+		},
+		Reactions: getEnvList("REACTIONS", []string{"like", "love", "laugh", "celebrate", "insightful", "sad"}),
+		Webhooks: WebhookConfig{
+			MaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			Timeout:     getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		},
+		Events: EventsConfig{
+			PollInterval: getEnvDuration("EVENTS_POLL_INTERVAL", 2*time.Second),
+		},
+		Jobs: JobConfig{
+			Workers:      getEnvInt("JOB_WORKERS", 4),
+			PollInterval: getEnvDuration("JOB_POLL_INTERVAL", 2*time.Second),
+			Timeout:      getEnvDuration("JOB_TIMEOUT", 5*time.Minute),
+			MaxAttempts:  getEnvInt("JOB_MAX_ATTEMPTS", 10),
+			RetryBase:    getEnvDuration("JOB_RETRY_BASE", 30*time.Second),
+			MaxBackoff:   getEnvDuration("JOB_MAX_BACKOFF", 6*time.Hour),
+			Retention:    getEnvDuration("JOB_RETENTION", 7*24*time.Hour),
+		},
+		Storage: StorageConfig{
+			Driver:    getEnv("STORAGE_DRIVER", "local"),
+			PublicURL: getEnv("STORAGE_PUBLIC_URL", ""),
+			LocalDir:  getEnv("UPLOAD_DIR", "uploads"),
+			S3: S3Config{
+				Endpoint:        getEnv("S3_ENDPOINT", ""),
+				Region:          getEnv("S3_REGION", "us-east-1"),
+				Bucket:          getEnv("S3_BUCKET", ""),
+				AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+				Prefix:          getEnv("S3_PREFIX", ""),
+				PathStyle:       getEnvBool("S3_PATH_STYLE", false),
+			},
+		},
+		Images: ImageConfig{
+			Widths:        getEnvInts("IMAGE_WIDTHS", []int{320, 640, 1280}),
+			ThumbnailSize: getEnvInt("IMAGE_THUMBNAIL_SIZE", 200),
+			MaxWidth:      getEnvInt("IMAGE_MAX_WIDTH", 2048),
+			Quality:       getEnvInt("IMAGE_QUALITY", 82),
+			MaxDimension:  getEnvInt("IMAGE_MAX_DIMENSION", 10000),
+			MaxPixels:     int64(getEnvInt("IMAGE_MAX_PIXELS", 40000000)),
+		},
+		UploadGC: UploadGCConfig{
+			Schedule:         getEnv("UPLOAD_GC_SCHEDULE", "@daily"),
+			GracePeriod:      getEnvDuration("UPLOAD_GC_GRACE_PERIOD", 24*time.Hour),
+			QuarantinePeriod: getEnvDuration("UPLOAD_GC_QUARANTINE_PERIOD", 7*24*time.Hour),
+		},
+		Uploads: UploadConfig{
+			MaxSize: getEnvSizes("UPLOAD_MAX_SIZE", map[string]int64{
+				"author":    100 << 20,
+				"moderator": 100 << 20,
+				"admin":     1 << 30,
+			}),
+			MaxImageSize: int64(getEnvInt("UPLOAD_MAX_IMAGE_SIZE", 20<<20)),
+			Expiry:       getEnvDuration("UPLOAD_EXPIRY", 24*time.Hour),
+			Quota: getEnvSizes("UPLOAD_QUOTA", map[string]int64{
+				"author":    1 << 30,
+				"moderator": 1 << 30,
+			}),
+		},
+		PrivateMedia: PrivateMediaConfig{
+			SigningKey:    getEnv("MEDIA_SIGNING_KEY", ""),
+			URLExpiry:     getEnvDuration("MEDIA_URL_EXPIRY", time.Hour),
+			FeedURLExpiry: getEnvDuration("MEDIA_FEED_URL_EXPIRY", 7*24*time.Hour),
+		},
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvList(key string, fallback []string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvInts(key string, fallback []int) []int {
+	items := getEnvList(key, nil)
+	if items == nil {
+		return fallback
+	}
+
+	values := make([]int, 0, len(items))
+	for _, item := range items {
+		value, err := strconv.Atoi(item)
+		if err != nil {
+			return fallback
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// getEnvSizes reads a list of name:bytes pairs, e.g. "author:1048576".
+func getEnvSizes(key string, fallback map[string]int64) map[string]int64 {
+	items := getEnvList(key, nil)
+	if items == nil {
+		return fallback
+	}
+
+	sizes := make(map[string]int64, len(items))
+	for _, item := range items {
+		name, value, _ := strings.Cut(item, ":")
+		size, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fallback
+		}
+		sizes[strings.TrimSpace(name)] = size
+	}
+	return sizes
+}
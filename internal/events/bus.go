@@ -0,0 +1,254 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// relayBatch is how many outbox rows the relay claims at a time.
+	relayBatch = 50
+	// maxAttempts is how often a subscriber is retried before the event is
+	// marked as failed for it.
+	maxAttempts = 10
+	// retention is how long dispatched rows are kept for inspection.
+	retention = 7 * 24 * time.Hour
+)
+
+type syncHandler func(tx *gorm.DB, event Event) error
+
+type asyncHandler struct {
+	name   string
+	decode func(payload []byte) (Event, error)
+	handle func(ctx context.Context, event Event) error
+}
+
+// Bus delivers domain events to subscribers.
+//
+// Synchronous subscribers run inside the publishing transaction and can
+// abort it by returning an error. Asynchronous subscribers run after commit:
+// Publish writes one outbox row per subscriber in the same transaction, and
+// the relay started by Run hands them over with retries, so each subscriber
+// sees every committed event at least once.
+type Bus struct {
+	db    *gorm.DB
+	mu    sync.RWMutex
+	sync  map[string][]syncHandler
+	async map[string][]asyncHandler
+	wake  chan struct{}
+}
+
+func NewBus(db *gorm.DB) *Bus {
+	return &Bus{
+		db:    db,
+		sync:  make(map[string][]syncHandler),
+		async: make(map[string][]asyncHandler),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Subscribe registers a handler run inside the publishing transaction.
+func Subscribe[E Event](b *Bus, fn func(tx *gorm.DB, event E) error) {
+	var zero E
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sync[zero.EventName()] = append(b.sync[zero.EventName()], func(tx *gorm.DB, event Event) error {
+		return fn(tx, event.(E))
+	})
+}
+
+// SubscribeAsync registers a handler run after the publishing transaction
+// commits. name identifies the subscriber in the outbox and must be stable
+// across deploys.
+func SubscribeAsync[E Event](b *Bus, name string, fn func(ctx context.Context, event E) error) {
+	var zero E
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.async[zero.EventName()] = append(b.async[zero.EventName()], asyncHandler{
+		name: name,
+		decode: func(payload []byte) (Event, error) {
+			var event E
+			err := json.Unmarshal(payload, &event)
+			return event, err
+		},
+		handle: func(ctx context.Context, event Event) error {
+			return fn(ctx, event.(E))
+		},
+	})
+}
+
+// Transaction runs fn in a database transaction and wakes the relay once it
+// has committed, so events published inside fn are delivered promptly.
+func (b *Bus) Transaction(fn func(tx *gorm.DB) error) error {
+	if err := b.db.Transaction(fn); err != nil {
+		return err
+	}
+	b.kick()
+	return nil
+}
+
+// Publish runs the synchronous subscribers of each event and records it for
+// the asynchronous ones. tx must be the transaction making the change the
+// events describe.
+func (b *Bus) Publish(tx *gorm.DB, events ...Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	now := time.Now()
+	var rows []models.OutboxEvent
+	for _, event := range events {
+		for _, handler := range b.sync[event.EventName()] {
+			if err := handler(tx, event); err != nil {
+				return fmt.Errorf("%s subscriber: %w", event.EventName(), err)
+			}
+		}
+
+		subscribers := b.async[event.EventName()]
+		if len(subscribers) == 0 {
+			continue
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		for _, subscriber := range subscribers {
+			rows = append(rows, models.OutboxEvent{
+				Name:          event.EventName(),
+				Subscriber:    subscriber.name,
+				Payload:       string(payload),
+				NextAttemptAt: now,
+			})
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+func (b *Bus) kick() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays outbox events to asynchronous subscribers until ctx is
+// cancelled. Several replicas may relay at once; rows are claimed with
+// FOR UPDATE SKIP LOCKED.
+func (b *Bus) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+
+	for {
+		for {
+			processed, err := b.relay(ctx)
+			if err != nil {
+				log.Printf("events: relay failed: %v", err)
+			}
+			if processed < relayBatch {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			lastCleanup = time.Now()
+			err := b.db.Where("dispatched_at < ?", time.Now().Add(-retention)).
+				Delete(&models.OutboxEvent{}).Error
+			if err != nil {
+				log.Printf("events: outbox cleanup failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-b.wake:
+		}
+	}
+}
+
+func (b *Bus) relay(ctx context.Context) (int, error) {
+	var processed int
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []models.OutboxEvent
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("id").Limit(relayBatch).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range rows {
+			b.deliver(ctx, &rows[i])
+			if err := tx.Save(&rows[i]).Error; err != nil {
+				return err
+			}
+			processed++
+		}
+		return nil
+	})
+	return processed, err
+}
+
+// deliver hands one outbox row to its subscriber and records the outcome.
+func (b *Bus) deliver(ctx context.Context, row *models.OutboxEvent) {
+	now := time.Now()
+	row.Attempts++
+
+	err := b.handle(ctx, row)
+	if err == nil {
+		row.DispatchedAt = &now
+		row.LastError = ""
+		return
+	}
+
+	row.LastError = err.Error()
+	if row.Attempts >= maxAttempts {
+		row.FailedAt = &now
+		log.Printf("events: giving up on %s for %s after %d attempts: %v", row.Name, row.Subscriber, row.Attempts, err)
+		return
+	}
+	row.NextAttemptAt = now.Add(time.Second << row.Attempts)
+}
+
+func (b *Bus) handle(ctx context.Context, row *models.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panicked: %v", r)
+		}
+	}()
+
+	b.mu.RLock()
+	var subscriber *asyncHandler
+	for _, s := range b.async[row.Name] {
+		if s.name == row.Subscriber {
+			subscriber = &s
+			break
+		}
+	}
+	b.mu.RUnlock()
+
+	// The subscriber was removed since the event was recorded.
+	if subscriber == nil {
+		return nil
+	}
+
+	event, err := subscriber.decode([]byte(row.Payload))
+	if err != nil {
+		return err
+	}
+	return subscriber.handle(ctx, event)
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"github.com/Realwale/scribana/internal/models"
+)
+
+// Event is a domain event. Events carry identifiers rather than whole
+// records so subscribers always work from the committed state.
+type Event interface {
+	EventName() string
+}
+
+// UserRegistered is published when a new account is created.
+type UserRegistered struct {
+	UserID uint `json:"user_id"`
+}
+
+func (UserRegistered) EventName() string { return "user.registered" }
+
+// PostCreated is published for every new post, draft or not.
+type PostCreated struct {
+	PostID   uint `json:"post_id"`
+	AuthorID uint `json:"author_id"`
+}
+
+func (PostCreated) EventName() string { return "post.created" }
+
+// PostPublished is published when a post becomes publicly visible. ActorID
+// is 0 when the system published it.
+type PostPublished struct {
+	PostID  uint `json:"post_id"`
+	ActorID uint `json:"actor_id"`
+}
+
+func (PostPublished) EventName() string { return "post.published" }
+
+// PostUpdated is published when a post is edited. WasPublished tells
+// subscribers whether the post was public before the edit, so moving a
+// post back to draft can be told apart from editing a draft.
+type PostUpdated struct {
+	PostID       uint `json:"post_id"`
+	ActorID      uint `json:"actor_id"`
+	WasPublished bool `json:"was_published"`
+}
+
+func (PostUpdated) EventName() string { return "post.updated" }
+
+// PostDeleted is published when a post is removed. It carries the slug
+// because the post can no longer be loaded.
+type PostDeleted struct {
+	PostID       uint   `json:"post_id"`
+	Slug         string `json:"slug"`
+	ActorID      uint   `json:"actor_id"`
+	WasPublished bool   `json:"was_published"`
+}
+
+func (PostDeleted) EventName() string { return "post.deleted" }
+
+// CommentCreated is published for every new comment, whatever its
+// moderation status.
+type CommentCreated struct {
+	CommentID uint                 `json:"comment_id"`
+	PostID    uint                 `json:"post_id"`
+	UserID    uint                 `json:"user_id"`
+	Status    models.CommentStatus `json:"status"`
+}
+
+func (CommentCreated) EventName() string { return "comment.created" }
+
+// CommentPublished is published when a comment becomes publicly visible,
+// either on creation or when a moderator approves it.
+type CommentPublished struct {
+	CommentID uint `json:"comment_id"`
+	PostID    uint `json:"post_id"`
+}
+
+func (CommentPublished) EventName() string { return "comment.published" }
+
+// CommentUpdated is published when a visible comment is edited.
+type CommentUpdated struct {
+	CommentID uint `json:"comment_id"`
+	PostID    uint `json:"post_id"`
+}
+
+func (CommentUpdated) EventName() string { return "comment.updated" }
+
+// CommentRemoved is published when a visible comment is deleted or taken
+// out of public view by moderation.
+type CommentRemoved struct {
+	CommentID uint `json:"comment_id"`
+	PostID    uint `json:"post_id"`
+}
+
+func (CommentRemoved) EventName() string { return "comment.removed" }
+
+// ReactionToggled is published when a user adds or removes a reaction.
+type ReactionToggled struct {
+	UserID     uint              `json:"user_id"`
+	TargetType models.TargetType `json:"target_type"`
+	TargetID   uint              `json:"target_id"`
+	PostID     uint              `json:"post_id"`
+	Reaction   string            `json:"reaction"`
+	Added      bool              `json:"added"`
+}
+
+func (ReactionToggled) EventName() string { return "reaction.toggled" }
+
+// SpamTrained is published when moderation decisions change the spam
+// classifier's persisted counts.
+type SpamTrained struct{}
+
+func (SpamTrained) EventName() string { return "spam.trained" }
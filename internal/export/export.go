@@ -0,0 +1,471 @@
+// Package export renders the public site into a directory of static files
+// that can be published to any static host.
+//
+// Pages are rendered by requesting them from the site's own HTTP handler, so
+// the export matches what the server would serve. A manifest of content
+// hashes is kept in the output directory; on later runs only files whose
+// content changed are rewritten, and files for pages that no longer exist
+// are removed.
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Realwale/scribana/internal/feed"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// ManifestFile is the name of the manifest kept in the output directory.
+const ManifestFile = ".scribana-export.json"
+
+type Exporter struct {
+	// Handler serves the site; every exported page is requested from it.
+	Handler http.Handler
+	DB      *gorm.DB
+	// Static holds the theme assets, copied under static/.
+	Static fs.FS
+	// Uploads is where uploads referenced by exported pages are copied from.
+	// Uploads served from another host, such as a CDN, are linked to as is.
+	Uploads storage.Storage
+	// PrivateURL is the URL private media is served under, from Uploads
+	// keys starting with PrivatePrefix. The site links to it with signatures
+	// that expire, so exported pages link to it unsigned instead, and the
+	// private media they link to is copied, as the posts are public.
+	PrivateURL    string
+	PrivatePrefix string
+	// PageSize is how many posts the site shows per list page.
+	PageSize int
+	// Podcast exports the podcast feed, served at /podcast.xml.
+	Podcast bool
+}
+
+// Options control a single export.
+type Options struct {
+	// Dir is the output directory.
+	Dir string
+	// Full rewrites every file, not only those that changed.
+	Full bool
+}
+
+// Report summarises an export.
+type Report struct {
+	Written   int `json:"written"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	Uploads   int `json:"uploads"`
+}
+
+type manifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// run is the state of one export.
+type run struct {
+	*Exporter
+	opts    Options
+	old     manifest
+	new     manifest
+	uploads map[string]bool
+	// uploadRef matches links to uploaded files, relative or absolute, when
+	// they are served by the site itself; uploadDir is where they are copied.
+	uploadRef *regexp.Regexp
+	uploadDir string
+	// privateRef matches signed links to private media, capturing the file
+	// name; private lists those to copy to privateDir.
+	privateRef *regexp.Regexp
+	privateDir string
+	private    map[string]bool
+	report     Report
+}
+
+// Export renders the whole site into opts.Dir.
+func (e *Exporter) Export(opts Options) (*Report, error) {
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	r := &run{
+		Exporter: e,
+		opts:     opts,
+		new:      manifest{Files: map[string]string{}},
+		uploads:  map[string]bool{},
+		private:  map[string]bool{},
+	}
+	if prefix := e.Uploads.URL(""); strings.HasPrefix(prefix, "/") {
+		r.uploadRef = regexp.MustCompile(regexp.QuoteMeta(prefix) + `[A-Za-z0-9._\-/]+`)
+		r.uploadDir = strings.TrimPrefix(prefix, "/")
+	}
+	if e.PrivateURL != "" {
+		r.privateRef = regexp.MustCompile(regexp.QuoteMeta(e.PrivateURL+"/") +
+			`([A-Za-z0-9._\-]+)(?:\?expires=[0-9]+&(?:amp;)?signature=[0-9a-f]+)?`)
+		r.privateDir = strings.TrimPrefix(e.PrivateURL, "/") + "/"
+	}
+	if err := r.loadManifest(); err != nil {
+		return nil, err
+	}
+
+	paths, err := e.paths()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		if err := r.page(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.notFound(); err != nil {
+		return nil, err
+	}
+	if err := r.static(); err != nil {
+		return nil, err
+	}
+	if err := r.copyUploads(); err != nil {
+		return nil, err
+	}
+	if err := r.copyPrivate(); err != nil {
+		return nil, err
+	}
+	if err := r.removeStale(); err != nil {
+		return nil, err
+	}
+	if err := r.saveManifest(); err != nil {
+		return nil, err
+	}
+	return &r.report, nil
+}
+
+// paths lists every page of the site: lists with their pagination, posts,
+// feeds, and the sitemap and robots.txt. Sitemap pages beyond the index are
+// discovered while exporting.
+func (e *Exporter) paths() ([]string, error) {
+	var paths []string
+	list := func(base string, query *gorm.DB) error {
+		var count int64
+		if err := query.Model(&models.Post{}).Scopes(models.Public).Count(&count).Error; err != nil {
+			return err
+		}
+		paths = append(paths, base)
+		for n := 2; int64((n-1)*e.PageSize) < count; n++ {
+			paths = append(paths, fmt.Sprintf("%s/page/%d", strings.TrimSuffix(base, "/"), n))
+		}
+		for _, format := range feed.Formats {
+			paths = append(paths, strings.TrimSuffix(base, "/")+"/"+format.Filename)
+		}
+		return nil
+	}
+
+	if err := list("/", e.DB); err != nil {
+		return nil, err
+	}
+
+	var posts []models.Post
+	if err := e.DB.Scopes(models.Public).Select("id", "slug").Order("id").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		paths = append(paths, posts[i].Path())
+	}
+
+	var categories []models.Category
+	err := e.DB.Where("id IN (?)", e.DB.Model(&models.Post{}).Scopes(models.Public).Select("category_id")).
+		Order("slug").Find(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		if err := list("/categories/"+category.Slug, e.DB.Where("posts.category_id = ?", category.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	var tags []models.Tag
+	err = e.DB.Where("id IN (?)", e.DB.Table("post_tags").Select("tag_id").
+		Where("post_id IN (?)", e.DB.Model(&models.Post{}).Scopes(models.Public).Select("id"))).
+		Order("slug").Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		query := e.DB.Where("posts.id IN (?)", e.DB.Table("post_tags").Select("post_id").Where("tag_id = ?", tag.ID))
+		if err := list("/tags/"+tag.Slug, query); err != nil {
+			return nil, err
+		}
+	}
+
+	var authors []models.User
+	err = e.DB.Where("id IN (?)", e.DB.Model(&models.Post{}).Scopes(models.Public).Select("author_id")).
+		Order("username").Find(&authors).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, author := range authors {
+		if err := list("/authors/"+author.Username, e.DB.Where("posts.author_id = ?", author.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.Podcast {
+		paths = append(paths, "/podcast.xml")
+	}
+	return append(paths, "/sitemap.xml", "/robots.txt"), nil
+}
+
+// page renders one path and writes it out.
+func (r *run) page(p string) error {
+	status, body := r.get(p)
+	if status != http.StatusOK {
+		return fmt.Errorf("export %s: status %d", p, status)
+	}
+	if err := r.write(file(p), body); err != nil {
+		return err
+	}
+
+	// A split sitemap links to its pages from the index.
+	if p == "/sitemap.xml" && bytes.Contains(body, []byte("<sitemapindex")) {
+		for n := 1; ; n++ {
+			page := fmt.Sprintf("/sitemaps/%d.xml", n)
+			if status, body := r.get(page); status == http.StatusOK {
+				if err := r.write(file(page), body); err != nil {
+					return err
+				}
+				continue
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// notFound exports the site's 404 page as 404.html, which most static hosts
+// serve for missing paths.
+func (r *run) notFound() error {
+	status, body := r.get("/404.html")
+	if status != http.StatusNotFound {
+		return fmt.Errorf("export 404 page: status %d", status)
+	}
+	return r.write("404.html", body)
+}
+
+func (r *run) get(p string) (int, []byte) {
+	req := httptest.NewRequest(http.MethodGet, p, nil)
+	rec := httptest.NewRecorder()
+	r.Handler.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.Bytes()
+}
+
+func (r *run) static() error {
+	if r.Static == nil {
+		return nil
+	}
+	return fs.WalkDir(r.Static, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := fs.ReadFile(r.Static, name)
+		if err != nil {
+			return err
+		}
+		return r.write(path.Join("static", name), body)
+	})
+}
+
+func (r *run) copyUploads() error {
+	names := make([]string, 0, len(r.uploads))
+	for name := range r.uploads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if path.Clean("/"+name) != "/"+name {
+			continue
+		}
+		body, err := r.readUpload(name)
+		if errors.Is(err, storage.ErrNotFound) {
+			// A dangling link; the page is exported as the server renders it.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.write(r.uploadDir+name, body); err != nil {
+			return err
+		}
+		r.report.Uploads++
+	}
+	return nil
+}
+
+// copyPrivate copies the private media linked from exported pages to where
+// the unsigned links point.
+func (r *run) copyPrivate() error {
+	names := make([]string, 0, len(r.private))
+	for name := range r.private {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		body, err := r.readUpload(r.PrivatePrefix + name)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("copy private media %s: %w", name, err)
+		}
+		if err := r.write(r.privateDir+name, body); err != nil {
+			return err
+		}
+		r.report.Uploads++
+	}
+	return nil
+}
+
+func (r *run) readUpload(key string) ([]byte, error) {
+	f, err := r.Uploads.Open(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// write records a file in the manifest and writes it unless the previous
+// export wrote the same content. Uploads referenced by the file are queued
+// for copying.
+func (r *run) write(name string, body []byte) error {
+	if r.privateRef != nil && !strings.HasPrefix(name, r.privateDir) {
+		body = r.privateRef.ReplaceAllFunc(body, func(ref []byte) []byte {
+			file := string(r.privateRef.FindSubmatch(ref)[1])
+			r.private[file] = true
+			return []byte(r.PrivateURL + "/" + file)
+		})
+	}
+	if r.uploadRef != nil && !strings.HasPrefix(name, r.uploadDir) {
+		for _, ref := range r.uploadRef.FindAll(body, -1) {
+			r.uploads[strings.TrimPrefix(string(ref), "/"+r.uploadDir)] = true
+		}
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	r.new.Files[name] = hash
+
+	target := filepath.Join(r.opts.Dir, filepath.FromSlash(name))
+	if !r.opts.Full && r.old.Files[name] == hash {
+		if _, err := os.Stat(target); err == nil {
+			r.report.Unchanged++
+			return nil
+		}
+	}
+
+	if err := writeFile(target, body); err != nil {
+		return err
+	}
+	r.report.Written++
+	return nil
+}
+
+// removeStale deletes files written by the previous export that this one
+// no longer produces, such as the pages of deleted posts.
+func (r *run) removeStale() error {
+	for name := range r.old.Files {
+		if _, ok := r.new.Files[name]; ok {
+			continue
+		}
+		target := filepath.Join(r.opts.Dir, filepath.FromSlash(name))
+		if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		r.report.Removed++
+		removeEmptyDirs(r.opts.Dir, filepath.Dir(target))
+	}
+	return nil
+}
+
+func (r *run) loadManifest() error {
+	body, err := os.ReadFile(filepath.Join(r.opts.Dir, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &r.old); err != nil {
+		return fmt.Errorf("read %s: %w", ManifestFile, err)
+	}
+	return nil
+}
+
+func (r *run) saveManifest() error {
+	body, err := json.MarshalIndent(r.new, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(r.opts.Dir, ManifestFile), body)
+}
+
+// file maps a site path to the file serving it: pages become directory
+// indexes so their URLs stay the same on a static host.
+func file(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "index.html"
+	}
+	if path.Ext(p) != "" {
+		return p
+	}
+	return p + "/index.html"
+}
+
+// writeFile replaces a file atomically, creating its directory.
+func writeFile(name string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// removeEmptyDirs removes dir and its parents up to root while they are
+// empty.
+func removeEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+const generator = "Scribana"
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang      string      `xml:"xml:lang,attr,omitempty"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Generator string      `xml:"generator"`
+	Links     []atomLink  `xml:"link"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    atomText       `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteAtom renders the feed as Atom 1.0.
+func (f *Feed) WriteAtom(w io.Writer) error {
+	doc := atomFeed{
+		Lang:      f.Language,
+		ID:        f.FeedURL,
+		Title:     f.Title,
+		Subtitle:  f.Description,
+		Updated:   atomTime(f.Updated),
+		Generator: generator,
+		Links: []atomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: f.FeedURL, Rel: "self", Type: atomType},
+		},
+	}
+
+	for _, item := range f.Items {
+		entry := atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Published: atomTime(item.Published),
+			Updated:   atomTime(item.Updated),
+			Author:    atomPerson{Name: item.Author, URI: item.AuthorURL},
+			Links:     []atomLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}},
+			Summary:   atomText{Type: "text", Value: item.Summary},
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: item.Content}
+		}
+		if e := item.Enclosure; e != nil {
+			link := atomLink{Href: e.URL, Rel: "enclosure", Type: e.Type}
+			if e.Length > 0 {
+				link.Length = strconv.FormatInt(e.Length, 10)
+			}
+			entry.Links = append(entry.Links, link)
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return writeXML(w, doc)
+}
+
+// atomTime formats t as RFC 3339. Atom requires an updated date even for an
+// empty feed, so the zero time is rendered as the Unix epoch.
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,183 @@
+// Package feed builds syndication feeds for the blog and renders them as
+// RSS 2.0, Atom 1.0 or JSON Feed 1.1.
+package feed
+
+import (
+	"io"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+)
+
+// summaryLength is the length of excerpts generated from post content.
+const summaryLength = 300
+
+// Feed is a format independent feed.
+type Feed struct {
+	Title       string
+	Description string
+	// Link is the page the feed belongs to; FeedURL is the feed itself.
+	Link     string
+	FeedURL  string
+	Language string
+	Updated  time.Time
+	Items    []Item
+	// Podcast makes the RSS rendering a podcast feed; it is nil for others.
+	Podcast *Podcast
+}
+
+// Podcast describes the show of a podcast feed, as Apple Podcasts and other
+// podcast apps expect.
+type Podcast struct {
+	Author     string
+	OwnerName  string
+	OwnerEmail string
+	// Image is the absolute URL of the show's artwork.
+	Image string
+	// Category is an Apple Podcasts category, with an optional subcategory
+	// after " > ".
+	Category string
+	Explicit bool
+	// Type is episodic or serial.
+	Type string
+}
+
+type Item struct {
+	// ID is the post's permalink, which never changes once published.
+	ID         string
+	Title      string
+	Link       string
+	Summary    string
+	Content    string
+	Author     string
+	AuthorURL  string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+	Enclosure  *Enclosure
+	// Image is the absolute URL of the post's image, used as episode artwork.
+	Image string
+	// Episode is the episode number of podcast items.
+	Episode int
+}
+
+// Enclosure is a file attached to an item. Length is 0 when unknown, and
+// Duration is set for audio only.
+type Enclosure struct {
+	URL      string
+	Type     string
+	Length   int64
+	Duration time.Duration
+}
+
+// Format is one of the supported feed formats.
+type Format struct {
+	// Filename is the last path segment feeds of this format are served at.
+	Filename    string
+	ContentType string
+	Write       func(f *Feed, w io.Writer) error
+}
+
+const (
+	rssType  = "application/rss+xml; charset=utf-8"
+	atomType = "application/atom+xml; charset=utf-8"
+	jsonType = "application/feed+json; charset=utf-8"
+)
+
+var (
+	RSS  = Format{Filename: "feed.xml", ContentType: rssType, Write: (*Feed).WriteRSS}
+	Atom = Format{Filename: "atom.xml", ContentType: atomType, Write: (*Feed).WriteAtom}
+	JSON = Format{Filename: "feed.json", ContentType: jsonType, Write: (*Feed).WriteJSON}
+)
+
+// Formats lists every supported format.
+var Formats = []Format{RSS, Atom, JSON}
+
+// Builder turns posts into feeds.
+type Builder struct {
+	Site config.SiteConfig
+	// FullContent includes whole posts rather than just their summaries.
+	FullContent bool
+}
+
+// Build creates a feed for the page at pagePath listing posts, which should
+// be published and have their author, category, tags, media and audio
+// loaded. Audio attached to a post is its enclosure, or else its image.
+func (b *Builder) Build(title, description, pagePath, feedPath string, posts []models.Post) *Feed {
+	f := &Feed{
+		Title:       title,
+		Description: description,
+		Link:        b.Site.URL(pagePath),
+		FeedURL:     b.Site.URL(feedPath),
+		Language:    b.Site.Language,
+	}
+
+	for i := range posts {
+		item := b.item(&posts[i])
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f
+}
+
+func (b *Builder) item(post *models.Post) Item {
+	link := b.Site.URL(post.Path())
+	item := Item{
+		ID:        link,
+		Title:     post.Title,
+		Link:      link,
+		Summary:   post.Summary(summaryLength),
+		Author:    post.Author.Username,
+		AuthorURL: b.Site.URL("/authors/" + post.Author.Username),
+		Published: post.CreatedAt,
+		Updated:   post.UpdatedAt,
+	}
+	if post.PublishedAt != nil {
+		item.Published = *post.PublishedAt
+	}
+	if item.Updated.Before(item.Published) {
+		item.Updated = item.Published
+	}
+	if b.FullContent {
+		item.Content = post.HTML()
+	}
+
+	if post.Category.Name != "" {
+		item.Categories = append(item.Categories, post.Category.Name)
+	}
+	for _, tag := range post.Tags {
+		item.Categories = append(item.Categories, tag.Name)
+	}
+
+	if post.ImageURL != "" {
+		item.Image = b.Site.URL(post.ImageURL)
+	}
+	if post.Episode != nil {
+		item.Episode = *post.Episode
+	}
+
+	if audio := post.Audio; audio != nil {
+		item.Enclosure = &Enclosure{
+			URL:      b.Site.URL(audio.URL),
+			Type:     audio.MimeType,
+			Length:   audio.Size,
+			Duration: time.Duration(audio.Duration) * time.Second,
+		}
+	} else if post.ImageURL != "" {
+		enclosure := &Enclosure{URL: b.Site.URL(post.ImageURL), Type: mime.TypeByExtension(path.Ext(post.ImageURL))}
+		if enclosure.Type == "" {
+			enclosure.Type = "application/octet-stream"
+		}
+		// Images hosted elsewhere are of unknown size.
+		if post.Media != nil {
+			enclosure.Length = post.Media.Size
+		}
+		item.Enclosure = enclosure
+	}
+	return item
+}
@@ -0,0 +1,178 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type rss struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ITunesNS  string     `xml:"xmlns:itunes,attr,omitempty"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+	itunesChannel
+}
+
+// itunesChannel are the iTunes podcast tags of a channel.
+type itunesChannel struct {
+	Author   string          `xml:"itunes:author,omitempty"`
+	Owner    *itunesOwner    `xml:"itunes:owner"`
+	Image    *itunesImage    `xml:"itunes:image"`
+	Category *itunesCategory `xml:"itunes:category"`
+	Explicit string          `xml:"itunes:explicit,omitempty"`
+	Type     string          `xml:"itunes:type,omitempty"`
+}
+
+type itunesOwner struct {
+	Name  string `xml:"itunes:name,omitempty"`
+	Email string `xml:"itunes:email,omitempty"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type itunesCategory struct {
+	Text        string          `xml:"text,attr"`
+	Subcategory *itunesCategory `xml:"itunes:category"`
+}
+
+// itunesItem are the iTunes podcast tags of an episode.
+type itunesItem struct {
+	Title       string       `xml:"itunes:title,omitempty"`
+	Episode     int          `xml:"itunes:episode,omitempty"`
+	EpisodeType string       `xml:"itunes:episodeType,omitempty"`
+	Duration    int64        `xml:"itunes:duration,omitempty"`
+	Image       *itunesImage `xml:"itunes:image"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	Description string        `xml:"description"`
+	Content     *cdata        `xml:"content:encoded"`
+	Creator     string        `xml:"dc:creator,omitempty"`
+	Categories  []string      `xml:"category"`
+	PubDate     string        `xml:"pubDate"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+	itunesItem
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// WriteRSS renders the feed as RSS 2.0, with the iTunes tags of a podcast
+// feed when the feed has Podcast set.
+func (f *Feed) WriteRSS(w io.Writer) error {
+	doc := rss{
+		Version:   "2.0",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Language:    f.Language,
+			Generator:   generator,
+			Self:        atomLink{Href: f.FeedURL, Rel: "self", Type: rssType},
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	if p := f.Podcast; p != nil {
+		doc.ITunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+		doc.Channel.itunesChannel = itunesChannel{
+			Author:   p.Author,
+			Explicit: strconv.FormatBool(p.Explicit),
+			Type:     p.Type,
+		}
+		if p.OwnerName != "" || p.OwnerEmail != "" {
+			doc.Channel.Owner = &itunesOwner{Name: p.OwnerName, Email: p.OwnerEmail}
+		}
+		if p.Image != "" {
+			doc.Channel.Image = &itunesImage{Href: p.Image}
+		}
+		if p.Category != "" {
+			category, sub, _ := strings.Cut(p.Category, ">")
+			doc.Channel.Category = &itunesCategory{Text: strings.TrimSpace(category)}
+			if sub = strings.TrimSpace(sub); sub != "" {
+				doc.Channel.Category.Subcategory = &itunesCategory{Text: sub}
+			}
+		}
+	}
+
+	for _, item := range f.Items {
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{IsPermaLink: true, Value: item.ID},
+			Description: item.Summary,
+			Creator:     item.Author,
+			Categories:  item.Categories,
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+		}
+		if item.Content != "" {
+			entry.Content = &cdata{Value: item.Content}
+		}
+		if e := item.Enclosure; e != nil {
+			entry.Enclosure = &rssEnclosure{URL: e.URL, Type: e.Type, Length: strconv.FormatInt(e.Length, 10)}
+		}
+		if f.Podcast != nil {
+			entry.itunesItem = itunesItem{Title: item.Title, Episode: item.Episode, EpisodeType: "full"}
+			if e := item.Enclosure; e != nil {
+				entry.Duration = int64(e.Duration / time.Second)
+			}
+			if item.Image != "" {
+				entry.Image = &itunesImage{Href: item.Image}
+			}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, entry)
+	}
+
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
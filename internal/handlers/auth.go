@@ -3,17 +3,20 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/Realwale/scribana/internal/events"
 	"github.com/Realwale/scribana/internal/models"
 	"github.com/Realwale/scribana/internal/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
 	authService *services.AuthService
+	bus         *events.Bus
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService *services.AuthService, bus *events.Bus) *AuthHandler {
+	return &AuthHandler{authService: authService, bus: bus}
 }
 
 type LoginRequest struct {
@@ -57,7 +60,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Role:     models.ReaderRole,
 	}
 
-	if err := h.authService.Db.Create(user).Error; err != nil {
+	err = h.bus.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return h.bus.Publish(tx, events.UserRegistered{UserID: user.ID})
+	})
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create user"})
 		return
 	}
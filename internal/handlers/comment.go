@@ -2,28 +2,36 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 
+	"github.com/Realwale/scribana/internal/events"
 	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type CommentHandler struct {
-	db *gorm.DB
+	db         *gorm.DB
+	moderation *services.ModerationService
+	spam       *services.SpamService
+	bus        *events.Bus
 }
 
-func NewCommentHandler(db *gorm.DB) *CommentHandler {
-	return &CommentHandler{db: db}
+func NewCommentHandler(db *gorm.DB, moderation *services.ModerationService, spam *services.SpamService, bus *events.Bus) *CommentHandler {
+	return &CommentHandler{db: db, moderation: moderation, spam: spam, bus: bus}
 }
 
 type CreateCommentRequest struct {
 	Content string `json:"content" binding:"required"`
 	PostID  uint   `json:"post_id" binding:"required"`
+	// ParentID is the comment being replied to, if any.
+	ParentID *uint `json:"parent_id"`
+	// Website is a honeypot field hidden from humans; it must be left empty.
+	Website string `json:"website"`
 }
 
 // @Summary Create new comment
-// @Description Create a new comment on a blog post
+// @Description Create a new comment on a blog post. Depending on the moderation rules the comment is published immediately or held as pending; comments scoring as spam go straight to the spam bucket.
 // @Tags comments
 // @Accept json
 // @Produce json
@@ -32,6 +40,7 @@ type CreateCommentRequest struct {
 // @Success 201 {object} models.Comment
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Router /comments [post]
 func (h *CommentHandler) CreateComment(c *gin.Context) {
 	var req CreateCommentRequest
@@ -40,23 +49,78 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
-	userID, _ := strconv.ParseUint(c.GetString("userID"), 10, 64)
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var post models.Post
+	if err := h.db.Scopes(models.Published).Select("id").First(&post, req.PostID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	if req.ParentID != nil {
+		var parent models.Comment
+		err := h.db.Where("post_id = ? AND status = ?", req.PostID, models.CommentApproved).
+			First(&parent, *req.ParentID).Error
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent comment not found on this post"})
+			return
+		}
+	}
+
+	result, err := h.spam.Check(user.ID, req.Content, req.Website)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	status := models.CommentSpam
+	if !h.spam.IsSpam(result.Score) {
+		if status, err = h.moderation.InitialStatus(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+			return
+		}
+	}
+
 	comment := models.Comment{
-		Content: req.Content,
-		PostID:  req.PostID,
-		UserID:  uint(userID),
+		Content:   req.Content,
+		PostID:    req.PostID,
+		ParentID:  req.ParentID,
+		UserID:    user.ID,
+		Status:    status,
+		SpamScore: result.Score,
 	}
 
-	if err := h.db.Create(&comment).Error; err != nil {
+	err = h.bus.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		created := []events.Event{events.CommentCreated{
+			CommentID: comment.ID,
+			PostID:    comment.PostID,
+			UserID:    comment.UserID,
+			Status:    comment.Status,
+		}}
+		if comment.Status == models.CommentApproved {
+			created = append(created, events.CommentPublished{CommentID: comment.ID, PostID: comment.PostID})
+		}
+		return h.bus.Publish(tx, created...)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
 
+	comment.User = *user
+
 	c.JSON(http.StatusCreated, comment)
 }
 
 // @Summary Update comment
-// @Description Update an existing comment
+// @Description Update an existing comment. Authors may edit their own comments; admins and moderators may edit any comment. An author's edit is scored for spam and holds an approved comment for moderation again unless they are trusted; edits never approve a comment.
 // @Tags comments
 // @Accept json
 // @Produce json
@@ -74,8 +138,13 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		return
 	}
 
-	userID, _ := strconv.ParseUint(c.GetString("userID"), 10, 64)
-	if comment.UserID != uint(userID) {
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if comment.UserID != user.ID && !user.CanModerate() {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this comment"})
 		return
 	}
@@ -86,8 +155,50 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		return
 	}
 
+	wasApproved := comment.Status == models.CommentApproved
+
+	// An edit by the author is scored like a new comment, and takes an
+	// approved comment back through moderation unless they are trusted.
+	// Editing never approves a comment: pending comments stay pending, and
+	// rejected or spam comments stay so.
+	if comment.UserID == user.ID {
+		result, err := h.spam.Check(user.ID, req.Content, req.Website)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+			return
+		}
+		comment.SpamScore = result.Score
+		status := comment.Status
+		if h.spam.IsSpam(result.Score) {
+			status = models.CommentSpam
+		} else if wasApproved {
+			if status, err = h.moderation.InitialStatus(user); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+				return
+			}
+			if status != models.CommentApproved {
+				status = models.CommentPending
+			}
+		}
+		comment.Status = status
+	}
+
 	comment.Content = req.Content
-	if err := h.db.Save(&comment).Error; err != nil {
+	err = h.bus.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&comment).Error; err != nil {
+			return err
+		}
+		switch {
+		case comment.Status == models.CommentApproved && wasApproved:
+			return h.bus.Publish(tx, events.CommentUpdated{CommentID: comment.ID, PostID: comment.PostID})
+		case comment.Status == models.CommentApproved:
+			return h.bus.Publish(tx, events.CommentPublished{CommentID: comment.ID, PostID: comment.PostID})
+		case wasApproved:
+			return h.bus.Publish(tx, events.CommentRemoved{CommentID: comment.ID, PostID: comment.PostID})
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
 		return
 	}
@@ -96,7 +207,7 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 }
 
 // @Summary Delete comment
-// @Description Delete a comment
+// @Description Delete a comment. Authors may delete their own comments; admins and moderators may delete any comment.
 // @Tags comments
 // @Security Bearer
 // @Param id path string true "Comment ID"
@@ -111,13 +222,27 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	userID, _ := strconv.ParseUint(c.GetString("userID"), 10, 64)
-	if comment.UserID != uint(userID) {
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if comment.UserID != user.ID && !user.CanModerate() {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this comment"})
 		return
 	}
 
-	if err := h.db.Delete(&comment).Error; err != nil {
+	err = h.bus.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&comment).Error; err != nil {
+			return err
+		}
+		if comment.Status != models.CommentApproved {
+			return nil
+		}
+		return h.bus.Publish(tx, events.CommentRemoved{CommentID: comment.ID, PostID: comment.PostID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 		return
 	}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// currentUser loads the user authenticated by AuthMiddleware.
+func currentUser(c *gin.Context, db *gorm.DB) (*models.User, error) {
+	if user, ok := c.Get("user"); ok {
+		return user.(*models.User), nil
+	}
+
+	var user models.User
+	if err := db.First(&user, c.GetString("userID")).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// canManageMedia reports whether the current user owns the media or is an admin.
+func canManageMedia(c *gin.Context, db *gorm.DB, media *models.Media) bool {
+	user, err := currentUser(c, db)
+	if err != nil {
+		return false
+	}
+	return media.OwnerID == user.ID || user.Role == models.AdminRole
+}
+
+// paginate reads the page and limit query parameters, returning a bounded
+// limit and the matching offset.
+func paginate(c *gin.Context) (limit, offset int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return limit, (page - 1) * limit
+}
+
+// currentUserID returns the authenticated user's ID, or 0 for anonymous
+// requests on routes using OptionalAuthMiddleware.
+func currentUserID(c *gin.Context) uint {
+	userID, _ := strconv.ParseUint(c.GetString("userID"), 10, 64)
+	return uint(userID)
+}
+
+// serveDocument writes a generated document with an ETag and Last-Modified
+// date, so conditional requests from crawlers and feed readers are answered
+// with 304 Not Modified.
+func serveDocument(c *gin.Context, contentType string, modified time.Time, body []byte) {
+	sum := sha256.Sum256(body)
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Cache-Control", "public, max-age=300")
+	http.ServeContent(c.Writer, c.Request, "", modified, bytes.NewReader(body))
+}
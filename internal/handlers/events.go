@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/realtime"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// heartbeatInterval keeps idle connections from being closed by proxies.
+const heartbeatInterval = 25 * time.Second
+
+type EventsHandler struct {
+	db   *gorm.DB
+	hub  *realtime.Hub
+	auth *services.AuthService
+}
+
+func NewEventsHandler(db *gorm.DB, hub *realtime.Hub, auth *services.AuthService) *EventsHandler {
+	return &EventsHandler{db: db, hub: hub, auth: auth}
+}
+
+type StreamTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// @Summary Stream post events
+// @Description Server-Sent Events stream of new, edited and deleted comments and reaction counts for a published post. Members-only posts need a token from POST /me/events/token
+// @Tags events
+// @Produce text/event-stream
+// @Param slug path string true "Post slug"
+// @Param token query string false "Stream token"
+// @Success 200 {string} string "Event stream"
+// @Failure 404 {object} ErrorResponse
+// @Router /posts/{slug}/events [get]
+func (h *EventsHandler) PostEvents(c *gin.Context) {
+	var post models.Post
+	if err := h.db.Scopes(models.VisibleTo(currentUserID(c))).Select("id").
+		Where("slug = ?", c.Param("slug")).First(&post).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	h.stream(c, realtime.PostTopic(post.ID))
+}
+
+// @Summary Create stream token
+// @Description Issue a short-lived token for opening the current user's event stream and members-only post streams, which browsers connect to without an Authorization header
+// @Tags events
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} StreamTokenResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /me/events/token [post]
+func (h *EventsHandler) StreamToken(c *gin.Context) {
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	token, expires, err := h.auth.GenerateStreamToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StreamTokenResponse{Token: token, ExpiresAt: expires})
+}
+
+// @Summary Stream my events
+// @Description Server-Sent Events stream of the current user's notifications. Authenticate with a token from POST /me/events/token; a new one is needed to reconnect once it expires
+// @Tags events
+// @Produce text/event-stream
+// @Param token query string true "Stream token"
+// @Success 200 {string} string "Event stream"
+// @Failure 401,403 {object} ErrorResponse
+// @Router /me/events [get]
+func (h *EventsHandler) UserEvents(c *gin.Context) {
+	h.stream(c, realtime.UserTopic(currentUserID(c)))
+}
+
+func (h *EventsHandler) stream(c *gin.Context, topic string) {
+	events, unsubscribe := h.hub.Subscribe(topic)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/feed"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FeedHandler serves the RSS, Atom and JSON feeds of the blog and of its
+// categories, tags and authors.
+type FeedHandler struct {
+	db      *gorm.DB
+	media   *services.MediaService
+	builder *feed.Builder
+	site    config.SiteConfig
+	items   int
+	podcast config.PodcastConfig
+}
+
+func NewFeedHandler(db *gorm.DB, media *services.MediaService, builder *feed.Builder, cfg config.FeedConfig) *FeedHandler {
+	return &FeedHandler{db: db, media: media, builder: builder, site: builder.Site, items: cfg.Items, podcast: cfg.Podcast}
+}
+
+// Blog serves the feed of every published post.
+func (h *FeedHandler) Blog(format feed.Format) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		posts, err := h.posts(h.db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+			return
+		}
+
+		f := h.builder.Build(h.site.Title, h.site.Description, "/", "/"+format.Filename, posts)
+		h.serve(c, format, f)
+	}
+}
+
+// Category serves the feed of one category.
+func (h *FeedHandler) Category(format feed.Format) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var category models.Category
+		if err := h.db.Where("slug = ?", c.Param("slug")).First(&category).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+
+		posts, err := h.posts(h.db.Where("posts.category_id = ?", category.ID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+			return
+		}
+
+		path := "/categories/" + category.Slug
+		title := fmt.Sprintf("%s: %s", h.site.Title, category.Name)
+		f := h.builder.Build(title, fmt.Sprintf("Posts filed under %s", category.Name), path, path+"/"+format.Filename, posts)
+		h.serve(c, format, f)
+	}
+}
+
+// Tag serves the feed of one tag.
+func (h *FeedHandler) Tag(format feed.Format) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tag models.Tag
+		if err := h.db.Where("slug = ?", c.Param("slug")).First(&tag).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+
+		posts, err := h.posts(h.db.Where("posts.id IN (?)",
+			h.db.Table("post_tags").Select("post_id").Where("tag_id = ?", tag.ID)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+			return
+		}
+
+		path := "/tags/" + tag.Slug
+		title := fmt.Sprintf("%s: %s", h.site.Title, tag.Name)
+		f := h.builder.Build(title, fmt.Sprintf("Posts tagged %s", tag.Name), path, path+"/"+format.Filename, posts)
+		h.serve(c, format, f)
+	}
+}
+
+// Author serves the feed of one author's posts.
+func (h *FeedHandler) Author(format feed.Format) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var author models.User
+		if err := h.db.Where("username = ?", c.Param("username")).First(&author).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+			return
+		}
+
+		posts, err := h.posts(h.db.Where("posts.author_id = ?", author.ID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+			return
+		}
+
+		path := "/authors/" + author.Username
+		title := fmt.Sprintf("%s: %s", h.site.Title, author.Username)
+		f := h.builder.Build(title, fmt.Sprintf("Posts by %s", author.Username), path, path+"/"+format.Filename, posts)
+		h.serve(c, format, f)
+	}
+}
+
+// Podcast serves the podcast feed: every post with audio in the podcast's
+// category, as an RSS feed with iTunes tags. Episodes without a number are
+// numbered by publication order.
+func (h *FeedHandler) Podcast(c *gin.Context) {
+	var category models.Category
+	if h.podcast.Category == "" || h.db.Where("slug = ?", h.podcast.Category).First(&category).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Podcast not found"})
+		return
+	}
+
+	var posts []models.Post
+	err := h.db.Scopes(models.Public).
+		Where("posts.category_id = ? AND posts.audio_id IS NOT NULL", category.ID).
+		Preload("Author").Preload("Category").Preload("Tags").Preload("Media").Preload("Audio").
+		Order("posts.published_at DESC, posts.id DESC").
+		Find(&posts).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+	for i := range posts {
+		if posts[i].Episode == nil {
+			number := len(posts) - i
+			posts[i].Episode = &number
+		}
+		h.media.SignFeedPost(&posts[i])
+	}
+
+	path := "/categories/" + category.Slug
+	title := h.podcast.Title
+	if title == "" {
+		title = fmt.Sprintf("%s: %s", h.site.Title, category.Name)
+	}
+	description := h.podcast.Description
+	if description == "" {
+		description = h.site.Description
+	}
+	f := h.builder.Build(title, description, path, "/podcast.xml", posts)
+	f.Podcast = &feed.Podcast{
+		Author:     h.podcast.Author,
+		OwnerName:  h.podcast.OwnerName,
+		OwnerEmail: h.podcast.OwnerEmail,
+		Category:   h.podcast.ITunesCategory,
+		Explicit:   h.podcast.Explicit,
+		Type:       h.podcast.Type,
+	}
+	if h.podcast.Image != "" {
+		f.Podcast.Image = h.site.URL(h.podcast.Image)
+	}
+	h.serve(c, feed.RSS, f)
+}
+
+// posts loads the latest published posts matching query, with the URLs of
+// their private media signed.
+func (h *FeedHandler) posts(query *gorm.DB) ([]models.Post, error) {
+	var posts []models.Post
+	err := query.Scopes(models.Public).
+		Preload("Author").Preload("Category").Preload("Tags").Preload("Media").Preload("Audio").
+		Order("posts.published_at DESC").Limit(h.items).
+		Find(&posts).Error
+	for i := range posts {
+		h.media.SignFeedPost(&posts[i])
+	}
+	return posts, err
+}
+
+func (h *FeedHandler) serve(c *gin.Context, format feed.Format, f *feed.Feed) {
+	var buf bytes.Buffer
+	if err := format.Write(f, &buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+
+	// The ETag alone answers conditional requests: the newest item's time
+	// stays put when posts leave the feed or signed links are renewed.
+	serveDocument(c, format.ContentType, time.Time{}, buf.Bytes())
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Realwale/scribana/internal/jobs"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type JobHandler struct {
+	db    *gorm.DB
+	queue *jobs.Queue
+}
+
+func NewJobHandler(db *gorm.DB, queue *jobs.Queue) *JobHandler {
+	return &JobHandler{db: db, queue: queue}
+}
+
+type JobListResponse struct {
+	Jobs  []models.Job `json:"jobs"`
+	Total int64        `json:"total"`
+}
+
+// @Summary List jobs
+// @Description List background jobs, newest first (Admin only)
+// @Tags jobs
+// @Produce json
+// @Security Bearer
+// @Param status query string false "Job status (pending, running, succeeded, dead)"
+// @Param kind query string false "Job kind, e.g. webhooks.deliver"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} JobListResponse
+// @Failure 401,403 {object} ErrorResponse
+// @Router /admin/jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	query := h.db.Model(&models.Job{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	limit, offset := paginate(c)
+	var jobs []models.Job
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, JobListResponse{Jobs: jobs, Total: total})
+}
+
+// @Summary Get job
+// @Description Get a background job with its payload and last error (Admin only)
+// @Tags jobs
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.Job
+// @Failure 401,403,404 {object} ErrorResponse
+// @Router /admin/jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	var job models.Job
+	if err := h.db.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Retry job
+// @Description Queue a dead job for another round of attempts (Admin only)
+// @Tags jobs
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Job ID"
+// @Success 202 {object} models.Job
+// @Failure 401,403,404,409 {object} ErrorResponse
+// @Router /admin/jobs/{id}/retry [post]
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	job, err := h.queue.Retry(uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, jobs.ErrNotRetryable):
+			c.JSON(http.StatusConflict, gin.H{"error": "Only dead jobs can be retried"})
+		case errors.Is(err, jobs.ErrKeyTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "A job with the same key is already queued"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/importer"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxMarkdownUpload bounds an uploaded Markdown file or zip archive.
+const maxMarkdownUpload = 32 << 20
+
+type MarkdownHandler struct {
+	markdown *importer.Markdown
+}
+
+func NewMarkdownHandler(markdown *importer.Markdown) *MarkdownHandler {
+	return &MarkdownHandler{markdown: markdown}
+}
+
+// @Summary Import Markdown posts
+// @Description Import posts from a Markdown file with YAML front matter, or a zip archive of them. Posts are matched by slug: existing ones are updated, others created. New posts without an author in their front matter belong to the caller. (Admin only)
+// @Tags markdown
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param file formData file true "A .md file or a .zip archive of .md files"
+// @Param dry_run query bool false "Report what would change without changing anything"
+// @Success 200 {object} importer.Report
+// @Failure 400,401,403 {object} ErrorResponse
+// @Router /admin/markdown/import [post]
+func (h *MarkdownHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMarkdownUpload)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	defer file.Close()
+
+	var files []importer.MarkdownFile
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".zip":
+		files, err = importer.ReadMarkdownZip(file, header.Size)
+	case ".md", ".markdown":
+		var data []byte
+		if data, err = io.ReadAll(io.LimitReader(file, importer.MaxMarkdownFileSize+1)); err == nil && len(data) > importer.MaxMarkdownFileSize {
+			err = fmt.Errorf("larger than %d bytes", importer.MaxMarkdownFileSize)
+		}
+		files = []importer.MarkdownFile{{Name: header.Filename, Data: data}}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload a .md file or a .zip archive"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.markdown.Import(files, importer.MarkdownOptions{
+		DryRun:   c.Query("dry_run") == "true",
+		AuthorID: currentUserID(c),
+		ActorID:  currentUserID(c),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import posts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// @Summary Export Markdown posts
+// @Description Download every post as a Markdown file with YAML front matter, in a zip archive (Admin only)
+// @Tags markdown
+// @Produce application/zip
+// @Security Bearer
+// @Param status query string false "Only posts with this status (draft, published, scheduled)"
+// @Success 200 {file} file
+// @Failure 400,401,403 {object} ErrorResponse
+// @Router /admin/markdown/export [get]
+func (h *MarkdownHandler) Export(c *gin.Context) {
+	status := models.PostStatus(c.Query("status"))
+	switch status {
+	case "", models.PostDraft, models.PostPublished, models.PostScheduled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	filename := fmt.Sprintf("posts-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	archive := zip.NewWriter(c.Writer)
+	_, err := h.markdown.Export(func(name string, data []byte) error {
+		w, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}, importer.MarkdownExportOptions{Status: status})
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		// The archive is partly sent and, without its central directory,
+		// will not open; all that is left is to log why.
+		log.Printf("markdown export: %v", err)
+	}
+}
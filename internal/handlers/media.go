@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/Realwale/scribana/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MediaHandler struct {
+	db    *gorm.DB
+	media *services.MediaService
+}
+
+func NewMediaHandler(db *gorm.DB, media *services.MediaService) *MediaHandler {
+	return &MediaHandler{db: db, media: media}
+}
+
+type MediaListResponse struct {
+	Media []models.Media `json:"media"`
+	Total int64          `json:"total"`
+}
+
+type UpdateMediaRequest struct {
+	AltText string `json:"alt_text" binding:"max=500"`
+	Caption string `json:"caption" binding:"max=2000"`
+}
+
+// @Summary List media
+// @Description List the media library, newest first. Authors see their own uploads; admins see everyone's and may filter by owner. Private media comes with signed URLs that expire.
+// @Tags media
+// @Produce json
+// @Security Bearer
+// @Param q query string false "Search filename, alt text and caption"
+// @Param mime_type query string false "MIME type, e.g. image/png, or audio/* for any audio"
+// @Param owner_id query int false "Owner's user ID (Admin only)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} MediaListResponse
+// @Failure 401,403 {object} ErrorResponse
+// @Router /media [get]
+func (h *MediaHandler) ListMedia(c *gin.Context) {
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	query := h.db.Model(&models.Media{})
+	if user.Role != models.AdminRole {
+		query = query.Where("owner_id = ?", user.ID)
+	} else if owner := c.Query("owner_id"); owner != "" {
+		ownerID, _ := strconv.ParseUint(owner, 10, 64)
+		query = query.Where("owner_id = ?", ownerID)
+	}
+	if q := c.Query("q"); q != "" {
+		pattern := "%" + likeEscaper.Replace(q) + "%"
+		query = query.Where("filename ILIKE ? OR alt_text ILIKE ? OR caption ILIKE ?", pattern, pattern, pattern)
+	}
+	if mimeType := c.Query("mime_type"); mimeType != "" {
+		if kind, ok := strings.CutSuffix(mimeType, "/*"); ok {
+			query = query.Where("mime_type LIKE ?", likeEscaper.Replace(kind)+"/%")
+		} else {
+			query = query.Where("mime_type = ?", mimeType)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media"})
+		return
+	}
+
+	limit, offset := paginate(c)
+	media := []models.Media{}
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&media).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media"})
+		return
+	}
+	for i := range media {
+		h.media.SignMedia(&media[i])
+	}
+
+	c.JSON(http.StatusOK, MediaListResponse{Media: media, Total: total})
+}
+
+// @Summary Get media
+// @Description Get an item of the media library with its variants (owner or admin). Private media comes with signed URLs that expire.
+// @Tags media
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Media ID"
+// @Success 200 {object} models.Media
+// @Failure 401,403,404 {object} ErrorResponse
+// @Router /media/{id} [get]
+func (h *MediaHandler) GetMedia(c *gin.Context) {
+	media, ok := h.find(c)
+	if !ok {
+		return
+	}
+	h.media.SignMedia(media)
+	c.JSON(http.StatusOK, media)
+}
+
+// @Summary Update media
+// @Description Update the alt text and caption of an item of the media library (owner or admin)
+// @Tags media
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Media ID"
+// @Param media body UpdateMediaRequest true "Media details"
+// @Success 200 {object} models.Media
+// @Failure 400,401,403,404 {object} ErrorResponse
+// @Router /media/{id} [put]
+func (h *MediaHandler) UpdateMedia(c *gin.Context) {
+	media, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	media.AltText = req.AltText
+	media.Caption = req.Caption
+	if err := h.db.Save(media).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update media"})
+		return
+	}
+
+	h.media.SignMedia(media)
+	c.JSON(http.StatusOK, media)
+}
+
+// @Summary Delete media
+// @Description Delete an item of the media library and its stored files (owner or admin). Media a post is attached to, links to or embeds cannot be deleted.
+// @Tags media
+// @Security Bearer
+// @Param id path string true "Media ID"
+// @Success 200 {object} map[string]string
+// @Failure 401,403,404,409 {object} ErrorResponse
+// @Router /media/{id} [delete]
+func (h *MediaHandler) DeleteMedia(c *gin.Context) {
+	media, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	err := h.media.Delete(c.Request.Context(), media)
+	if errors.Is(err, services.ErrMediaInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Media is used by a post"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
+}
+
+// @Summary Storage usage
+// @Description Get the storage the caller's media library fills, the bytes reserved by their unfinished resumable uploads and what remains of their role's quota. Remaining is omitted when the role has no quota.
+// @Tags media
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} services.StorageUsage
+// @Failure 401,403 {object} ErrorResponse
+// @Router /media/usage [get]
+func (h *MediaHandler) StorageUsage(c *gin.Context) {
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	usage, err := h.media.Usage(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch storage usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// @Summary Storage report
+// @Description Report the storage filled by each user with media or unfinished uploads, largest first, with their quota, along with the totals (Admin only).
+// @Tags media
+// @Produce json
+// @Security Bearer
+// @Param role query string false "Only users with this role"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} services.StorageReport
+// @Failure 401,403 {object} ErrorResponse
+// @Router /admin/storage [get]
+func (h *MediaHandler) StorageReport(c *gin.Context) {
+	limit, offset := paginate(c)
+	report, err := h.media.StorageReport(c.Request.Context(), models.Role(c.Query("role")), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch storage report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ServePrivate serves private media from the URLs signed by SignMedia,
+// refusing URLs that were altered or have expired. Ranges are supported so
+// audio and video can be played from any position.
+func (h *MediaHandler) ServePrivate(c *gin.Context) {
+	name := strings.TrimPrefix(c.Param("filepath"), "/")
+	r, obj, expires, err := h.media.OpenPrivate(c.Request.Context(), name, c.Query("expires"), c.Query("signature"))
+	switch {
+	case errors.Is(err, storage.ErrURLExpired):
+		c.JSON(http.StatusForbidden, gin.H{"error": "URL has expired"})
+		return
+	case errors.Is(err, storage.ErrBadSignature):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid URL signature"})
+		return
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, services.ErrPrivateUnavailable):
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer r.Close()
+
+	// Caches may keep the file only for the user it was signed for, and no
+	// longer than the URL works.
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(expires).Seconds())))
+	c.Header("Content-Type", obj.ContentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", obj.ModTime, seeker)
+		return
+	}
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, r, nil)
+}
+
+// HidePrivate keeps private media out of the public uploads route, so it is
+// only served from signed URLs.
+func (h *MediaHandler) HidePrivate(c *gin.Context) {
+	file := path.Clean(c.Param("filepath"))
+	if file+"/" == "/"+services.PrivatePrefix || strings.HasPrefix(file, "/"+services.PrivatePrefix) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Next()
+}
+
+// find loads the media named in the path, answering the request itself when
+// it does not exist or the current user may not manage it.
+func (h *MediaHandler) find(c *gin.Context) (*models.Media, bool) {
+	var media models.Media
+	if err := h.db.First(&media, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return nil, false
+	}
+	if !canManageMedia(c, h.db, &media) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to manage this media"})
+		return nil, false
+	}
+	return &media, true
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ModerationHandler struct {
+	db         *gorm.DB
+	moderation *services.ModerationService
+}
+
+func NewModerationHandler(db *gorm.DB, moderation *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{db: db, moderation: moderation}
+}
+
+type BulkModerateRequest struct {
+	IDs    []uint `json:"ids" binding:"required,min=1"`
+	Action string `json:"action" binding:"required,oneof=approve reject spam"`
+}
+
+type CommentQueueResponse struct {
+	Comments []QueuedComment `json:"comments"`
+	Total    int64           `json:"total"`
+}
+
+// QueuedComment is a comment as moderators see it, with the spam score
+// that is kept from its author.
+type QueuedComment struct {
+	models.Comment
+	SpamScore float64 `json:"spam_score"`
+}
+
+var moderationActions = map[string]models.CommentStatus{
+	"approve": models.CommentApproved,
+	"reject":  models.CommentRejected,
+	"spam":    models.CommentSpam,
+}
+
+// @Summary List moderation queue
+// @Description List comments by moderation status, pending by default (Admin and moderator only)
+// @Tags moderation
+// @Produce json
+// @Security Bearer
+// @Param status query string false "Comment status (pending, approved, rejected, spam)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} CommentQueueResponse
+// @Failure 400,401,403 {object} ErrorResponse
+// @Router /moderation/comments [get]
+func (h *ModerationHandler) ListComments(c *gin.Context) {
+	status := models.CommentStatus(c.DefaultQuery("status", string(models.CommentPending)))
+	if !status.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	limit, offset := paginate(c)
+	comments, total, err := h.moderation.ListComments(status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	queued := make([]QueuedComment, len(comments))
+	for i, comment := range comments {
+		queued[i] = QueuedComment{Comment: comment, SpamScore: comment.SpamScore}
+	}
+	c.JSON(http.StatusOK, CommentQueueResponse{Comments: queued, Total: total})
+}
+
+// @Summary Bulk moderate comments
+// @Description Approve, reject or mark as spam several comments at once (Admin and moderator only)
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body BulkModerateRequest true "Comment IDs and action"
+// @Success 200 {object} map[string]int64
+// @Failure 400,401,403 {object} ErrorResponse
+// @Router /moderation/comments/bulk [post]
+func (h *ModerationHandler) BulkModerate(c *gin.Context) {
+	var req BulkModerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	updated, err := h.moderation.SetStatus(req.IDs, moderationActions[req.Action], user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+type ReportQueueResponse struct {
+	Reports []models.Report `json:"reports"`
+	Total   int64           `json:"total"`
+}
+
+type ModerationActionRequest struct {
+	Action        string `json:"action" binding:"required"`
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"`
+}
+
+func (r ModerationActionRequest) sanction() services.Sanction {
+	return services.Sanction{
+		Action:   models.ModerationActionType(r.Action),
+		Reason:   r.Reason,
+		Duration: time.Duration(r.DurationHours) * time.Hour,
+	}
+}
+
+// @Summary List reports
+// @Description List content reports by status, open by default (Admin and moderator only)
+// @Tags moderation
+// @Produce json
+// @Security Bearer
+// @Param status query string false "Report status (open, dismissed, resolved)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} ReportQueueResponse
+// @Failure 400,401,403 {object} ErrorResponse
+// @Router /moderation/reports [get]
+func (h *ModerationHandler) ListReports(c *gin.Context) {
+	status := models.ReportStatus(c.DefaultQuery("status", string(models.ReportOpen)))
+	switch status {
+	case models.ReportOpen, models.ReportDismissed, models.ReportResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	limit, offset := paginate(c)
+	reports, total, err := h.moderation.ListReports(status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ReportQueueResponse{Reports: reports, Total: total})
+}
+
+// @Summary Resolve report
+// @Description Act on a report: dismiss, remove_content, warn, suspend (requires duration_hours) or ban the content's author (Admin and moderator only). Removing content that is already gone resolves the report; sanctioning an author who no longer exists fails with 409.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Report ID"
+// @Param action body ModerationActionRequest true "Action to take"
+// @Success 200 {object} models.Report
+// @Failure 400,401,403,404,409 {object} ErrorResponse
+// @Router /moderation/reports/{id}/actions [post]
+func (h *ModerationHandler) ResolveReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	var req ModerationActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Action == string(models.ActionReinstate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid moderation action"})
+		return
+	}
+
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	report, err := h.moderation.ResolveReport(uint(id), user, req.sanction())
+	if err != nil {
+		respondModerationError(c, err, "Report not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// @Summary Sanction user
+// @Description Warn, suspend (requires duration_hours), ban or reinstate a user (Admin and moderator only)
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "User ID"
+// @Param action body ModerationActionRequest true "Action to take"
+// @Success 200 {object} map[string]string
+// @Failure 400,401,403,404 {object} ErrorResponse
+// @Router /moderation/users/{id}/actions [post]
+func (h *ModerationHandler) SanctionUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req ModerationActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := h.moderation.SanctionUser(uint(id), user, req.sanction()); err != nil {
+		respondModerationError(c, err, "User not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Action applied successfully"})
+}
+
+func respondModerationError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, services.ErrTargetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reported content not found"})
+	case errors.Is(err, services.ErrReportClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Report is already closed"})
+	case errors.Is(err, services.ErrTargetGone):
+		c.JSON(http.StatusConflict, gin.H{"error": "Target no longer exists"})
+	case errors.Is(err, services.ErrInvalidAction), errors.Is(err, services.ErrInvalidDuration):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCannotSanctionMod):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply moderation action"})
+	}
+}
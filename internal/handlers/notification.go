@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	notifications *services.NotificationService
+}
+
+func NewNotificationHandler(notifications *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notifications: notifications}
+}
+
+type NotificationListResponse struct {
+	Notifications []services.NotificationEntry `json:"notifications"`
+	UnreadCount   int64                        `json:"unread_count"`
+}
+
+type NotificationPreferencesRequest struct {
+	Preferences map[models.NotificationType]bool `json:"preferences" binding:"required"`
+}
+
+// @Summary List notifications
+// @Description List the current user's notifications, newest first, with the unread count
+// @Tags notifications
+// @Produce json
+// @Security Bearer
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} NotificationListResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /me/notifications [get]
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	userID := currentUserID(c)
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+	limit, offset := paginate(c)
+
+	notifications, err := h.notifications.List(userID, unreadOnly, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	unread, err := h.notifications.UnreadCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, NotificationListResponse{Notifications: notifications, UnreadCount: unread})
+}
+
+// @Summary Mark notification read
+// @Description Mark one of the current user's notifications as read
+// @Tags notifications
+// @Security Bearer
+// @Param id path string true "Notification ID"
+// @Success 200 {object} map[string]string
+// @Failure 401,404 {object} ErrorResponse
+// @Router /me/notifications/{id}/read [post]
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	found, err := h.notifications.MarkRead(currentUserID(c), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// @Summary Mark all notifications read
+// @Description Mark every unread notification of the current user as read
+// @Tags notifications
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]int64
+// @Failure 401 {object} ErrorResponse
+// @Router /me/notifications/read-all [post]
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	updated, err := h.notifications.MarkAllRead(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// @Summary Get notification preferences
+// @Description Get which notification types are enabled for the current user
+// @Tags notifications
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]bool
+// @Failure 401 {object} ErrorResponse
+// @Router /me/notifications/preferences [get]
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	prefs, err := h.notifications.Preferences(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// @Summary Update notification preferences
+// @Description Enable or disable notification types (mention, comment_reply, post_comment, post_published) for the current user
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param preferences body NotificationPreferencesRequest true "Preferences by type"
+// @Success 200 {object} map[string]bool
+// @Failure 400,401 {object} ErrorResponse
+// @Router /me/notifications/preferences [put]
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	var req NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for kind := range req.Preferences {
+		if !isNotificationType(kind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type: " + string(kind)})
+			return
+		}
+	}
+
+	userID := currentUserID(c)
+	if err := h.notifications.SetPreferences(userID, req.Preferences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	prefs, err := h.notifications.Preferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+func isNotificationType(kind models.NotificationType) bool {
+	for _, t := range models.NotificationTypes {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -1,32 +1,70 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/jobs"
 	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/seo"
+	"github.com/Realwale/scribana/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/gosimple/slug"
 	"gorm.io/gorm"
 )
 
 type PostHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	posts     *services.PostService
+	reactions *services.ReactionService
+	media     *services.MediaService
+	bus       *events.Bus
+	seo       *seo.Builder
 }
 
-func NewPostHandler(db *gorm.DB) *PostHandler {
-	return &PostHandler{db: db}
+func NewPostHandler(db *gorm.DB, posts *services.PostService, reactions *services.ReactionService, media *services.MediaService, bus *events.Bus, seo *seo.Builder) *PostHandler {
+	return &PostHandler{db: db, posts: posts, reactions: reactions, media: media, bus: bus, seo: seo}
 }
 
 type CreatePostRequest struct {
 	Title      string `json:"title" binding:"required"`
 	Content    string `json:"content" binding:"required"`
 	CategoryID uint   `json:"category_id" binding:"required"`
-	ImageURL   string `json:"image_url"`
+	// MediaID sets the post's image from the media library.
+	MediaID *uint `json:"media_id"`
+	// ImageURL is an image hosted elsewhere, used when media_id is not set.
+	ImageURL string `json:"image_url"`
+	// AudioID attaches audio from the media library, making the post a
+	// podcast episode when it is in the podcast's category.
+	AudioID *uint `json:"audio_id"`
+	// Episode numbers the episode; episodes without one are numbered by
+	// publication order.
+	Episode *int `json:"episode" binding:"omitempty,min=1"`
+	// Format is the markup of the content, html or markdown; html when omitted.
+	Format string `json:"format" binding:"omitempty,oneof=html markdown"`
+	// Excerpt is a short summary shown in listings and feeds; it is derived
+	// from the content when empty.
+	Excerpt string   `json:"excerpt"`
+	Tags    []string `json:"tags"`
+	// Status is draft, published or scheduled; new posts are published when omitted.
+	Status string `json:"status" binding:"omitempty,oneof=draft published scheduled"`
+	// PublishAt is when a scheduled post goes live.
+	PublishAt *time.Time `json:"publish_at" binding:"required_if=Status scheduled"`
+	// MembersOnly limits the post to signed-in users.
+	MembersOnly bool `json:"members_only"`
+	// SEO overrides; empty fields fall back to the title, excerpt and image.
+	MetaTitle       string `json:"meta_title" binding:"max=200"`
+	MetaDescription string `json:"meta_description" binding:"max=500"`
+	CanonicalURL    string `json:"canonical_url" binding:"omitempty,url"`
+	NoIndex         bool   `json:"noindex"`
+	SocialImage     string `json:"social_image"`
 }
 
 // @Summary Create new post
-// @Description Create a new blog post
+// @Description Create a new blog post, published immediately unless status is draft, or at publish_at when status is scheduled
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -43,52 +81,175 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Status == string(models.PostScheduled) && !req.PublishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+		return
+	}
 
 	userID, _ := strconv.ParseUint(c.GetString("userID"), 10, 64)
 	post := models.Post{
 		Title:      req.Title,
 		Slug:       slug.Make(req.Title),
 		Content:    req.Content,
+		Format:     req.format(),
 		AuthorID:   uint(userID),
 		CategoryID: req.CategoryID,
-		ImageURL:   req.ImageURL,
+		Excerpt:    req.Excerpt,
+		Status:     models.PostDraft,
+	}
+	post.MembersOnly = req.MembersOnly
+	req.applySEO(&post)
+	if !h.applyImage(c, &req, &post) || !h.applyAudio(c, &req, &post) {
+		return
+	}
+	switch models.PostStatus(req.Status) {
+	case models.PostDraft:
+	case models.PostScheduled:
+		post.Schedule(*req.PublishAt)
+	default:
+		post.Publish()
 	}
 
-	if err := h.db.Create(&post).Error; err != nil {
+	err := h.bus.Transaction(func(tx *gorm.DB) error {
+		tags, err := h.posts.ResolveTags(tx, req.Tags)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		if post.Status == models.PostScheduled {
+			if err := h.posts.SchedulePublish(tx, &post); err != nil {
+				return err
+			}
+		}
+		created := []events.Event{events.PostCreated{PostID: post.ID, AuthorID: post.AuthorID}}
+		if post.Status == models.PostPublished {
+			created = append(created, events.PostPublished{PostID: post.ID, ActorID: post.AuthorID})
+		}
+		return h.bus.Publish(tx, created...)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
 		return
 	}
 
+	h.media.SignPost(&post)
 	c.JSON(http.StatusCreated, post)
 }
 
+func (req *CreatePostRequest) format() models.PostFormat {
+	if req.Format == "" {
+		return models.FormatHTML
+	}
+	return models.PostFormat(req.Format)
+}
+
+func (req *CreatePostRequest) applySEO(post *models.Post) {
+	post.MetaTitle = req.MetaTitle
+	post.MetaDescription = req.MetaDescription
+	post.CanonicalURL = req.CanonicalURL
+	post.NoIndex = req.NoIndex
+	post.SocialImage = req.SocialImage
+}
+
+// applyImage sets the post's image from the media library, or from
+// image_url for images hosted elsewhere, answering the request itself when
+// the media cannot be used.
+func (h *PostHandler) applyImage(c *gin.Context, req *CreatePostRequest, post *models.Post) bool {
+	post.MediaID = nil
+	post.ImageURL = req.ImageURL
+	if req.MediaID == nil {
+		return true
+	}
+
+	var media models.Media
+	if err := h.db.First(&media, *req.MediaID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Media not found"})
+		return false
+	}
+	if !canManageMedia(c, h.db, &media) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to use this media"})
+		return false
+	}
+	post.MediaID = &media.ID
+	post.ImageURL = media.URL
+	return true
+}
+
+// applyAudio attaches audio from the media library to the post, answering
+// the request itself when the media cannot be used.
+func (h *PostHandler) applyAudio(c *gin.Context, req *CreatePostRequest, post *models.Post) bool {
+	post.AudioID = nil
+	post.Audio = nil
+	post.Episode = req.Episode
+	if req.AudioID == nil {
+		return true
+	}
+
+	var media models.Media
+	if err := h.db.First(&media, *req.AudioID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Audio not found"})
+		return false
+	}
+	if !media.IsAudio() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Media is not audio"})
+		return false
+	}
+	if !canManageMedia(c, h.db, &media) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to use this media"})
+		return false
+	}
+	post.AudioID = &media.ID
+	return true
+}
+
 // @Summary Get all posts
-// @Description Get all blog posts
+// @Description Get all published blog posts. Members-only posts are included for signed-in users only. Private media they use comes with signed URLs that expire.
 // @Tags posts
 // @Produce json
 // @Param category query string false "Filter by category slug"
+// @Param tag query string false "Filter by tag slug"
+// @Param Authorization header string false "Optional bearer token to include members-only posts and the caller's own reactions"
 // @Success 200 {array} models.Post
 // @Failure 500 {object} ErrorResponse
 // @Router /posts [get]
 func (h *PostHandler) GetPosts(c *gin.Context) {
 	var posts []models.Post
-	query := h.db.Preload("Author").Preload("Category").Preload("Comments")
+	query := h.db.Scopes(models.VisibleTo(currentUserID(c))).Preload("Author").Preload("Category").Preload("Tags").Preload("Media").Preload("Audio").
+		Preload("Comments", "status = ?", models.CommentApproved)
 
 	if category := c.Query("category"); category != "" {
 		query = query.Joins("JOIN categories ON categories.id = posts.category_id").
 			Where("categories.slug = ?", category)
 	}
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("posts.id IN (?)", h.db.Table("post_tags").
+			Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("tags.slug = ?", tag))
+	}
 
 	if err := query.Find(&posts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
 		return
 	}
 
+	if err := h.reactions.Decorate(posts, currentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	// The caller may see these posts, and so their media.
+	for i := range posts {
+		h.media.SignPost(&posts[i])
+	}
+
 	c.JSON(http.StatusOK, posts)
 }
 
 // @Summary Get post by slug
-// @Description Get a blog post by its slug
+// @Description Get a blog post by its slug. Drafts and scheduled posts are only visible to their author and admins, and members-only posts to signed-in users, and so are signed URLs of the private media they use. The response includes computed SEO metadata: Open Graph and Twitter Card tags and schema.org JSON-LD.
 // @Tags posts
 // @Produce json
 // @Param slug path string true "Post slug"
@@ -99,17 +260,35 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 	slug := c.Param("slug")
 	var post models.Post
 
-	if err := h.db.Preload("Author").Preload("Category").Preload("Comments").
+	if err := h.db.Preload("Author").Preload("Category").Preload("Tags").Preload("Media").Preload("Audio").
+		Preload("Comments", "status = ?", models.CommentApproved).
 		Where("slug = ?", slug).First(&post).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, post)
+	if post.Status != models.PostPublished && !h.canManage(c, &post) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if post.MembersOnly && currentUserID(c) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	posts := []models.Post{post}
+	if err := h.reactions.Decorate(posts, currentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return
+	}
+
+	h.media.SignPost(&posts[0])
+	posts[0].SEO = h.seo.Post(&posts[0])
+	c.JSON(http.StatusOK, posts[0])
 }
 
 // @Summary Update post
-// @Description Update an existing blog post
+// @Description Update an existing blog post. Setting status to scheduled (re)schedules its publication for publish_at.
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -117,7 +296,7 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 // @Param id path string true "Post ID"
 // @Param post body CreatePostRequest true "Post details"
 // @Success 200 {object} models.Post
-// @Failure 400,401,403,404 {object} ErrorResponse
+// @Failure 400,401,403,404,409 {object} ErrorResponse
 // @Router /posts/{id} [put]
 func (h *PostHandler) UpdatePost(c *gin.Context) {
 	id := c.Param("id")
@@ -128,8 +307,7 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 	}
 
 	// Check if user is author or admin
-	userID, _ := strconv.ParseUint(c.GetString("userID"), 10, 64)
-	if post.AuthorID != uint(userID) {
+	if !h.canManage(c, &post) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this post"})
 		return
 	}
@@ -139,18 +317,64 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Status == string(models.PostScheduled) && !req.PublishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+		return
+	}
 
 	post.Title = req.Title
 	post.Slug = slug.Make(req.Title)
 	post.Content = req.Content
+	post.Format = req.format()
 	post.CategoryID = req.CategoryID
-	post.ImageURL = req.ImageURL
+	post.Excerpt = req.Excerpt
+	post.MembersOnly = req.MembersOnly
+	req.applySEO(&post)
+	if !h.applyImage(c, &req, &post) || !h.applyAudio(c, &req, &post) {
+		return
+	}
+
+	wasPublished := post.Status == models.PostPublished
+	publish := req.Status == string(models.PostPublished) && !wasPublished
+	if publish {
+		post.Publish()
+	} else if req.Status == string(models.PostDraft) {
+		post.Status = models.PostDraft
+	} else if req.Status == string(models.PostScheduled) {
+		post.Schedule(*req.PublishAt)
+	}
 
-	if err := h.db.Save(&post).Error; err != nil {
+	err := h.bus.Transaction(func(tx *gorm.DB) error {
+		tags, err := h.posts.ResolveTags(tx, req.Tags)
+		if err != nil {
+			return err
+		}
+		if err := tx.Save(&post).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&post).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+		if post.Status == models.PostScheduled {
+			if err := h.posts.SchedulePublish(tx, &post); err != nil {
+				return err
+			}
+		}
+		if publish {
+			return h.bus.Publish(tx, events.PostPublished{PostID: post.ID, ActorID: currentUserID(c)})
+		}
+		return h.bus.Publish(tx, events.PostUpdated{PostID: post.ID, ActorID: currentUserID(c), WasPublished: wasPublished})
+	})
+	if errors.Is(err, jobs.ErrJobRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The post is being published; try again"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
 		return
 	}
 
+	h.media.SignPost(&post)
 	c.JSON(http.StatusOK, post)
 }
 
@@ -171,16 +395,38 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 	}
 
 	// Check if user is author or admin
-	userID, _ := strconv.ParseUint(c.GetString("userID"), 10, 64)
-	if post.AuthorID != uint(userID) {
+	if !h.canManage(c, &post) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this post"})
 		return
 	}
 
-	if err := h.db.Delete(&post).Error; err != nil {
+	err := h.bus.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&post).Error; err != nil {
+			return err
+		}
+		return h.bus.Publish(tx, events.PostDeleted{
+			PostID:       post.ID,
+			Slug:         post.Slug,
+			ActorID:      currentUserID(c),
+			WasPublished: post.Status == models.PostPublished,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
 }
+
+// canManage reports whether the current user is the post's author or an admin.
+func (h *PostHandler) canManage(c *gin.Context, post *models.Post) bool {
+	if currentUserID(c) == 0 {
+		return false
+	}
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		return false
+	}
+	return post.AuthorID == user.ID || user.Role == models.AdminRole
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReactionHandler struct {
+	reactions *services.ReactionService
+}
+
+func NewReactionHandler(reactions *services.ReactionService) *ReactionHandler {
+	return &ReactionHandler{reactions: reactions}
+}
+
+type ToggleReactionRequest struct {
+	TargetType string `json:"target_type" binding:"required,oneof=post comment"`
+	TargetID   uint   `json:"target_id" binding:"required"`
+	Reaction   string `json:"reaction" binding:"required"`
+}
+
+type ToggleReactionResponse struct {
+	Reacted   bool             `json:"reacted"`
+	Reactions map[string]int64 `json:"reactions"`
+}
+
+type ReactionListResponse struct {
+	Reactions []services.ReactionEntry `json:"reactions"`
+	Total     int64                    `json:"total"`
+}
+
+// @Summary List available reactions
+// @Description List the reactions users can leave on posts and comments
+// @Tags reactions
+// @Produce json
+// @Success 200 {array} string
+// @Router /reactions/types [get]
+func (h *ReactionHandler) ListTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, h.reactions.Kinds())
+}
+
+// @Summary Toggle reaction
+// @Description Add a reaction to a post or comment, or remove it if already present
+// @Tags reactions
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param reaction body ToggleReactionRequest true "Reaction details"
+// @Success 200 {object} ToggleReactionResponse
+// @Failure 400,401,404 {object} ErrorResponse
+// @Router /reactions [post]
+func (h *ReactionHandler) ToggleReaction(c *gin.Context) {
+	var req ToggleReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reacted, counts, err := h.reactions.Toggle(currentUserID(c), models.TargetType(req.TargetType), req.TargetID, req.Reaction)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownReaction):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown reaction"})
+		case errors.Is(err, services.ErrTargetNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Content not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reaction"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ToggleReactionResponse{Reacted: reacted, Reactions: counts})
+}
+
+// @Summary List reactions
+// @Description List who reacted to a post or comment, and with what. Reactions to members-only posts are listed for signed-in users only.
+// @Tags reactions
+// @Produce json
+// @Param target_type query string true "Target type (post or comment)"
+// @Param target_id query int true "Target ID"
+// @Param reaction query string false "Only list this reaction"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Param Authorization header string false "Optional bearer token to list reactions to members-only posts"
+// @Success 200 {object} ReactionListResponse
+// @Failure 400,404 {object} ErrorResponse
+// @Router /reactions [get]
+func (h *ReactionHandler) ListReactions(c *gin.Context) {
+	target := models.TargetType(c.Query("target_type"))
+	if target != models.TargetPost && target != models.TargetComment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target_type"})
+		return
+	}
+
+	targetID, err := strconv.ParseUint(c.Query("target_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target_id"})
+		return
+	}
+
+	limit, offset := paginate(c)
+	reactions, total, err := h.reactions.List(currentUserID(c), target, uint(targetID), c.Query("reaction"), limit, offset)
+	if errors.Is(err, services.ErrTargetNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ReactionListResponse{Reactions: reactions, Total: total})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReportHandler struct {
+	db         *gorm.DB
+	moderation *services.ModerationService
+}
+
+func NewReportHandler(db *gorm.DB, moderation *services.ModerationService) *ReportHandler {
+	return &ReportHandler{db: db, moderation: moderation}
+}
+
+type CreateReportRequest struct {
+	TargetType string `json:"target_type" binding:"required,oneof=post comment"`
+	TargetID   uint   `json:"target_id" binding:"required"`
+	Reason     string `json:"reason" binding:"required,oneof=spam harassment hate_speech inappropriate misinformation other"`
+	Details    string `json:"details" binding:"max=2000"`
+}
+
+// @Summary Report content
+// @Description Flag a published post or an approved comment for review by moderators
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param report body CreateReportRequest true "Report details"
+// @Success 201 {object} models.Report
+// @Failure 400,401,404,409 {object} ErrorResponse
+// @Router /reports [post]
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	var req CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	report := models.Report{
+		ReporterID: user.ID,
+		TargetType: models.TargetType(req.TargetType),
+		TargetID:   req.TargetID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+	}
+
+	if err := h.moderation.CreateReport(&report); err != nil {
+		switch {
+		case errors.Is(err, services.ErrTargetNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reported content not found"})
+		case errors.Is(err, services.ErrAlreadyReported):
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this content"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/seo"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/Realwale/scribana/internal/theme"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SiteHandler serves the public HTML site rendered by the theme.
+type SiteHandler struct {
+	db       *gorm.DB
+	media    *services.MediaService
+	theme    *theme.Theme
+	seo      *seo.Builder
+	pageSize int
+}
+
+func NewSiteHandler(db *gorm.DB, media *services.MediaService, theme *theme.Theme, seo *seo.Builder, cfg config.ThemeConfig) *SiteHandler {
+	return &SiteHandler{db: db, media: media, theme: theme, seo: seo, pageSize: cfg.PageSize}
+}
+
+// Home lists the latest posts.
+func (h *SiteHandler) Home(c *gin.Context) {
+	page := &theme.Page{Path: "/"}
+	if !h.list(c, h.db, page) {
+		return
+	}
+	h.render(c, http.StatusOK, theme.Home, page)
+}
+
+// Post shows a published post with its approved comments.
+func (h *SiteHandler) Post(c *gin.Context) {
+	var post models.Post
+	err := h.db.Scopes(models.Public).
+		Preload("Author").Preload("Category").Preload("Tags").Preload("Media").Preload("Audio").
+		Preload("Comments", func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", models.CommentApproved).Order("created_at ASC")
+		}).
+		Preload("Comments.User").
+		Where("slug = ?", c.Param("slug")).First(&post).Error
+	if err != nil {
+		h.NotFound(c)
+		return
+	}
+
+	h.media.SignPost(&post)
+	post.SEO = h.seo.Post(&post)
+	h.render(c, http.StatusOK, theme.Post, &theme.Page{
+		Title:       post.SEO.Title,
+		Description: post.SEO.Description,
+		Path:        post.Path(),
+		SEO:         post.SEO,
+		Post:        &post,
+	})
+}
+
+// Category lists the posts in a category.
+func (h *SiteHandler) Category(c *gin.Context) {
+	var category models.Category
+	if err := h.db.Where("slug = ?", c.Param("slug")).First(&category).Error; err != nil {
+		h.NotFound(c)
+		return
+	}
+
+	path := "/categories/" + category.Slug
+	page := &theme.Page{Title: category.Name, Path: path, Feed: path + "/feed.xml", Category: &category}
+	if !h.list(c, h.db.Where("posts.category_id = ?", category.ID), page) {
+		return
+	}
+	h.render(c, http.StatusOK, theme.Category, page)
+}
+
+// Tag lists the posts with a tag.
+func (h *SiteHandler) Tag(c *gin.Context) {
+	var tag models.Tag
+	if err := h.db.Where("slug = ?", c.Param("slug")).First(&tag).Error; err != nil {
+		h.NotFound(c)
+		return
+	}
+
+	path := "/tags/" + tag.Slug
+	page := &theme.Page{Title: tag.Name, Path: path, Feed: path + "/feed.xml", Tag: &tag}
+	query := h.db.Where("posts.id IN (?)", h.db.Table("post_tags").Select("post_id").Where("tag_id = ?", tag.ID))
+	if !h.list(c, query, page) {
+		return
+	}
+	h.render(c, http.StatusOK, theme.Tag, page)
+}
+
+// Author lists an author's posts.
+func (h *SiteHandler) Author(c *gin.Context) {
+	var author models.User
+	if err := h.db.Where("username = ?", c.Param("username")).First(&author).Error; err != nil {
+		h.NotFound(c)
+		return
+	}
+
+	path := "/authors/" + author.Username
+	page := &theme.Page{Title: author.Username, Path: path, Feed: path + "/feed.xml", Author: &author}
+	if !h.list(c, h.db.Where("posts.author_id = ?", author.ID), page) {
+		return
+	}
+	h.render(c, http.StatusOK, theme.Author, page)
+}
+
+// Search finds published posts whose title, excerpt or content contain the
+// q query parameter.
+func (h *SiteHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	page := &theme.Page{Title: "Search", Query: q}
+	if q != "" {
+		pattern := "%" + likeEscaper.Replace(q) + "%"
+		query := h.db.Where("posts.title ILIKE ? OR posts.excerpt ILIKE ? OR posts.content ILIKE ?", pattern, pattern, pattern)
+		if !h.list(c, query, page) {
+			return
+		}
+	}
+	h.render(c, http.StatusOK, theme.Search, page)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// NotFound renders the theme's 404 page, or a JSON error for API routes.
+func (h *SiteHandler) NotFound(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	h.render(c, http.StatusNotFound, theme.NotFound, &theme.Page{Title: "Page not found"})
+}
+
+// Static serves the theme's static assets.
+func (h *SiteHandler) Static(c *gin.Context) {
+	name := strings.TrimPrefix(c.Param("filepath"), "/")
+	static := h.theme.Static()
+	if info, err := fs.Stat(static, name); err != nil || info.IsDir() {
+		h.NotFound(c)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.FileFromFS(name, http.FS(static))
+}
+
+// list loads one page of the published posts matching query into page. List
+// pages are numbered in the path, as in /page/2, so they can be exported as
+// static files; search results use the page query parameter instead. It
+// reports false after writing a response.
+func (h *SiteHandler) list(c *gin.Context, query *gorm.DB, page *theme.Page) bool {
+	n := 1
+	if param := c.Param("page"); param != "" {
+		var err error
+		if n, err = strconv.Atoi(param); err != nil || n < 2 {
+			h.NotFound(c)
+			return false
+		}
+	} else if q, err := strconv.Atoi(c.Query("page")); err == nil && q > 1 {
+		n = q
+	}
+
+	var posts []models.Post
+	err := query.Scopes(models.Public).
+		Preload("Author").Preload("Category").
+		Order("posts.published_at DESC").
+		Limit(h.pageSize + 1).Offset((n - 1) * h.pageSize).
+		Find(&posts).Error
+	if err != nil {
+		h.error(c, err)
+		return false
+	}
+
+	if len(posts) == 0 && n > 1 {
+		h.NotFound(c)
+		return false
+	}
+
+	page.Pagination = theme.Pagination{Page: n}
+	if n > 1 {
+		page.Pagination.Prev = h.pageURL(c, page, n-1)
+	}
+	if len(posts) > h.pageSize {
+		posts = posts[:h.pageSize]
+		page.Pagination.Next = h.pageURL(c, page, n+1)
+	}
+	for i := range posts {
+		h.media.SignPost(&posts[i])
+	}
+	page.Posts = posts
+	return true
+}
+
+// pageURL links to the nth page of a list.
+func (h *SiteHandler) pageURL(c *gin.Context, page *theme.Page, n int) string {
+	if page.Path == "" {
+		values := c.Request.URL.Query()
+		values.Set("page", strconv.Itoa(n))
+		if n == 1 {
+			values.Del("page")
+		}
+		link := url.URL{Path: c.Request.URL.Path, RawQuery: values.Encode()}
+		return link.String()
+	}
+	if n == 1 {
+		return page.Path
+	}
+	return strings.TrimSuffix(page.Path, "/") + "/page/" + strconv.Itoa(n)
+}
+
+func (h *SiteHandler) render(c *gin.Context, status int, name string, page *theme.Page) {
+	var buf bytes.Buffer
+	if err := h.theme.Render(&buf, name, page); err != nil {
+		h.error(c, err)
+		return
+	}
+
+	if status != http.StatusOK {
+		c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+		return
+	}
+	serveDocument(c, "text/html; charset=utf-8", time.Time{}, buf.Bytes())
+}
+
+func (h *SiteHandler) error(c *gin.Context, err error) {
+	log.Printf("site: %s: %v", c.Request.URL.Path, err)
+	c.String(http.StatusInternalServerError, "Internal Server Error")
+}
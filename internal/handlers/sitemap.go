@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/sitemap"
+	"github.com/gin-gonic/gin"
+)
+
+type SitemapHandler struct {
+	sitemaps *sitemap.Generator
+	site     config.SiteConfig
+	cfg      config.SitemapConfig
+}
+
+func NewSitemapHandler(sitemaps *sitemap.Generator, site config.SiteConfig, cfg config.SitemapConfig) *SitemapHandler {
+	return &SitemapHandler{sitemaps: sitemaps, site: site, cfg: cfg}
+}
+
+const xmlContentType = "application/xml; charset=utf-8"
+
+// Sitemap serves /sitemap.xml.
+func (h *SitemapHandler) Sitemap(c *gin.Context) {
+	doc, err := h.sitemaps.Sitemap()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build sitemap"})
+		return
+	}
+
+	serveDocument(c, xmlContentType, doc.LastModified, doc.Body)
+}
+
+// Page serves one page of a sitemap split into an index.
+func (h *SitemapHandler) Page(c *gin.Context) {
+	n, err := strconv.Atoi(strings.TrimSuffix(c.Param("file"), ".xml"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sitemap not found"})
+		return
+	}
+
+	doc, found, err := h.sitemaps.Page(n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build sitemap"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sitemap not found"})
+		return
+	}
+
+	serveDocument(c, xmlContentType, doc.LastModified, doc.Body)
+}
+
+// Robots serves /robots.txt.
+func (h *SitemapHandler) Robots(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := sitemap.WriteRobots(&buf, h.site, h.cfg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build robots.txt"})
+		return
+	}
+
+	serveDocument(c, "text/plain; charset=utf-8", time.Time{}, buf.Bytes())
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tusVersion is the version of the tus resumable upload protocol served.
+const tusVersion = "1.0.0"
+
+// TusHandler serves resumable uploads through the tus protocol
+// (https://tus.io/protocols/resumable-upload), for files too large or
+// connections too flaky for a single request. Completed uploads are added
+// to the media library.
+type TusHandler struct {
+	db    *gorm.DB
+	media *services.MediaService
+}
+
+func NewTusHandler(db *gorm.DB, media *services.MediaService) *TusHandler {
+	return &TusHandler{db: db, media: media}
+}
+
+// Resumable checks the client speaks the supported protocol version and
+// marks every response with it.
+func (h *TusHandler) Resumable(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported tus version"})
+		return
+	}
+	c.Next()
+}
+
+// @Summary Describe resumable uploads
+// @Description Report the tus protocol version and extensions supported. Authenticated authors also get the largest upload their role allows in Tus-Max-Size.
+// @Tags uploads
+// @Param Authorization header string false "Optional bearer token to include the caller's size limit"
+// @Success 204
+// @Header 204 {string} Tus-Version "Supported protocol versions"
+// @Header 204 {string} Tus-Extension "Supported protocol extensions"
+// @Header 204 {integer} Tus-Max-Size "Largest upload allowed, in bytes"
+// @Router /uploads/tus [options]
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,creation-with-upload,termination,expiration")
+	if currentUserID(c) != 0 {
+		if user, err := currentUser(c, h.db); err == nil && h.media.UploadLimit(user.Role) > 0 {
+			c.Header("Tus-Max-Size", strconv.FormatInt(h.media.UploadLimit(user.Role), 10))
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Create resumable upload
+// @Description Start a tus upload of a file of Upload-Length bytes. Upload-Metadata must carry the file's name as "filename"; its extension decides how the file is validated and stored. The limit depends on the caller's role, and images are limited further. The whole length is reserved against the caller's storage quota until the upload completes or expires. A first chunk may be sent along as application/offset+octet-stream.
+// @Tags uploads
+// @Accept application/offset+octet-stream
+// @Security Bearer
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Length header int true "Size of the whole file in bytes"
+// @Param Upload-Metadata header string true "Comma-separated keys with base64 values, e.g. filename ZXBpc29kZS5tcDM=; private dHJ1ZQ== (true) keeps the file private"
+// @Success 201
+// @Header 201 {string} Location "URL of the new upload"
+// @Header 201 {string} Upload-Expires "When the upload is discarded unless continued"
+// @Header 201 {integer} Upload-Offset "Bytes received, when a first chunk was sent"
+// @Header 201 {integer} X-Quota-Remaining "Storage left to the caller, when limited, with this upload reserved"
+// @Failure 400,401,403,412 {object} ErrorResponse
+// @Failure 413 {object} QuotaErrorResponse "The file exceeds the caller's size limit or storage quota"
+// @Router /uploads/tus [post]
+func (h *TusHandler) CreateUpload(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length is required"})
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Metadata"})
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Metadata must include a filename"})
+		return
+	}
+
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	upload, err := h.media.CreateUpload(c.Request.Context(), user, filename, length, metadata)
+	if uploadRefused(c, h.media, user, err) {
+		return
+	}
+
+	c.Header("Location", strings.TrimRight(c.Request.URL.Path, "/")+"/"+upload.ID)
+	if c.ContentType() == "application/offset+octet-stream" && c.Request.ContentLength != 0 {
+		upload, err = h.media.WriteUpload(c.Request.Context(), upload, 0, c.Request.Body)
+		if writeFailed(c, err) {
+			return
+		}
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	}
+	setUploadExpires(c, upload)
+	h.setQuotaRemaining(c, user)
+	c.Status(http.StatusCreated)
+}
+
+// @Summary Resumable upload offset
+// @Description Report how much of a tus upload has been received, so an interrupted upload can resume from there.
+// @Tags uploads
+// @Security Bearer
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param id path string true "Upload ID"
+// @Success 200
+// @Header 200 {integer} Upload-Offset "Bytes received"
+// @Header 200 {integer} Upload-Length "Size of the whole file"
+// @Failure 401,403,404,410,412 {object} ErrorResponse
+// @Router /uploads/tus/{id} [head]
+func (h *TusHandler) UploadOffset(c *gin.Context) {
+	upload, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Cache-Control", "no-store")
+	setUploadExpires(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// @Summary Send upload chunk
+// @Description Append a chunk to a tus upload. Upload-Offset must match the bytes received so far. Whatever arrives of an interrupted chunk is kept; ask for the offset to resume. The final chunk adds the file to the media library, failing with 400 when it is not a valid file of its type.
+// @Tags uploads
+// @Accept application/offset+octet-stream
+// @Security Bearer
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Offset header int true "Offset the chunk starts at"
+// @Param id path string true "Upload ID"
+// @Success 204
+// @Header 204 {integer} Upload-Offset "Bytes received"
+// @Failure 400,401,403,404,410,412 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Upload-Offset does not match the bytes received"
+// @Failure 413 {object} ErrorResponse "The chunk runs past Upload-Length"
+// @Failure 415 {object} ErrorResponse
+// @Router /uploads/tus/{id} [patch]
+func (h *TusHandler) WriteUpload(c *gin.Context) {
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset is required"})
+		return
+	}
+	upload, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if c.Request.ContentLength > upload.Length-upload.Offset {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk exceeds the upload length"})
+		return
+	}
+
+	upload, err = h.media.WriteUpload(c.Request.Context(), upload, offset, c.Request.Body)
+	if writeFailed(c, err) {
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	setUploadExpires(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Terminate resumable upload
+// @Description Discard a tus upload and the chunks received. Media made from a completed upload is kept.
+// @Tags uploads
+// @Security Bearer
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param id path string true "Upload ID"
+// @Success 204
+// @Failure 401,403,404,410,412 {object} ErrorResponse
+// @Router /uploads/tus/{id} [delete]
+func (h *TusHandler) TerminateUpload(c *gin.Context) {
+	upload, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	if err := h.media.TerminateUpload(c.Request.Context(), upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to terminate upload"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Get resumable upload
+// @Description Get a tus upload's progress, including the media it was added to the library as once complete.
+// @Tags uploads
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Upload ID"
+// @Success 200 {object} models.Upload
+// @Failure 401,403,404,410 {object} ErrorResponse
+// @Router /uploads/tus/{id} [get]
+func (h *TusHandler) GetUpload(c *gin.Context) {
+	upload, ok := h.find(c)
+	if !ok {
+		return
+	}
+	h.media.SignMedia(upload.Media)
+	c.JSON(http.StatusOK, upload)
+}
+
+// find loads the upload named in the URL, answering the request if it is
+// missing, expired or not the current user's.
+func (h *TusHandler) find(c *gin.Context) (*models.Upload, bool) {
+	upload, err := h.media.FindUpload(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrUploadExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Upload has expired"})
+		return nil, false
+	case errors.Is(err, gorm.ErrRecordNotFound) || err == nil && upload.OwnerID != currentUserID(c):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upload"})
+		return nil, false
+	}
+	return upload, true
+}
+
+// writeFailed answers the request when storing a chunk failed.
+func writeFailed(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrOffsetMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the bytes received"})
+	case errors.Is(err, services.ErrUploadOverrun):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk exceeds the upload length"})
+	case errors.Is(err, services.ErrQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Storage quota exceeded"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+	default:
+		return uploadFailed(c, err)
+	}
+	return true
+}
+
+// setQuotaRemaining tells users with a storage quota how much of it is left.
+func (h *TusHandler) setQuotaRemaining(c *gin.Context, user *models.User) {
+	usage, err := h.media.Usage(c.Request.Context(), user)
+	if err == nil && usage.Remaining != nil {
+		c.Header("X-Quota-Remaining", strconv.FormatInt(*usage.Remaining, 10))
+	}
+}
+
+// setUploadExpires tells the client until when an unfinished upload may be
+// resumed.
+func setUploadExpires(c *gin.Context, upload *models.Upload) {
+	if !upload.Complete() {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated
+// keys, each followed by a space and its base64-encoded value, if any.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -1,22 +1,57 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
 	"net/http"
-	"path/filepath"
+	"path"
+	"strconv"
 
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/Realwale/scribana/pkg/imaging"
 	"github.com/Realwale/scribana/pkg/storage"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type UploadHandler struct {
-	storage *storage.Storage
+	db    *gorm.DB
+	media *services.MediaService
 }
 
-func NewUploadHandler(storage *storage.Storage) *UploadHandler {
-	return &UploadHandler{storage: storage}
+func NewUploadHandler(db *gorm.DB, media *services.MediaService) *UploadHandler {
+	return &UploadHandler{db: db, media: media}
 }
 
+// UploadResponse is the uploaded media, along with the uploader's storage
+// usage and what remains of their quota.
+type UploadResponse struct {
+	models.Media
+	Quota *services.StorageUsage `json:"quota"`
+}
+
+// @Summary Upload image
+// @Description Upload a JPEG, PNG, GIF or WebP image of up to 5MB into the media library. The format is checked against the file's content, which must match its extension, and oversized dimensions are rejected. It is stored without EXIF or GPS metadata, scaled down to the configured maximum width, along with smaller responsive variants and a square thumbnail; srcset lists them for an img element.
+// @Tags uploads
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param image formData file true "Image file"
+// @Param private formData bool false "Keep the image private: served only from signed URLs that expire, given to users allowed to see it"
+// @Success 201 {object} UploadResponse
+// @Failure 400,401,403 {object} ErrorResponse
+// @Failure 413 {object} QuotaErrorResponse "The image would exceed the caller's storage quota"
+// @Router /uploads/image [post]
 func (h *UploadHandler) UploadImage(c *gin.Context) {
+	user, err := currentUser(c, h.db)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
 	// Single file upload
 	file, err := c.FormFile("image")
 	if err != nil {
@@ -30,14 +65,130 @@ func (h *UploadHandler) UploadImage(c *gin.Context) {
 		return
 	}
 
-	filename, err := h.storage.SaveImage(file)
+	if imaging.FormatOf(path.Ext(file.Filename)) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported image format"})
+		return
+	}
+	h.save(c, user, file)
+}
+
+// @Summary Upload file
+// @Description Upload a file into the media library: an image, handled as by /uploads/image, or an MP3, M4A, Ogg or WAV audio file, an MP4 or WebM video, or a PDF. The type is checked against the file's content. The duration of MP3 and M4A audio is read into the media's duration. Files may be as large as the caller's role allows; use the tus endpoint for large files on unreliable connections.
+// @Tags uploads
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param file formData file true "File"
+// @Param private formData bool false "Keep the file private: served only from signed URLs that expire, given to users allowed to see it"
+// @Success 201 {object} UploadResponse
+// @Failure 400,401,403 {object} ErrorResponse
+// @Failure 413 {object} QuotaErrorResponse "The file exceeds the caller's size limit or storage quota"
+// @Router /uploads/file [post]
+func (h *UploadHandler) UploadFile(c *gin.Context) {
+	user, err := currentUser(c, h.db)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
+	// Leave room for the rest of the form around the file.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.media.UploadLimit(user.Role)+1<<20)
+	file, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the upload size limit"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	h.save(c, user, file)
+}
 
-	imageURL := filepath.Join("/uploads", filename)
-	c.JSON(http.StatusOK, gin.H{
-		"url": imageURL,
-	})
+// save adds an uploaded file to the media library once it passes the
+// checks of CheckUpload, as private media when the form asks for it.
+func (h *UploadHandler) save(c *gin.Context, user *models.User, file *multipart.FileHeader) {
+	private := false
+	if value := c.PostForm("private"); value != "" {
+		var err error
+		if private, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "private must be true or false"})
+			return
+		}
+	}
+	err := h.media.CheckUpload(c.Request.Context(), user, file.Filename, file.Size)
+	if uploadRefused(c, h.media, user, err) {
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	defer src.Close()
+
+	media, err := h.media.Upload(c.Request.Context(), user.ID, file.Filename, src, file.Size, private)
+	if uploadRefused(c, h.media, user, err) {
+		return
+	}
+	h.media.SignMedia(media)
+
+	usage, err := h.media.Usage(c.Request.Context(), user)
+	if err != nil {
+		log.Printf("upload: storage usage of user %d: %v", user.ID, err)
+	}
+	c.JSON(http.StatusCreated, UploadResponse{Media: *media, Quota: usage})
+}
+
+// uploadRefused answers the request when an upload was refused before
+// anything was stored, explaining the limit it ran into.
+func uploadRefused(c *gin.Context, media *services.MediaService, user *models.User, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrUploadTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the upload size limit"})
+	case errors.Is(err, services.ErrQuotaExceeded):
+		usage, err := media.Usage(c.Request.Context(), user)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Storage quota exceeded"})
+			return true
+		}
+		c.JSON(http.StatusRequestEntityTooLarge, QuotaErrorResponse{
+			Error: fmt.Sprintf("Storage quota exceeded: %d of %d bytes used, %d remaining",
+				usage.Used+usage.Pending, usage.Quota, *usage.Remaining),
+			Quota: usage,
+		})
+	default:
+		return uploadFailed(c, err)
+	}
+	return true
+}
+
+// QuotaErrorResponse explains an upload refused for lack of storage quota.
+type QuotaErrorResponse struct {
+	Error string                 `json:"error"`
+	Quota *services.StorageUsage `json:"quota,omitempty"`
+}
+
+// uploadFailed answers the request when storing an upload failed,
+// explaining files that were rejected.
+func uploadFailed(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, imaging.ErrUnsupported):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported image format"})
+	case errors.Is(err, storage.ErrUnsupportedType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file type"})
+	case errors.Is(err, imaging.ErrMismatch), errors.Is(err, storage.ErrTypeMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File extension does not match its content"})
+	case errors.Is(err, imaging.ErrTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image dimensions exceed the limit"})
+	case errors.Is(err, services.ErrPrivateUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Private media is not available on this server"})
+	default:
+		log.Printf("upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	}
+	return true
 }
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	db       *gorm.DB
+	webhooks *services.WebhookService
+}
+
+func NewWebhookHandler(db *gorm.DB, webhooks *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{db: db, webhooks: webhooks}
+}
+
+type WebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events" binding:"required,min=1,dive,oneof=post.published post.updated post.deleted comment.created"`
+	Active      *bool    `json:"active"`
+}
+
+// WebhookCreatedResponse includes the signing secret, which is only shown once.
+type WebhookCreatedResponse struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+type DeliveryListResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	Total      int64                    `json:"total"`
+}
+
+// @Summary List webhooks
+// @Description List webhook subscriptions (Admin only)
+// @Tags webhooks
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} models.Webhook
+// @Failure 401,403 {object} ErrorResponse
+// @Router /admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	var webhooks []models.Webhook
+	if err := h.db.Order("id").Find(&webhooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// @Summary Create webhook
+// @Description Subscribe a URL to events. Payloads are POSTed as JSON and signed: X-Scribana-Signature is "sha256=" followed by the hex HMAC-SHA256 of "<X-Scribana-Timestamp>.<body>" keyed with the returned secret (Admin only)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param webhook body WebhookRequest true "Webhook details"
+// @Success 201 {object} WebhookCreatedResponse
+// @Failure 400,401,403 {object} ErrorResponse
+// @Router /admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := services.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	webhook := models.Webhook{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		Secret:      secret,
+		Active:      req.Active == nil || *req.Active,
+	}
+
+	if err := h.db.Create(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebhookCreatedResponse{Webhook: webhook, Secret: secret})
+}
+
+// @Summary Update webhook
+// @Description Change a webhook's URL, events or active flag (Admin only)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Webhook ID"
+// @Param webhook body WebhookRequest true "Webhook details"
+// @Success 200 {object} models.Webhook
+// @Failure 400,401,403,404 {object} ErrorResponse
+// @Router /admin/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := h.db.First(&webhook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook.URL = req.URL
+	webhook.Description = req.Description
+	webhook.Events = req.Events
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+
+	if err := h.db.Save(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// @Summary Delete webhook
+// @Description Remove a webhook subscription; queued deliveries to it are abandoned (Admin only)
+// @Tags webhooks
+// @Security Bearer
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} map[string]string
+// @Failure 401,403,404 {object} ErrorResponse
+// @Router /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := h.db.First(&webhook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	if err := h.db.Delete(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// @Summary List webhook deliveries
+// @Description List the delivery log of a webhook, newest first, with response codes (Admin only)
+// @Tags webhooks
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Webhook ID"
+// @Param status query string false "Delivery status (pending, succeeded, failed)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} DeliveryListResponse
+// @Failure 401,403,404 {object} ErrorResponse
+// @Router /admin/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var webhook models.Webhook
+	if err := h.db.Unscoped().First(&webhook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	query := h.db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	limit, offset := paginate(c)
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, DeliveryListResponse{Deliveries: deliveries, Total: total})
+}
+
+// @Summary Redeliver webhook
+// @Description Queue a new delivery with the same payload as an earlier one (Admin only)
+// @Tags webhooks
+// @Produce json
+// @Security Bearer
+// @Param id path string true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 401,403,404 {object} ErrorResponse
+// @Router /admin/webhook-deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	delivery, err := h.webhooks.Redeliver(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver webhook"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// maxRedirects bounds the redirects followed for one attachment.
+const maxRedirects = 10
+
+// errBlockedAddress is returned for attachments on addresses other than
+// public ones. Import files name arbitrary URLs, and fetching them must not
+// reach the server's own network.
+var errBlockedAddress = errors.New("address not allowed")
+
+// reservedPrefixes are not reachable on the internet but are not caught by
+// netip's classification either.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// newAttachmentClient returns a client that fetches http and https URLs on
+// public addresses only. Addresses are checked as they are dialed, after
+// DNS resolution and for every redirect, and no proxy is used.
+func newAttachmentClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !isPublic(addr) {
+				return fmt.Errorf("%s: %w", host, errBlockedAddress)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   time.Minute,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return checkScheme(req.URL)
+		},
+	}
+}
+
+// checkScheme only allows attachments to be fetched over http and https.
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+// isPublic reports whether addr is a unicast address on the internet, as
+// opposed to a loopback, private, link-local or otherwise reserved one.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,510 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/gosimple/slug"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// MaxMarkdownFileSize bounds a single Markdown file read from a directory or
+// zip archive.
+const MaxMarkdownFileSize = 4 << 20
+
+// FrontMatter is the YAML header of a Markdown post. Exporting a post and
+// importing the file again gives back the same post.
+type FrontMatter struct {
+	Title    string     `yaml:"title"`
+	Slug     string     `yaml:"slug,omitempty"`
+	Author   string     `yaml:"author,omitempty"`
+	Category string     `yaml:"category"`
+	Tags     []string   `yaml:"tags,omitempty,flow"`
+	Date     *time.Time `yaml:"date,omitempty"`
+	// Status is draft, published or scheduled; published when omitted.
+	Status  string `yaml:"status,omitempty"`
+	Image   string `yaml:"image,omitempty"`
+	Excerpt string `yaml:"excerpt,omitempty"`
+	// Format is html for posts written in HTML; the body is Markdown when
+	// omitted.
+	Format          string `yaml:"format,omitempty"`
+	MetaTitle       string `yaml:"meta_title,omitempty"`
+	MetaDescription string `yaml:"meta_description,omitempty"`
+	CanonicalURL    string `yaml:"canonical_url,omitempty"`
+	NoIndex         bool   `yaml:"noindex,omitempty"`
+	SocialImage     string `yaml:"social_image,omitempty"`
+	// MembersOnly limits the post to signed-in users.
+	MembersOnly bool `yaml:"members_only,omitempty"`
+}
+
+// MarkdownFile is a Markdown post read from a directory or archive.
+type MarkdownFile struct {
+	Name string
+	Data []byte
+}
+
+const frontMatterDelimiter = "---\n"
+
+// EncodeMarkdown renders a post, with its Author, Category and Tags loaded,
+// as a Markdown file.
+func EncodeMarkdown(post *models.Post) ([]byte, error) {
+	fm := FrontMatter{
+		Title:           post.Title,
+		Slug:            post.Slug,
+		Author:          post.Author.Username,
+		Category:        post.Category.Name,
+		Status:          string(post.Status),
+		Image:           post.ImageURL,
+		Excerpt:         post.Excerpt,
+		MetaTitle:       post.MetaTitle,
+		MetaDescription: post.MetaDescription,
+		CanonicalURL:    post.CanonicalURL,
+		NoIndex:         post.NoIndex,
+		SocialImage:     post.SocialImage,
+		MembersOnly:     post.MembersOnly,
+	}
+	for _, tag := range post.Tags {
+		fm.Tags = append(fm.Tags, tag.Name)
+	}
+	sort.Strings(fm.Tags)
+	if post.PublishedAt != nil {
+		date := post.PublishedAt.UTC()
+		fm.Date = &date
+	}
+	if post.Format != models.FormatMarkdown {
+		fm.Format = string(models.FormatHTML)
+	}
+
+	header, err := yaml.Marshal(&fm)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(frontMatterDelimiter)
+	buf.Write(header)
+	buf.WriteString(frontMatterDelimiter)
+	buf.WriteString("\n")
+	buf.WriteString(post.Content)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// DecodeMarkdown splits a Markdown file into its front matter and body. The
+// blank line after the front matter and the final newline that
+// EncodeMarkdown adds are not part of the body.
+func DecodeMarkdown(data []byte) (*FrontMatter, string, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimPrefix(text, "\ufeff")
+	if !strings.HasPrefix(text, frontMatterDelimiter) {
+		return nil, "", errors.New("missing front matter")
+	}
+	rest := text[len(frontMatterDelimiter):]
+
+	var header, body string
+	switch end := strings.Index(rest, "\n"+frontMatterDelimiter); {
+	case strings.HasPrefix(rest, frontMatterDelimiter):
+		body = rest[len(frontMatterDelimiter):]
+	case end >= 0:
+		header, body = rest[:end+1], rest[end+1+len(frontMatterDelimiter):]
+	case strings.HasSuffix(rest, "\n---"):
+		header = strings.TrimSuffix(rest, "---")
+	default:
+		return nil, "", errors.New("unterminated front matter")
+	}
+
+	var fm FrontMatter
+	if err := yaml.Unmarshal([]byte(header), &fm); err != nil {
+		return nil, "", fmt.Errorf("front matter: %w", err)
+	}
+	body = strings.TrimPrefix(body, "\n")
+	body = strings.TrimSuffix(body, "\n")
+	return &fm, body, nil
+}
+
+// ReadMarkdownDir reads every .md file under dir.
+func ReadMarkdownDir(dir string) ([]MarkdownFile, error) {
+	var files []MarkdownFile
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isMarkdown(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > MaxMarkdownFileSize {
+			return fmt.Errorf("%s: larger than %d bytes", name, MaxMarkdownFileSize)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, name)
+		files = append(files, MarkdownFile{Name: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// ReadMarkdownZip reads every .md file in a zip archive.
+func ReadMarkdownZip(r io.ReaderAt, size int64) ([]MarkdownFile, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []MarkdownFile
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !isMarkdown(entry.Name) || strings.HasPrefix(path.Base(entry.Name), "._") {
+			continue
+		}
+		if entry.UncompressedSize64 > MaxMarkdownFileSize {
+			return nil, fmt.Errorf("%s: larger than %d bytes", entry.Name, MaxMarkdownFileSize)
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, MaxMarkdownFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > MaxMarkdownFileSize {
+			return nil, fmt.Errorf("%s: larger than %d bytes", entry.Name, MaxMarkdownFileSize)
+		}
+		files = append(files, MarkdownFile{Name: entry.Name, Data: data})
+	}
+	return files, nil
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// Markdown imports and exports posts as Markdown files with YAML front
+// matter. Imports match posts by slug: existing posts are updated, others
+// created, and files that match their post exactly are left alone.
+type Markdown struct {
+	db    *gorm.DB
+	bus   *events.Bus
+	posts *services.PostService
+}
+
+func NewMarkdownImporter(db *gorm.DB, bus *events.Bus, posts *services.PostService) *Markdown {
+	return &Markdown{db: db, bus: bus, posts: posts}
+}
+
+// MarkdownOptions control a Markdown import.
+type MarkdownOptions struct {
+	// DryRun reports what would change without changing anything.
+	DryRun bool
+	// AuthorID owns new posts whose front matter names no author.
+	AuthorID uint
+	// ActorID is recorded as the user publishing and updating posts.
+	ActorID uint
+}
+
+// Import creates or updates a post for every file. Files that can't be
+// imported are skipped and reported; the others are still imported.
+func (m *Markdown) Import(files []MarkdownFile, opts MarkdownOptions) (*Report, error) {
+	report := newReport("markdown", opts.DryRun)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	importAll := func(atomic func(func(tx *gorm.DB) error) error) error {
+		seen := map[string]string{}
+		for _, file := range files {
+			err := atomic(func(tx *gorm.DB) error {
+				return m.importFile(tx, file, opts, seen, report)
+			})
+			var invalid invalidFile
+			if errors.As(err, &invalid) {
+				report.add("post", file.Name, 0, Skipped, invalid.Error())
+				report.warn("%s: %v", file.Name, invalid)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", file.Name, err)
+			}
+		}
+		return nil
+	}
+
+	if !opts.DryRun {
+		if err := importAll(m.bus.Transaction); err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		nested := func(fn func(tx *gorm.DB) error) error { return tx.Transaction(fn) }
+		if err := importAll(nested); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// invalidFile is a problem with a file itself, which skips the file rather
+// than failing the import.
+type invalidFile struct{ error }
+
+func invalidf(format string, args ...interface{}) error {
+	return invalidFile{fmt.Errorf(format, args...)}
+}
+
+// postState is what a Markdown file says about a post, for telling whether
+// an import changes it.
+type postState struct {
+	Title, Content, Excerpt, ImageURL        string
+	Format                                   models.PostFormat
+	AuthorID, CategoryID                     uint
+	Status                                   models.PostStatus
+	PublishedAt                              int64
+	MetaTitle, MetaDescription, CanonicalURL string
+	SocialImage                              string
+	NoIndex, MembersOnly                     bool
+	Tags                                     string
+}
+
+func stateOf(post *models.Post, tags []models.Tag) postState {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Slug
+	}
+	sort.Strings(names)
+	state := postState{
+		Title: post.Title, Content: post.Content, Excerpt: post.Excerpt, ImageURL: post.ImageURL,
+		Format: post.Format, AuthorID: post.AuthorID, CategoryID: post.CategoryID, Status: post.Status,
+		MetaTitle: post.MetaTitle, MetaDescription: post.MetaDescription, CanonicalURL: post.CanonicalURL,
+		SocialImage: post.SocialImage, NoIndex: post.NoIndex, MembersOnly: post.MembersOnly,
+		Tags: strings.Join(names, ","),
+	}
+	if post.PublishedAt != nil {
+		state.PublishedAt = post.PublishedAt.UnixMicro()
+	}
+	return state
+}
+
+func (m *Markdown) importFile(tx *gorm.DB, file MarkdownFile, opts MarkdownOptions, seen map[string]string, report *Report) error {
+	fm, body, err := DecodeMarkdown(file.Data)
+	if err != nil {
+		return invalidFile{err}
+	}
+
+	title := strings.TrimSpace(fm.Title)
+	if title == "" {
+		return invalidf("title is required")
+	}
+	postSlug := strings.TrimSpace(fm.Slug)
+	if postSlug == "" {
+		postSlug = strings.TrimSuffix(path.Base(file.Name), path.Ext(file.Name))
+	}
+	if postSlug != slug.Make(postSlug) {
+		return invalidf("slug %q is not a valid slug; try %q", postSlug, slug.Make(postSlug))
+	}
+	if other, ok := seen[postSlug]; ok {
+		return invalidf("slug %q is also used by %s", postSlug, other)
+	}
+	seen[postSlug] = file.Name
+
+	status := models.PostStatus(fm.Status)
+	switch status {
+	case "":
+		status = models.PostPublished
+	case models.PostDraft, models.PostPublished, models.PostScheduled:
+	default:
+		return invalidf("status %q is not one of draft, published or scheduled", fm.Status)
+	}
+	if status == models.PostScheduled && fm.Date == nil {
+		return invalidf("scheduled posts need a date")
+	}
+	format := models.FormatMarkdown
+	switch models.PostFormat(fm.Format) {
+	case "", models.FormatMarkdown:
+	case models.FormatHTML:
+		format = models.FormatHTML
+	default:
+		return invalidf("format %q is not html or markdown", fm.Format)
+	}
+	if strings.TrimSpace(fm.Category) == "" {
+		return invalidf("category is required")
+	}
+
+	var post models.Post
+	err = tx.Unscoped().Where("slug = ?", postSlug).First(&post).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if exists && post.DeletedAt.Valid {
+		return invalidf("slug %q belongs to a deleted post", postSlug)
+	}
+	var before postState
+	if exists {
+		var tags []models.Tag
+		if err := tx.Model(&post).Association("Tags").Find(&tags); err != nil {
+			return err
+		}
+		before = stateOf(&post, tags)
+	}
+
+	switch {
+	case fm.Author != "":
+		var author models.User
+		if err := tx.Where("username = ?", fm.Author).First(&author).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return invalidf("author %q does not exist", fm.Author)
+			}
+			return err
+		}
+		post.AuthorID = author.ID
+	case !exists:
+		if opts.AuthorID == 0 {
+			return invalidf("author is required")
+		}
+		post.AuthorID = opts.AuthorID
+	}
+
+	categoryID, err := m.category(tx, fm.Category)
+	if err != nil {
+		return err
+	}
+	tags, err := m.posts.ResolveTags(tx, fm.Tags)
+	if err != nil {
+		return err
+	}
+
+	wasPublished := exists && post.Status == models.PostPublished
+	post.Title = title
+	post.Slug = postSlug
+	post.Content = body
+	post.Format = format
+	post.CategoryID = categoryID
+	post.Excerpt = fm.Excerpt
+	post.ImageURL = fm.Image
+	post.MetaTitle = fm.MetaTitle
+	post.MetaDescription = fm.MetaDescription
+	post.CanonicalURL = fm.CanonicalURL
+	post.NoIndex = fm.NoIndex
+	post.SocialImage = fm.SocialImage
+	post.MembersOnly = fm.MembersOnly
+	if fm.Date != nil {
+		date := fm.Date.UTC()
+		post.PublishedAt = &date
+	}
+
+	switch {
+	case status == models.PostScheduled && fm.Date.After(time.Now()):
+		post.Schedule(*fm.Date)
+	case status == models.PostScheduled, status == models.PostPublished:
+		post.Publish()
+	default:
+		post.Status = models.PostDraft
+	}
+
+	if exists && stateOf(&post, tags) == before {
+		report.add("post", file.Name, post.ID, Existing, "unchanged")
+		return nil
+	}
+
+	if exists {
+		if err := tx.Save(&post).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&post).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+	} else {
+		post.Tags = tags
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+	}
+	if post.Status == models.PostScheduled {
+		if err := m.posts.SchedulePublish(tx, &post); err != nil {
+			return err
+		}
+	}
+
+	var published []events.Event
+	if post.Status == models.PostPublished && !wasPublished {
+		published = append(published, events.PostPublished{PostID: post.ID, ActorID: opts.ActorID})
+	}
+	if !exists {
+		report.add("post", file.Name, post.ID, Created, "")
+		return m.bus.Publish(tx, append([]events.Event{events.PostCreated{PostID: post.ID, AuthorID: post.AuthorID}}, published...)...)
+	}
+	report.add("post", file.Name, post.ID, Updated, "")
+	if len(published) > 0 {
+		return m.bus.Publish(tx, published...)
+	}
+	return m.bus.Publish(tx, events.PostUpdated{PostID: post.ID, ActorID: opts.ActorID, WasPublished: wasPublished})
+}
+
+// category finds a category by slug or name, creating it if there is none.
+func (m *Markdown) category(tx *gorm.DB, name string) (uint, error) {
+	name = strings.TrimSpace(name)
+	var category models.Category
+	err := tx.Where("slug = ? OR LOWER(name) = LOWER(?)", slug.Make(name), name).First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		category = models.Category{Name: name, Slug: slug.Make(name)}
+		err = tx.Create(&category).Error
+	}
+	return category.ID, err
+}
+
+// MarkdownExportOptions select the posts to export.
+type MarkdownExportOptions struct {
+	// Status limits the export to posts with this status; all posts when
+	// empty.
+	Status models.PostStatus
+}
+
+// Export writes every selected post as <slug>.md through write, returning
+// how many were written.
+func (m *Markdown) Export(write func(name string, data []byte) error, opts MarkdownExportOptions) (int, error) {
+	query := m.db.Preload("Author").Preload("Category").Preload("Tags").Order("id")
+	if opts.Status != "" {
+		query = query.Where("status = ?", opts.Status)
+	}
+
+	count := 0
+	var posts []models.Post
+	err := query.FindInBatches(&posts, 200, func(tx *gorm.DB, batch int) error {
+		for i := range posts {
+			data, err := EncodeMarkdown(&posts[i])
+			if err != nil {
+				return err
+			}
+			if err := write(posts[i].Slug+".md", data); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}).Error
+	return count, err
+}
@@ -0,0 +1,224 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+)
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	published := time.Date(2024, 3, 9, 8, 30, 0, 0, time.FixedZone("EST", -5*3600))
+
+	tests := []struct {
+		name string
+		post models.Post
+	}{
+		{"minimal", models.Post{Title: "Hello", Format: models.FormatMarkdown, Content: "Hi *there*."}},
+		{"full", models.Post{
+			Title:           "Everything: a post",
+			Slug:            "everything",
+			Author:          models.User{Username: "jane"},
+			Category:        models.Category{Name: "News & Views"},
+			Tags:            []models.Tag{{Name: "go"}, {Name: "Blogging"}},
+			Status:          models.PostPublished,
+			PublishedAt:     &published,
+			ImageURL:        "/uploads/cover.jpg",
+			Excerpt:         "First line\n---\nlast line",
+			Format:          models.FormatMarkdown,
+			MetaTitle:       "Meta",
+			MetaDescription: "Description",
+			CanonicalURL:    "https://example.com/everything",
+			NoIndex:         true,
+			SocialImage:     "/uploads/social.png",
+			MembersOnly:     true,
+			Content:         "# Heading\n\nText.",
+		}},
+		{"html", models.Post{Title: "Old", Format: models.FormatHTML, Content: "<p>Hi</p>"}},
+		{"draft", models.Post{Title: "Later", Status: models.PostDraft, Format: models.FormatMarkdown, Content: "Soon"}},
+		{"title looks like a delimiter", models.Post{Title: "---", Format: models.FormatMarkdown, Content: "x"}},
+		{"body with rules", models.Post{Title: "Rules", Format: models.FormatMarkdown, Content: "above\n---\nbelow\n\n---\n"}},
+		{"body with blank edges", models.Post{Title: "Blank", Format: models.FormatMarkdown, Content: "\n\nindented\n\n"}},
+		{"empty body", models.Post{Title: "Empty", Format: models.FormatMarkdown}},
+		{"unicode", models.Post{Title: "Grüße – 日本", Format: models.FormatMarkdown, Content: "Ünïcödé ✓"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeMarkdown(&tt.post)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fm, body, err := DecodeMarkdown(data)
+			if err != nil {
+				t.Fatalf("DecodeMarkdown: %v\n%s", err, data)
+			}
+			if body != tt.post.Content {
+				t.Errorf("body = %q, want %q", body, tt.post.Content)
+			}
+
+			want := FrontMatter{
+				Title:           tt.post.Title,
+				Slug:            tt.post.Slug,
+				Author:          tt.post.Author.Username,
+				Category:        tt.post.Category.Name,
+				Status:          string(tt.post.Status),
+				Image:           tt.post.ImageURL,
+				Excerpt:         tt.post.Excerpt,
+				MetaTitle:       tt.post.MetaTitle,
+				MetaDescription: tt.post.MetaDescription,
+				CanonicalURL:    tt.post.CanonicalURL,
+				NoIndex:         tt.post.NoIndex,
+				SocialImage:     tt.post.SocialImage,
+				MembersOnly:     tt.post.MembersOnly,
+			}
+			for _, tag := range tt.post.Tags {
+				want.Tags = append(want.Tags, tag.Name)
+			}
+			if tt.post.Format == models.FormatHTML {
+				want.Format = "html"
+			}
+
+			got := *fm
+			if (got.Date == nil) != (tt.post.PublishedAt == nil) ||
+				got.Date != nil && !got.Date.Equal(*tt.post.PublishedAt) {
+				t.Errorf("date = %v, want %v", got.Date, tt.post.PublishedAt)
+			}
+			got.Date = nil
+			// Tags are exported sorted.
+			sort.Strings(want.Tags)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("front matter = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  bool
+		wantFM   FrontMatter
+		wantBody string
+	}{
+		{"plain", "---\ntitle: Hi\n---\n\nBody\n", false, FrontMatter{Title: "Hi"}, "Body"},
+		{"no blank line", "---\ntitle: Hi\n---\nBody", false, FrontMatter{Title: "Hi"}, "Body"},
+		{"crlf", "---\r\ntitle: Hi\r\ntags: [a, b]\r\n---\r\n\r\nBody\r\n", false, FrontMatter{Title: "Hi", Tags: []string{"a", "b"}}, "Body"},
+		{"bom", "\ufeff---\ntitle: Hi\n---\nBody", false, FrontMatter{Title: "Hi"}, "Body"},
+		{"empty front matter", "---\n---\nBody", false, FrontMatter{}, "Body"},
+		{"no body", "---\ntitle: Hi\n---", false, FrontMatter{Title: "Hi"}, ""},
+		{"missing front matter", "# Just Markdown\n", true, FrontMatter{}, ""},
+		{"unterminated", "---\ntitle: Hi\n\nBody\n", true, FrontMatter{}, ""},
+		{"bad yaml", "---\ntitle: [unclosed\n---\nBody", true, FrontMatter{}, ""},
+		{"wrong type", "---\ntags: {a: b}\n---\nBody", true, FrontMatter{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body, err := DecodeMarkdown([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DecodeMarkdown = %+v, %q, want an error", fm, body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*fm, tt.wantFM) || body != tt.wantBody {
+				t.Errorf("DecodeMarkdown = %+v, %q, want %+v, %q", *fm, body, tt.wantFM, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestReadMarkdownZip(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := map[string]string{
+		"posts/a.md":          "a",
+		"posts/b.MARKDOWN":    "b",
+		"posts/._a.md":        "resource fork",
+		"posts/notes.txt":     "not markdown",
+		"posts/image.png":     "not markdown",
+		"__MACOSX/posts/x.md": "x",
+	}
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if _, err := w.Create("posts/dir.md/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadMarkdownZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := map[string]string{}
+	for _, f := range got {
+		read[f.Name] = string(f.Data)
+	}
+	want := map[string]string{"posts/a.md": "a", "posts/b.MARKDOWN": "b", "__MACOSX/posts/x.md": "x"}
+	if !reflect.DeepEqual(read, want) {
+		t.Errorf("ReadMarkdownZip read %v, want %v", read, want)
+	}
+}
+
+func TestReadMarkdownZipTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("big.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(bytes.Repeat([]byte("x"), MaxMarkdownFileSize+1))
+	w.Close()
+
+	if _, err := ReadMarkdownZip(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err == nil || !strings.Contains(err.Error(), "big.md") {
+		t.Errorf("ReadMarkdownZip = %v, want an error naming big.md", err)
+	}
+}
+
+func TestReadMarkdownDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "a")
+	write("2024/b.markdown", "b")
+	write("2024/c.txt", "c")
+
+	got, err := ReadMarkdownDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MarkdownFile{{Name: "2024/b.markdown", Data: []byte("b")}, {Name: "a.md", Data: []byte("a")}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadMarkdownDir = %q, want %q", got, want)
+	}
+
+	write("big.md", strings.Repeat("x", MaxMarkdownFileSize+1))
+	if _, err := ReadMarkdownDir(dir); err == nil {
+		t.Error("ReadMarkdownDir read a file over the size limit")
+	}
+}
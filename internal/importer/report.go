@@ -0,0 +1,125 @@
+// Package importer brings content from other blogging platforms into
+// Scribana, and round-trips posts through Markdown files.
+//
+// Every item imported from WordPress is recorded as a models.ImportRecord,
+// keyed by its source and external ID, so running an import again skips what
+// is already there and picks up only new items. Markdown files are matched
+// to posts by slug instead.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Realwale/scribana/internal/models"
+	"gorm.io/gorm"
+)
+
+// Actions taken on an item, as listed in a Report.
+const (
+	// Created items were added.
+	Created = "created"
+	// Updated items replaced the existing record they match.
+	Updated = "updated"
+	// Matched items already existed, e.g. a user with the same email, and
+	// were mapped to the existing record.
+	Matched = "matched"
+	// Existing items were imported by an earlier run.
+	Existing = "existing"
+	// Skipped items were not imported; the note says why.
+	Skipped = "skipped"
+)
+
+// Report describes what an import did, or would do on a dry run.
+type Report struct {
+	Source   string            `json:"source"`
+	DryRun   bool              `json:"dry_run"`
+	Counts   map[string]*Count `json:"counts"`
+	Items    []Item            `json:"items"`
+	Warnings []string          `json:"warnings"`
+}
+
+// Count tallies the actions taken on one kind of item.
+type Count struct {
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+	Matched  int `json:"matched"`
+	Existing int `json:"existing"`
+	Skipped  int `json:"skipped"`
+}
+
+// Item maps one item of the source to the record it became.
+type Item struct {
+	Kind       string `json:"kind"`
+	ExternalID string `json:"external_id"`
+	LocalID    uint   `json:"local_id,omitempty"`
+	Action     string `json:"action"`
+	Note       string `json:"note,omitempty"`
+}
+
+func newReport(source string, dryRun bool) *Report {
+	return &Report{Source: source, DryRun: dryRun, Counts: map[string]*Count{}, Items: []Item{}, Warnings: []string{}}
+}
+
+func (r *Report) add(kind, externalID string, localID uint, action, note string) {
+	count, ok := r.Counts[kind]
+	if !ok {
+		count = &Count{}
+		r.Counts[kind] = count
+	}
+	switch action {
+	case Created:
+		count.Created++
+	case Updated:
+		count.Updated++
+	case Matched:
+		count.Matched++
+	case Existing:
+		count.Existing++
+	case Skipped:
+		count.Skipped++
+	}
+	r.Items = append(r.Items, Item{Kind: kind, ExternalID: externalID, LocalID: localID, Action: action, Note: note})
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Summary is a line per kind of item, for printing.
+func (r *Report) Summary() []string {
+	kinds := make([]string, 0, len(r.Counts))
+	for kind := range r.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	lines := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		c := r.Counts[kind]
+		lines = append(lines, fmt.Sprintf("%-12s %5d created, %5d updated, %5d matched, %5d existing, %5d skipped",
+			kind, c.Created, c.Updated, c.Matched, c.Existing, c.Skipped))
+	}
+	return lines
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// imported returns the record of an item imported earlier, or nil.
+func imported(tx *gorm.DB, source, kind, externalID string) (*models.ImportRecord, error) {
+	var record models.ImportRecord
+	err := tx.Where("source = ? AND kind = ? AND external_id = ?", source, kind, externalID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func remember(tx *gorm.DB, source, kind, externalID string, localID uint, url string) error {
+	return tx.Create(&models.ImportRecord{Source: source, Kind: kind, ExternalID: externalID, LocalID: localID, URL: url}).Error
+}
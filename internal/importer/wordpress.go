@@ -0,0 +1,757 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/services"
+	"github.com/Realwale/scribana/pkg/storage"
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxAttachmentSize bounds a single downloaded attachment.
+const MaxAttachmentSize = 64 << 20
+
+// WordPress imports WordPress sites from their WXR export files.
+//
+// Authors, categories, tags, posts with their threaded comments, and
+// attachments are imported; pages and other post types are skipped.
+// Imported posts keep their slugs and dates. No domain events are published,
+// so an import doesn't notify followers or fire webhooks for old posts.
+type WordPress struct {
+	db      *gorm.DB
+	storage storage.Storage
+	posts   *services.PostService
+	client  *http.Client
+}
+
+func NewWordPressImporter(db *gorm.DB, storage storage.Storage, posts *services.PostService) *WordPress {
+	return &WordPress{db: db, storage: storage, posts: posts, client: newAttachmentClient()}
+}
+
+// WordPressOptions control a WordPress import.
+type WordPressOptions struct {
+	// DryRun reports what would be imported without changing anything.
+	DryRun bool
+	// SkipAttachments leaves attachments where they are, linking to the old
+	// site instead of downloading them.
+	SkipAttachments bool
+}
+
+// wpRun is the state of one import.
+type wpRun struct {
+	*WordPress
+	opts   WordPressOptions
+	tx     *gorm.DB
+	source string
+	report *Report
+
+	users       map[string]uint // by login
+	usersByID   map[string]uint // by WordPress author ID
+	categories  map[string]uint // by slug
+	tags        map[string]models.Tag
+	attachments map[string]string // local URL by WordPress post ID
+	files       map[string]string // local URL by original URL, see fileKey
+	comments    map[string]uint
+	commenters  map[string]uint
+	flattened   bool
+}
+
+// Import reads a WXR file. It is read twice: first for the authors, terms
+// and attachments, then for the posts that refer to them.
+func (w *WordPress) Import(r io.ReadSeeker, opts WordPressOptions) (*Report, error) {
+	run := &wpRun{
+		WordPress:   w,
+		opts:        opts,
+		tx:          w.db,
+		users:       map[string]uint{},
+		usersByID:   map[string]uint{},
+		categories:  map[string]uint{},
+		tags:        map[string]models.Tag{},
+		attachments: map[string]string{},
+		files:       map[string]string{},
+		comments:    map[string]uint{},
+		commenters:  map[string]uint{},
+		report:      newReport("", opts.DryRun),
+	}
+
+	if !opts.DryRun {
+		return run.run(r)
+	}
+	var report *Report
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		run.tx = tx
+		var err error
+		if report, err = run.run(r); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *wpRun) run(in io.ReadSeeker) (*Report, error) {
+	err := parseWXR(in, wxrVisitor{
+		Site: func(baseURL string) {
+			r.source = "wordpress:" + baseURL
+			r.report.Source = r.source
+		},
+		Author:   r.author,
+		Category: r.category,
+		Tag:      r.tag,
+		Item: func(item *wxrItem) error {
+			if item.Type == "attachment" {
+				return r.attachment(item)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read WXR: %w", err)
+	}
+	if r.source == "" {
+		return nil, errNoSource
+	}
+
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	err = parseWXR(in, wxrVisitor{Item: func(item *wxrItem) error {
+		switch item.Type {
+		case "post":
+			return r.post(item)
+		case "attachment", "nav_menu_item", "revision", "customize_changeset", "custom_css", "wp_global_styles":
+			return nil
+		default:
+			r.report.add(item.Type, item.ID, 0, Skipped, "only posts are imported")
+			return nil
+		}
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("read WXR: %w", err)
+	}
+	return r.report, nil
+}
+
+func (r *wpRun) author(a *wxrAuthor) error {
+	if r.source == "" {
+		return errNoSource
+	}
+	login := strings.TrimSpace(a.Login)
+	if login == "" {
+		return nil
+	}
+
+	record, err := imported(r.tx, r.source, "user", login)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		r.mapUser(a, record.LocalID)
+		r.report.add("user", login, record.LocalID, Existing, "")
+		return nil
+	}
+
+	user, action, err := r.user(r.tx, login, a.Email, models.AuthorRole)
+	if err != nil {
+		return err
+	}
+	if err := remember(r.tx, r.source, "user", login, user.ID, ""); err != nil {
+		return err
+	}
+	r.mapUser(a, user.ID)
+	note := ""
+	if action == Created {
+		note = "no password; the user must reset it to log in"
+	}
+	r.report.add("user", login, user.ID, action, note)
+	return nil
+}
+
+func (r *wpRun) mapUser(a *wxrAuthor, id uint) {
+	r.users[a.Login] = id
+	if a.ID != "" {
+		r.usersByID[a.ID] = id
+	}
+}
+
+// user finds the user with the given email, or creates one with a username
+// derived from name. Created users have no password.
+func (r *wpRun) user(tx *gorm.DB, name, email string, role models.Role) (*models.User, string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	var user models.User
+	if email != "" {
+		err := tx.Where("LOWER(email) = ?", email).First(&user).Error
+		if err == nil {
+			return &user, Matched, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", err
+		}
+	}
+
+	username := slug.Make(name)
+	if username == "" {
+		username = "wordpress-user"
+	}
+	base := username
+	for n := 2; ; n++ {
+		var taken int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+			return nil, "", err
+		}
+		if taken == 0 {
+			break
+		}
+		username = fmt.Sprintf("%s-%d", base, n)
+	}
+	if email == "" {
+		// Email is required and unique; .invalid never resolves.
+		email = username + "@imported.invalid"
+	}
+
+	user = models.User{Username: username, Email: email, Role: role}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, "", err
+	}
+	return &user, Created, nil
+}
+
+func (r *wpRun) category(c *wxrCategory) error {
+	if r.source == "" {
+		return errNoSource
+	}
+	if c.Parent != "" && !r.flattened {
+		r.flattened = true
+		r.report.warn("category hierarchy is flattened: Scribana categories have no parents")
+	}
+	_, err := r.categoryID(r.tx, c.Slug, c.Name)
+	return err
+}
+
+func (r *wpRun) categoryID(tx *gorm.DB, slugName, name string) (uint, error) {
+	slugName = decodeSlug(slugName)
+	if id, ok := r.categories[slugName]; ok {
+		return id, nil
+	}
+
+	record, err := imported(tx, r.source, "category", slugName)
+	if err != nil {
+		return 0, err
+	}
+	if record != nil {
+		r.categories[slugName] = record.LocalID
+		r.report.add("category", slugName, record.LocalID, Existing, "")
+		return record.LocalID, nil
+	}
+
+	var category models.Category
+	action := Matched
+	err = tx.Where("slug = ? OR LOWER(name) = LOWER(?)", slugName, name).First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		category = models.Category{Name: strings.TrimSpace(name), Slug: slugName}
+		err = tx.Create(&category).Error
+		action = Created
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := remember(tx, r.source, "category", slugName, category.ID, ""); err != nil {
+		return 0, err
+	}
+	r.categories[slugName] = category.ID
+	r.report.add("category", slugName, category.ID, action, "")
+	return category.ID, nil
+}
+
+func (r *wpRun) tag(t *wxrCategory) error {
+	if r.source == "" {
+		return errNoSource
+	}
+	_, err := r.resolveTag(r.tx, t.TagSlug, t.TagName)
+	return err
+}
+
+func (r *wpRun) resolveTag(tx *gorm.DB, slugName, name string) (models.Tag, error) {
+	slugName = decodeSlug(slugName)
+	if tag, ok := r.tags[slugName]; ok {
+		return tag, nil
+	}
+
+	var tag models.Tag
+	record, err := imported(tx, r.source, "tag", slugName)
+	if err != nil {
+		return tag, err
+	}
+	if record != nil {
+		if err := tx.First(&tag, record.LocalID).Error; err != nil {
+			return tag, err
+		}
+		r.tags[slugName] = tag
+		r.report.add("tag", slugName, tag.ID, Existing, "")
+		return tag, nil
+	}
+
+	tag = models.Tag{Name: strings.TrimSpace(name), Slug: slugName}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag)
+	if result.Error != nil {
+		return tag, result.Error
+	}
+	action := Created
+	if result.RowsAffected == 0 {
+		action = Matched
+		if err := tx.Where("slug = ? OR LOWER(name) = LOWER(?)", slugName, name).First(&tag).Error; err != nil {
+			return tag, err
+		}
+	}
+	if err := remember(tx, r.source, "tag", slugName, tag.ID, ""); err != nil {
+		return tag, err
+	}
+	r.tags[slugName] = tag
+	r.report.add("tag", slugName, tag.ID, action, "")
+	return tag, nil
+}
+
+func (r *wpRun) attachment(item *wxrItem) error {
+	if r.source == "" {
+		return errNoSource
+	}
+	original := strings.TrimSpace(item.AttachmentURL)
+	if original == "" {
+		return nil
+	}
+
+	record, err := imported(r.tx, r.source, "attachment", item.ID)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		r.mapFile(item.ID, original, record.URL)
+		r.report.add("attachment", item.ID, 0, Existing, record.URL)
+		return nil
+	}
+
+	if r.opts.SkipAttachments {
+		r.mapFile(item.ID, original, original)
+		r.report.add("attachment", item.ID, 0, Skipped, "left at "+original)
+		return nil
+	}
+	if r.opts.DryRun {
+		r.mapFile(item.ID, original, original)
+		r.report.add("attachment", item.ID, 0, Created, "would download "+original)
+		return nil
+	}
+
+	local, err := r.download(original)
+	if err != nil {
+		r.mapFile(item.ID, original, original)
+		r.report.add("attachment", item.ID, 0, Skipped, err.Error())
+		r.report.warn("attachment %s: %v; linking to the original", original, err)
+		return nil
+	}
+	if err := remember(r.tx, r.source, "attachment", item.ID, 0, local); err != nil {
+		return err
+	}
+	r.mapFile(item.ID, original, local)
+	r.report.add("attachment", item.ID, 0, Created, local)
+	return nil
+}
+
+func (r *wpRun) download(src string) (string, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	if err := checkScheme(u); err != nil {
+		return "", err
+	}
+
+	resp, err := r.client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download: %s", resp.Status)
+	}
+	if resp.ContentLength > MaxAttachmentSize {
+		return "", fmt.Errorf("download: larger than %d bytes", MaxAttachmentSize)
+	}
+
+	key, err := storage.Save(context.Background(), r.storage, io.LimitReader(resp.Body, MaxAttachmentSize), -1, path.Ext(u.Path))
+	if err != nil {
+		return "", err
+	}
+	return r.storage.URL(key), nil
+}
+
+func (r *wpRun) mapFile(id, original, local string) {
+	r.attachments[id] = local
+	r.files[fileKey(original)] = local
+}
+
+func (r *wpRun) post(item *wxrItem) error {
+	if r.source == "" {
+		return errNoSource
+	}
+	record, err := imported(r.tx, r.source, "post", item.ID)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		r.report.add("post", item.ID, record.LocalID, Existing, "")
+		// Pick up comments left since the last import.
+		return r.tx.Transaction(func(tx *gorm.DB) error {
+			return r.postComments(tx, record.LocalID, item)
+		})
+	}
+
+	status, note := wpStatus(item.Status)
+	if status == "" {
+		r.report.add("post", item.ID, 0, Skipped, note)
+		return nil
+	}
+
+	return r.tx.Transaction(func(tx *gorm.DB) error {
+		post, note, err := r.newPost(tx, item, status, note)
+		if err != nil {
+			return err
+		}
+		if post == nil {
+			r.report.add("post", item.ID, 0, Skipped, note)
+			return nil
+		}
+		if err := remember(tx, r.source, "post", item.ID, post.ID, ""); err != nil {
+			return err
+		}
+		r.report.add("post", item.ID, post.ID, Created, note)
+		return r.postComments(tx, post.ID, item)
+	})
+}
+
+func (r *wpRun) newPost(tx *gorm.DB, item *wxrItem, status models.PostStatus, note string) (*models.Post, string, error) {
+	authorID, ok := r.users[item.Creator]
+	if !ok {
+		// Exports of a single author's posts may leave the author list out.
+		user, _, err := r.user(tx, item.Creator, "", models.AuthorRole)
+		if err != nil {
+			return nil, "", err
+		}
+		authorID = user.ID
+		r.users[item.Creator] = authorID
+		r.report.warn("post %s: author %q is not in the export; created a user without email", item.ID, item.Creator)
+	}
+
+	var categoryID uint
+	var tags []models.Tag
+	var extra []string
+	for _, term := range item.Terms {
+		switch term.Domain {
+		case "category":
+			if categoryID == 0 {
+				id, err := r.categoryID(tx, term.Nicename, term.Name)
+				if err != nil {
+					return nil, "", err
+				}
+				categoryID = id
+				continue
+			}
+			// Posts have one category; the others become tags.
+			extra = append(extra, term.Name)
+			fallthrough
+		case "post_tag":
+			tag, err := r.resolveTag(tx, term.Nicename, term.Name)
+			if err != nil {
+				return nil, "", err
+			}
+			tags = append(tags, tag)
+		}
+	}
+	if categoryID == 0 {
+		id, err := r.categoryID(tx, "uncategorized", "Uncategorized")
+		if err != nil {
+			return nil, "", err
+		}
+		categoryID = id
+	}
+	if len(extra) > 0 {
+		note = joinNotes(note, "extra categories imported as tags: "+strings.Join(extra, ", "))
+	}
+
+	title := strings.TrimSpace(item.Title)
+	if title == "" {
+		title = "Untitled"
+	}
+	postSlug := slug.Make(decodeSlug(item.Name))
+	if postSlug == "" {
+		postSlug = slug.Make(title)
+	}
+	var taken int64
+	if err := tx.Unscoped().Model(&models.Post{}).Where("slug = ?", postSlug).Count(&taken).Error; err != nil {
+		return nil, "", err
+	}
+	if taken > 0 {
+		old := postSlug
+		postSlug = fmt.Sprintf("%s-wp%s", postSlug, item.ID)
+		note = joinNotes(note, fmt.Sprintf("slug %q is taken; imported as %q", old, postSlug))
+		r.report.warn("post %s: slug %q is taken; imported as %q", item.ID, old, postSlug)
+	}
+
+	post := &models.Post{
+		Title:      title,
+		Slug:       postSlug,
+		Content:    r.rewriteFiles(autop(item.content())),
+		Excerpt:    strings.TrimSpace(item.excerpt()),
+		AuthorID:   authorID,
+		CategoryID: categoryID,
+		Tags:       tags,
+		Status:     models.PostDraft,
+	}
+	if thumbnail := item.meta("_thumbnail_id"); thumbnail != "" {
+		post.ImageURL = r.attachments[thumbnail]
+	}
+	published, ok := wxrTime(item.DateGMT, item.Date)
+	if ok {
+		post.CreatedAt = published
+	}
+	if modified, ok := wxrTime(item.ModifiedGMT, ""); ok {
+		post.UpdatedAt = modified
+	}
+
+	switch {
+	case status == models.PostScheduled && published.After(time.Now()):
+		post.Schedule(published)
+	case status == models.PostScheduled, status == models.PostPublished:
+		if ok {
+			post.PublishedAt = &published
+		}
+		post.Publish()
+	}
+
+	if err := tx.Create(post).Error; err != nil {
+		return nil, "", err
+	}
+	if post.Status == models.PostScheduled {
+		if err := r.posts.SchedulePublish(tx, post); err != nil {
+			return nil, "", err
+		}
+	}
+	return post, note, nil
+}
+
+// wpStatus maps a WordPress post status, returning an empty status for posts
+// that are not imported.
+func wpStatus(status string) (models.PostStatus, string) {
+	switch status {
+	case "publish":
+		return models.PostPublished, ""
+	case "future":
+		return models.PostScheduled, ""
+	case "draft", "pending":
+		return models.PostDraft, ""
+	case "private":
+		return models.PostDraft, "private post imported as a draft"
+	default:
+		return "", fmt.Sprintf("status %q is not imported", status)
+	}
+}
+
+func (r *wpRun) postComments(tx *gorm.DB, postID uint, item *wxrItem) error {
+	comments := append([]wxrComment(nil), item.Comments...)
+	// Replies always have higher IDs than what they reply to.
+	sort.Slice(comments, func(i, j int) bool { return atoi(comments[i].ID) < atoi(comments[j].ID) })
+
+	for _, c := range comments {
+		record, err := imported(tx, r.source, "comment", c.ID)
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			r.comments[c.ID] = record.LocalID
+			r.report.add("comment", c.ID, record.LocalID, Existing, "")
+			continue
+		}
+
+		var status models.CommentStatus
+		switch {
+		case c.Type == "pingback" || c.Type == "trackback":
+			r.report.add("comment", c.ID, 0, Skipped, c.Type+"s are not imported")
+			continue
+		case c.Approved == "1":
+			status = models.CommentApproved
+		case c.Approved == "0":
+			status = models.CommentPending
+		case c.Approved == "spam":
+			status = models.CommentSpam
+		default:
+			r.report.add("comment", c.ID, 0, Skipped, fmt.Sprintf("status %q is not imported", c.Approved))
+			continue
+		}
+
+		userID, ok := r.usersByID[c.UserID]
+		if !ok || c.UserID == "0" {
+			if userID, err = r.commenter(tx, c.Author, c.AuthorEmail); err != nil {
+				return err
+			}
+		}
+
+		comment := models.Comment{
+			Content: strings.TrimSpace(c.Content),
+			PostID:  postID,
+			UserID:  userID,
+			Status:  status,
+		}
+		if created, ok := wxrTime(c.DateGMT, ""); ok {
+			comment.CreatedAt = created
+			comment.UpdatedAt = created
+		}
+		note := ""
+		if c.Parent != "" && c.Parent != "0" {
+			if parentID, ok := r.comments[c.Parent]; ok {
+				comment.ParentID = &parentID
+			} else {
+				note = "reply to a comment that was not imported; imported as a top-level comment"
+			}
+		}
+
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		if err := remember(tx, r.source, "comment", c.ID, comment.ID, ""); err != nil {
+			return err
+		}
+		r.comments[c.ID] = comment.ID
+		r.report.add("comment", c.ID, comment.ID, Created, note)
+	}
+	return nil
+}
+
+// commenter maps a guest commenter to a reader account, one per email
+// address, or per name for comments without one.
+func (r *wpRun) commenter(tx *gorm.DB, name, email string) (uint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "anonymous"
+	}
+	key := "email:" + strings.ToLower(strings.TrimSpace(email))
+	if strings.TrimSpace(email) == "" {
+		key = "name:" + name
+	}
+	if id, ok := r.commenters[key]; ok {
+		return id, nil
+	}
+
+	record, err := imported(tx, r.source, "commenter", key)
+	if err != nil {
+		return 0, err
+	}
+	if record != nil {
+		r.commenters[key] = record.LocalID
+		r.report.add("commenter", key, record.LocalID, Existing, "")
+		return record.LocalID, nil
+	}
+
+	user, action, err := r.user(tx, name, email, models.ReaderRole)
+	if err != nil {
+		return 0, err
+	}
+	if err := remember(tx, r.source, "commenter", key, user.ID, ""); err != nil {
+		return 0, err
+	}
+	r.commenters[key] = user.ID
+	r.report.add("commenter", key, user.ID, action, "")
+	return user.ID, nil
+}
+
+// wpUpload matches links to files in a WordPress uploads directory.
+var wpUpload = regexp.MustCompile(`(?:https?:)?//[^\s"'()<>]+/wp-content/uploads/[^\s"'()<>?#]+`)
+
+// wpSize is the suffix WordPress gives resized copies of images.
+var wpSize = regexp.MustCompile(`-\d+x\d+(\.[A-Za-z0-9]+)$`)
+
+// rewriteFiles points links to imported attachments, including their
+// resized copies, at the imported files.
+func (r *wpRun) rewriteFiles(content string) string {
+	return wpUpload.ReplaceAllStringFunc(content, func(link string) string {
+		if local, ok := r.files[fileKey(link)]; ok {
+			return local
+		}
+		return link
+	})
+}
+
+// fileKey identifies an uploaded file regardless of scheme and of the
+// resized copy linked to.
+func fileKey(link string) string {
+	if i := strings.Index(link, "//"); i >= 0 {
+		link = link[i+2:]
+	}
+	return wpSize.ReplaceAllString(link, "$1")
+}
+
+var blockTag = regexp.MustCompile(`^<(?:p|div|h[1-6]|ul|ol|li|blockquote|pre|figure|table|hr|img|!--|iframe|form|section|article|aside|header|footer|nav|dl|address|details)[\s>/]`)
+
+// autop adds the paragraphs WordPress adds when rendering classic-editor
+// posts, which are stored with blank lines between paragraphs. Content that
+// already has paragraph tags, such as block-editor posts, is left alone.
+func autop(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if strings.Contains(content, "<p>") || strings.Contains(content, "<p ") || !strings.Contains(content, "\n") {
+		return content
+	}
+
+	var b strings.Builder
+	for _, block := range regexp.MustCompile(`\n\s*\n`).Split(content, -1) {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		if blockTag.MatchString(block) {
+			b.WriteString(block)
+		} else {
+			b.WriteString("<p>" + strings.ReplaceAll(block, "\n", "<br>\n") + "</p>")
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// decodeSlug undoes the percent-encoding of non-ASCII WordPress slugs.
+func decodeSlug(s string) string {
+	if decoded, err := url.PathUnescape(s); err == nil {
+		return decoded
+	}
+	return s
+}
+
+// errNoSource is returned for WXR files whose items come before the site
+// URL, which keys the import records.
+var errNoSource = errors.New("not a WordPress export: missing base_blog_url")
+
+func joinNotes(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "; " + b
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,197 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+// The WordPress eXtended RSS (WXR) format. Element names are matched without
+// their namespace, since the wp namespace URI changes with every WXR
+// version; content:encoded and excerpt:encoded are told apart by namespace.
+
+type wxrAuthor struct {
+	ID          string `xml:"author_id"`
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+}
+
+type wxrCategory struct {
+	ID      string `xml:"term_id"`
+	Slug    string `xml:"category_nicename"`
+	Parent  string `xml:"category_parent"`
+	Name    string `xml:"cat_name"`
+	TagSlug string `xml:"tag_slug"`
+	TagName string `xml:"tag_name"`
+}
+
+type wxrItem struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	Creator       string       `xml:"creator"`
+	Encoded       []wxrText    `xml:"encoded"`
+	ID            string       `xml:"post_id"`
+	Date          string       `xml:"post_date"`
+	DateGMT       string       `xml:"post_date_gmt"`
+	ModifiedGMT   string       `xml:"post_modified_gmt"`
+	Name          string       `xml:"post_name"`
+	Status        string       `xml:"status"`
+	Type          string       `xml:"post_type"`
+	Parent        string       `xml:"post_parent"`
+	AttachmentURL string       `xml:"attachment_url"`
+	Terms         []wxrTerm    `xml:"category"`
+	Meta          []wxrMeta    `xml:"postmeta"`
+	Comments      []wxrComment `xml:"comment"`
+}
+
+type wxrText struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type wxrTerm struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+type wxrMeta struct {
+	Key   string `xml:"meta_key"`
+	Value string `xml:"meta_value"`
+}
+
+type wxrComment struct {
+	ID          string `xml:"comment_id"`
+	Author      string `xml:"comment_author"`
+	AuthorEmail string `xml:"comment_author_email"`
+	DateGMT     string `xml:"comment_date_gmt"`
+	Content     string `xml:"comment_content"`
+	Approved    string `xml:"comment_approved"`
+	Type        string `xml:"comment_type"`
+	Parent      string `xml:"comment_parent"`
+	UserID      string `xml:"comment_user_id"`
+}
+
+// content is the body of the item, from content:encoded.
+func (i *wxrItem) content() string {
+	for _, text := range i.Encoded {
+		if !strings.Contains(text.XMLName.Space, "excerpt") {
+			return text.Value
+		}
+	}
+	return ""
+}
+
+// excerpt is the hand-written excerpt of the item, from excerpt:encoded.
+func (i *wxrItem) excerpt() string {
+	for _, text := range i.Encoded {
+		if strings.Contains(text.XMLName.Space, "excerpt") {
+			return text.Value
+		}
+	}
+	return ""
+}
+
+func (i *wxrItem) meta(key string) string {
+	for _, m := range i.Meta {
+		if m.Key == key {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+// wxrVisitor receives the parts of a WXR file as they are read.
+type wxrVisitor struct {
+	Site     func(baseURL string)
+	Author   func(*wxrAuthor) error
+	Category func(*wxrCategory) error
+	Tag      func(*wxrCategory) error
+	Item     func(*wxrItem) error
+}
+
+// parseWXR streams a WXR file, so exports with thousands of posts are never
+// held in memory at once. Visitor callbacks left nil are skipped.
+func parseWXR(r io.Reader, v wxrVisitor) error {
+	dec := xml.NewDecoder(r)
+	// WordPress declares UTF-8 but older exports occasionally claim other
+	// charsets; decode them as is.
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		wp := strings.Contains(start.Name.Space, "wordpress.org/export")
+		switch {
+		case wp && start.Name.Local == "base_blog_url" && v.Site != nil:
+			var url string
+			if err := dec.DecodeElement(&url, &start); err != nil {
+				return err
+			}
+			v.Site(strings.TrimSpace(url))
+		case wp && start.Name.Local == "author" && v.Author != nil:
+			var author wxrAuthor
+			if err := dec.DecodeElement(&author, &start); err != nil {
+				return err
+			}
+			if err := v.Author(&author); err != nil {
+				return err
+			}
+		case wp && start.Name.Local == "category" && v.Category != nil:
+			var category wxrCategory
+			if err := dec.DecodeElement(&category, &start); err != nil {
+				return err
+			}
+			if err := v.Category(&category); err != nil {
+				return err
+			}
+		case wp && start.Name.Local == "tag" && v.Tag != nil:
+			var tag wxrCategory
+			if err := dec.DecodeElement(&tag, &start); err != nil {
+				return err
+			}
+			if err := v.Tag(&tag); err != nil {
+				return err
+			}
+		case start.Name.Local == "item":
+			if v.Item == nil {
+				if err := dec.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			var item wxrItem
+			if err := dec.DecodeElement(&item, &start); err != nil {
+				return err
+			}
+			if err := v.Item(&item); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// wxrTime parses the GMT dates of a WXR file, falling back to the local date
+// for drafts, whose GMT date is all zeros.
+func wxrTime(gmt, local string) (time.Time, bool) {
+	const layout = "2006-01-02 15:04:05"
+	if t, err := time.ParseInLocation(layout, strings.TrimSpace(gmt), time.UTC); err == nil && t.Year() > 1 {
+		return t, true
+	}
+	if t, err := time.ParseInLocation(layout, strings.TrimSpace(local), time.UTC); err == nil && t.Year() > 1 {
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,263 @@
+package importer
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWXR = `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"
+	xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<title>Example</title>
+	<wp:base_blog_url> https://example.com </wp:base_blog_url>
+	<wp:author>
+		<wp:author_id>2</wp:author_id>
+		<wp:author_login><![CDATA[jane]]></wp:author_login>
+		<wp:author_email><![CDATA[jane@example.com]]></wp:author_email>
+		<wp:author_display_name><![CDATA[Jane Doe]]></wp:author_display_name>
+	</wp:author>
+	<wp:category>
+		<wp:term_id>3</wp:term_id>
+		<wp:category_nicename><![CDATA[news]]></wp:category_nicename>
+		<wp:category_parent><![CDATA[]]></wp:category_parent>
+		<wp:cat_name><![CDATA[News & Views]]></wp:cat_name>
+	</wp:category>
+	<wp:tag>
+		<wp:term_id>4</wp:term_id>
+		<wp:tag_slug><![CDATA[go]]></wp:tag_slug>
+		<wp:tag_name><![CDATA[Go]]></wp:tag_name>
+	</wp:tag>
+	<item>
+		<title>Hello</title>
+		<link>https://example.com/hello/</link>
+		<dc:creator><![CDATA[jane]]></dc:creator>
+		<content:encoded><![CDATA[<p>Body</p>]]></content:encoded>
+		<excerpt:encoded><![CDATA[Short]]></excerpt:encoded>
+		<wp:post_id>10</wp:post_id>
+		<wp:post_date><![CDATA[2024-01-02 03:04:05]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[2024-01-02 02:04:05]]></wp:post_date_gmt>
+		<wp:post_name><![CDATA[hello]]></wp:post_name>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+		<category domain="category" nicename="news"><![CDATA[News & Views]]></category>
+		<category domain="post_tag" nicename="go"><![CDATA[Go]]></category>
+		<wp:postmeta>
+			<wp:meta_key><![CDATA[_thumbnail_id]]></wp:meta_key>
+			<wp:meta_value><![CDATA[11]]></wp:meta_value>
+		</wp:postmeta>
+		<wp:comment>
+			<wp:comment_id>5</wp:comment_id>
+			<wp:comment_author><![CDATA[Bob]]></wp:comment_author>
+			<wp:comment_author_email><![CDATA[bob@example.com]]></wp:comment_author_email>
+			<wp:comment_date_gmt><![CDATA[2024-01-03 00:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Nice]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[1]]></wp:comment_approved>
+			<wp:comment_type><![CDATA[comment]]></wp:comment_type>
+			<wp:comment_parent>0</wp:comment_parent>
+			<wp:comment_user_id>0</wp:comment_user_id>
+		</wp:comment>
+	</item>
+	<item>
+		<title>photo</title>
+		<wp:post_id>11</wp:post_id>
+		<wp:post_type><![CDATA[attachment]]></wp:post_type>
+		<wp:post_parent>10</wp:post_parent>
+		<wp:attachment_url><![CDATA[https://example.com/wp-content/uploads/photo.jpg]]></wp:attachment_url>
+	</item>
+</channel>
+</rss>`
+
+func TestParseWXR(t *testing.T) {
+	var (
+		site       string
+		authors    []wxrAuthor
+		categories []wxrCategory
+		tags       []wxrCategory
+		items      []wxrItem
+	)
+	err := parseWXR(strings.NewReader(testWXR), wxrVisitor{
+		Site:     func(baseURL string) { site = baseURL },
+		Author:   func(a *wxrAuthor) error { authors = append(authors, *a); return nil },
+		Category: func(c *wxrCategory) error { categories = append(categories, *c); return nil },
+		Tag:      func(c *wxrCategory) error { tags = append(tags, *c); return nil },
+		Item:     func(i *wxrItem) error { items = append(items, *i); return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if site != "https://example.com" {
+		t.Errorf("site = %q", site)
+	}
+	if want := []wxrAuthor{{ID: "2", Login: "jane", Email: "jane@example.com", DisplayName: "Jane Doe"}}; !reflect.DeepEqual(authors, want) {
+		t.Errorf("authors = %+v, want %+v", authors, want)
+	}
+	if want := []wxrCategory{{ID: "3", Slug: "news", Name: "News & Views"}}; !reflect.DeepEqual(categories, want) {
+		t.Errorf("categories = %+v, want %+v", categories, want)
+	}
+	if want := []wxrCategory{{ID: "4", TagSlug: "go", TagName: "Go"}}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %+v, want %+v", tags, want)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	post, attachment := items[0], items[1]
+	tests := []struct {
+		name, got, want string
+	}{
+		{"title", post.Title, "Hello"},
+		{"creator", post.Creator, "jane"},
+		{"content", post.content(), "<p>Body</p>"},
+		{"excerpt", post.excerpt(), "Short"},
+		{"id", post.ID, "10"},
+		{"name", post.Name, "hello"},
+		{"status", post.Status, "publish"},
+		{"type", post.Type, "post"},
+		{"date", post.DateGMT, "2024-01-02 02:04:05"},
+		{"thumbnail", post.meta("_thumbnail_id"), "11"},
+		{"missing meta", post.meta("_missing"), ""},
+		{"attachment type", attachment.Type, "attachment"},
+		{"attachment parent", attachment.Parent, "10"},
+		{"attachment url", attachment.AttachmentURL, "https://example.com/wp-content/uploads/photo.jpg"},
+		{"attachment content", attachment.content(), ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	wantTerms := []wxrTerm{
+		{Domain: "category", Nicename: "news", Name: "News & Views"},
+		{Domain: "post_tag", Nicename: "go", Name: "Go"},
+	}
+	if !reflect.DeepEqual(post.Terms, wantTerms) {
+		t.Errorf("terms = %+v, want %+v", post.Terms, wantTerms)
+	}
+	wantComments := []wxrComment{{
+		ID: "5", Author: "Bob", AuthorEmail: "bob@example.com", DateGMT: "2024-01-03 00:00:00",
+		Content: "Nice", Approved: "1", Type: "comment", Parent: "0", UserID: "0",
+	}}
+	if !reflect.DeepEqual(post.Comments, wantComments) {
+		t.Errorf("comments = %+v, want %+v", post.Comments, wantComments)
+	}
+}
+
+func TestParseWXRVisitor(t *testing.T) {
+	stop := errors.New("stop")
+	tests := []struct {
+		name    string
+		input   string
+		visitor wxrVisitor
+		wantErr error
+		items   int
+	}{
+		{"nil callbacks are skipped", testWXR, wxrVisitor{}, nil, 0},
+		{"author error", testWXR, wxrVisitor{Author: func(*wxrAuthor) error { return stop }}, stop, 0},
+		{"category error", testWXR, wxrVisitor{Category: func(*wxrCategory) error { return stop }}, stop, 0},
+		{"tag error", testWXR, wxrVisitor{Tag: func(*wxrCategory) error { return stop }}, stop, 0},
+		{"item error", testWXR, wxrVisitor{Item: func(*wxrItem) error { return stop }}, stop, 0},
+		// The category of an item is not a wp:category.
+		{"item categories", testWXR, wxrVisitor{Category: func(c *wxrCategory) error {
+			if c.Slug != "news" {
+				return stop
+			}
+			return nil
+		}}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := parseWXR(strings.NewReader(tt.input), tt.visitor); !errors.Is(err, tt.wantErr) {
+				t.Errorf("parseWXR = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := parseWXR(strings.NewReader("<rss><channel><item>"), wxrVisitor{}); err == nil {
+		t.Error("parseWXR succeeded on truncated XML")
+	}
+	latin1 := strings.Replace(testWXR, `encoding="UTF-8"`, `encoding="ISO-8859-1"`, 1)
+	if err := parseWXR(strings.NewReader(latin1), wxrVisitor{}); err != nil {
+		t.Errorf("parseWXR with another declared charset: %v", err)
+	}
+}
+
+func TestWXRTime(t *testing.T) {
+	tests := []struct {
+		gmt, local string
+		want       time.Time
+		ok         bool
+	}{
+		{"2024-01-02 02:04:05", "2024-01-02 03:04:05", time.Date(2024, 1, 2, 2, 4, 5, 0, time.UTC), true},
+		{" 2024-01-02 02:04:05 ", "", time.Date(2024, 1, 2, 2, 4, 5, 0, time.UTC), true},
+		{"0000-00-00 00:00:00", "2024-01-02 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"", "2024-01-02 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"0000-00-00 00:00:00", "0000-00-00 00:00:00", time.Time{}, false},
+		{"yesterday", "", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := wxrTime(tt.gmt, tt.local)
+		if !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("wxrTime(%q, %q) = %v, %v; want %v, %v", tt.gmt, tt.local, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckScheme(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"http://example.com/a.jpg", true},
+		{"https://example.com/a.jpg", true},
+		{"file:///etc/passwd", false},
+		{"ftp://example.com/a.jpg", false},
+		{"gopher://example.com/", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := checkScheme(u) == nil; got != tt.want {
+			t.Errorf("checkScheme(%s) allowed = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+// Package jobs runs background work from a Postgres-backed queue.
+//
+// Jobs are typed by their payload: a payload type names its kind through
+// JobKind, handlers are registered per payload type with Register, and
+// Enqueue stores the payload as JSON. Failed jobs are retried with
+// exponential backoff and end up dead once they run out of attempts.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/pkg/cron"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotRetryable = errors.New("only dead jobs can be retried")
+	// ErrJobRunning is returned when replacing a job that is already
+	// running, whose payload and run time can no longer change.
+	ErrJobRunning = errors.New("a job with the same key is running")
+	// ErrKeyTaken is returned when retrying a job whose unique key another
+	// pending or running job holds.
+	ErrKeyTaken = errors.New("a job with the same key is pending or running")
+)
+
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// Permanent marks err as one retrying cannot fix, so the job goes straight
+// to the dead state.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Payload is the data a job runs with.
+type Payload interface {
+	JobKind() string
+}
+
+// Options adjust how a job is enqueued. The zero value runs the job as soon
+// as possible with the queue's default number of attempts.
+type Options struct {
+	// RunAt delays the job until the given time.
+	RunAt time.Time
+	// MaxAttempts overrides the queue default.
+	MaxAttempts int
+	// UniqueKey prevents a second job with the same key from being queued
+	// while one is pending or running; Enqueue returns the existing job.
+	UniqueKey string
+	// Replace updates the payload and run time of an existing pending job
+	// with the same UniqueKey instead of leaving it untouched. Enqueue fails
+	// with ErrJobRunning when that job is already running.
+	Replace bool
+}
+
+type handler struct {
+	decode func(payload []byte) (Payload, error)
+	run    func(ctx context.Context, job *models.Job, payload Payload) error
+}
+
+type recurring struct {
+	name     string
+	schedule cron.Schedule
+	payload  Payload
+}
+
+type Queue struct {
+	db        *gorm.DB
+	cfg       config.JobConfig
+	mu        sync.RWMutex
+	handlers  map[string]handler
+	recurring []recurring
+	wake      chan struct{}
+}
+
+func NewQueue(db *gorm.DB, cfg config.JobConfig) *Queue {
+	q := &Queue{
+		db:       db,
+		cfg:      cfg,
+		handlers: make(map[string]handler),
+		wake:     make(chan struct{}, 1),
+	}
+	Register(q, q.prune)
+	q.recurring = append(q.recurring, recurring{name: "jobs.prune", schedule: cron.MustParse("@daily"), payload: pruneJobs{}})
+	return q
+}
+
+// Register sets the handler for jobs with payload type P. The job row is
+// passed along so handlers can tell a final attempt from one that will be
+// retried.
+func Register[P Payload](q *Queue, fn func(ctx context.Context, job *models.Job, payload P) error) {
+	var zero P
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[zero.JobKind()] = handler{
+		decode: func(data []byte) (Payload, error) {
+			var payload P
+			err := json.Unmarshal(data, &payload)
+			return payload, err
+		},
+		run: func(ctx context.Context, job *models.Job, payload Payload) error {
+			return fn(ctx, job, payload.(P))
+		},
+	}
+}
+
+// Schedule enqueues payload on a recurring cron schedule. name identifies
+// the schedule across replicas and deploys; each activation is queued once
+// no matter how many workers are running.
+func (q *Queue) Schedule(name, spec string, payload Payload) error {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.recurring = append(q.recurring, recurring{name: name, schedule: schedule, payload: payload})
+	return nil
+}
+
+// Enqueue adds a job to the queue. Pass a transaction as tx to enqueue the
+// job only if the surrounding change commits.
+func (q *Queue) Enqueue(tx *gorm.DB, payload Payload, opts Options) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.Job{
+		Kind:        payload.JobKind(),
+		Payload:     string(data),
+		Status:      models.JobPending,
+		RunAt:       opts.RunAt,
+		MaxAttempts: opts.MaxAttempts,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.cfg.MaxAttempts
+	}
+
+	if opts.UniqueKey == "" {
+		if err := tx.Create(&job).Error; err != nil {
+			return nil, err
+		}
+		q.kick()
+		return &job, nil
+	}
+
+	job.UniqueKey = &opts.UniqueKey
+	onConflict := clause.OnConflict{
+		Columns:     []clause.Column{{Name: "unique_key"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status IN ('pending','running')"}}},
+		DoNothing:   true,
+	}
+	if opts.Replace {
+		onConflict.DoNothing = false
+		onConflict.DoUpdates = clause.AssignmentColumns([]string{"payload", "run_at", "max_attempts", "updated_at"})
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: "jobs", Name: "status"}, Value: models.JobPending}}}
+	}
+	result := tx.Clauses(onConflict).Create(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Another job holds the key.
+		var existing models.Job
+		err := tx.Where("unique_key = ? AND status IN ?", opts.UniqueKey, []models.JobStatus{models.JobPending, models.JobRunning}).
+			First(&existing).Error
+		if err != nil {
+			return nil, err
+		}
+		if opts.Replace && existing.Status == models.JobRunning {
+			return nil, ErrJobRunning
+		}
+		return &existing, nil
+	}
+	q.kick()
+	return &job, nil
+}
+
+// Retry queues a dead job for another round of attempts. It fails with
+// ErrKeyTaken while another job with the same unique key is pending or
+// running.
+func (q *Queue) Retry(id uint) (*models.Job, error) {
+	var job models.Job
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&job, id).Error; err != nil {
+			return err
+		}
+		if job.Status != models.JobDead {
+			return ErrNotRetryable
+		}
+		if job.UniqueKey != nil {
+			var holders int64
+			err := tx.Model(&models.Job{}).
+				Where("unique_key = ? AND status IN ?", *job.UniqueKey, []models.JobStatus{models.JobPending, models.JobRunning}).
+				Count(&holders).Error
+			if err != nil {
+				return err
+			}
+			if holders > 0 {
+				return ErrKeyTaken
+			}
+		}
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":      models.JobPending,
+			"attempts":    0,
+			"run_at":      time.Now(),
+			"locked_at":   nil,
+			"finished_at": nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.kick()
+	return &job, nil
+}
+
+func (q *Queue) kick() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works the queue until ctx is cancelled, running up to the configured
+// number of jobs at once and queueing recurring jobs as they come due.
+// Several replicas may run workers at once.
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+
+	slots := make(chan struct{}, q.cfg.Workers)
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	for {
+		if err := q.scheduleRecurring(ctx); err != nil {
+			log.Printf("jobs: scheduling recurring jobs failed: %v", err)
+		}
+		if err := q.reclaim(ctx); err != nil {
+			log.Printf("jobs: reclaiming stalled jobs failed: %v", err)
+		}
+
+		for {
+			free := cap(slots) - len(slots)
+			if free == 0 {
+				break
+			}
+			jobs, err := q.claim(ctx, free)
+			if err != nil {
+				log.Printf("jobs: claiming jobs failed: %v", err)
+				break
+			}
+			for i := range jobs {
+				slots <- struct{}{}
+				running.Add(1)
+				go func(job *models.Job) {
+					defer func() {
+						<-slots
+						running.Done()
+						q.kick()
+					}()
+					q.execute(ctx, job)
+				}(&jobs[i])
+			}
+			if len(jobs) < free {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// claim marks up to limit due jobs as running and returns them.
+func (q *Queue) claim(ctx context.Context, limit int) ([]models.Job, error) {
+	var jobs []models.Job
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", models.JobPending, time.Now()).
+			Order("run_at").Limit(limit).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(jobs))
+		now := time.Now()
+		for i := range jobs {
+			ids[i] = jobs[i].ID
+			jobs[i].Status = models.JobRunning
+			jobs[i].Attempts++
+			jobs[i].LockedAt = &now
+		}
+		return tx.Model(&models.Job{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":    models.JobRunning,
+			"attempts":  gorm.Expr("attempts + 1"),
+			"locked_at": now,
+		}).Error
+	})
+	return jobs, err
+}
+
+// reclaim returns jobs whose worker went away mid-run to the queue. A job
+// counts as stalled once it has been running for well over the job timeout.
+func (q *Queue) reclaim(ctx context.Context) error {
+	return q.db.WithContext(ctx).Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", models.JobRunning, time.Now().Add(-2*q.cfg.Timeout)).
+		Updates(map[string]interface{}{
+			"status":     models.JobPending,
+			"run_at":     time.Now(),
+			"locked_at":  nil,
+			"last_error": "worker stopped before the job finished",
+		}).Error
+}
+
+// execute runs one claimed job and records the outcome.
+func (q *Queue) execute(ctx context.Context, job *models.Job) {
+	runCtx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	defer cancel()
+
+	err := q.run(runCtx, job)
+	if ctx.Err() != nil {
+		// Shutting down: leave the job for reclaim rather than burning an
+		// attempt on the cancellation.
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"locked_at": nil}
+	var permanent permanentError
+	switch {
+	case err == nil:
+		updates["status"] = models.JobSucceeded
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts || errors.As(err, &permanent):
+		updates["status"] = models.JobDead
+		updates["finished_at"] = now
+		updates["last_error"] = err.Error()
+		log.Printf("jobs: %s job %d is dead after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+	default:
+		updates["status"] = models.JobPending
+		updates["run_at"] = now.Add(q.backoff(job.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	if err := q.db.Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		log.Printf("jobs: recording result of job %d failed: %v", job.ID, err)
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	q.mu.RLock()
+	h, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for %q", job.Kind))
+	}
+
+	payload, err := h.decode([]byte(job.Payload))
+	if err != nil {
+		return Permanent(fmt.Errorf("decoding payload: %w", err))
+	}
+	return h.run(ctx, job, payload)
+}
+
+// backoff is the delay before the retry following the given attempt,
+// doubling each time up to the configured ceiling.
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.cfg.RetryBase
+	for i := 1; i < attempts && delay < q.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.cfg.MaxBackoff {
+		delay = q.cfg.MaxBackoff
+	}
+	return delay
+}
+
+// scheduleRecurring queues the next activation of every recurring job.
+// Activations are keyed by schedule name and time, so replicas racing to
+// queue the same one end up with a single job.
+func (q *Queue) scheduleRecurring(ctx context.Context) error {
+	q.mu.RLock()
+	schedules := q.recurring
+	q.mu.RUnlock()
+
+	now := time.Now()
+	for _, r := range schedules {
+		next := r.schedule.Next(now)
+		if next.IsZero() {
+			continue
+		}
+		_, err := q.Enqueue(q.db.WithContext(ctx), r.payload, Options{
+			RunAt:     next,
+			UniqueKey: fmt.Sprintf("cron:%s:%d", r.name, next.Unix()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneJobs deletes succeeded jobs older than the configured retention.
+// Dead jobs are kept until an admin deals with them.
+type pruneJobs struct{}
+
+func (pruneJobs) JobKind() string { return "jobs.prune" }
+
+func (q *Queue) prune(ctx context.Context, _ *models.Job, _ pruneJobs) error {
+	return q.db.WithContext(ctx).
+		Where("status = ? AND finished_at < ?", models.JobSucceeded, time.Now().Add(-q.cfg.Retention)).
+		Delete(&models.Job{}).Error
+}
@@ -3,10 +3,13 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -25,21 +28,129 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := bearerToken[1]
-		claims := &jwt.RegisteredClaims{}
+		claims, err := parseToken(bearerToken[1], "")
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.JWTSecret), nil
-		})
+		authorize(c, claims)
+	}
+}
+
+// StreamAuthMiddleware authenticates event streams by the stream token in
+// the token query parameter, as EventSource cannot send an Authorization
+// header.
+func StreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Stream token required"})
+			c.Abort()
+			return
+		}
 
-		if err != nil || !token.Valid {
+		claims, err := parseToken(token, config.StreamTokenAudience)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Set user ID in context
-		c.Set("userID", claims.Subject)
+		authorize(c, claims)
+	}
+}
+
+// authorize lets the request through as the user the token was issued to,
+// unless they no longer exist or are banned or suspended.
+func authorize(c *gin.Context, claims *jwt.RegisteredClaims) {
+	db := c.MustGet("db").(*gorm.DB)
+	var user models.User
+	if err := db.First(&user, claims.Subject).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return
+	}
+
+	if user.BannedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account has been banned"})
+		c.Abort()
+		return
+	}
+	if user.IsSuspended(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":           "Account is suspended",
+			"suspended_until": user.SuspendedUntil,
+		})
+		c.Abort()
+		return
+	}
+
+	// Set user ID in context
+	c.Set("userID", claims.Subject)
+	c.Set("user", &user)
+	c.Next()
+}
+
+// OptionalAuthMiddleware identifies the user when a valid bearer token is
+// sent, but lets anonymous requests through. Public routes use it to
+// personalise responses.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearerToken := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(bearerToken) != 2 || strings.ToLower(bearerToken[0]) != "bearer" {
+			c.Next()
+			return
+		}
+
+		if claims, err := parseToken(bearerToken[1], ""); err == nil {
+			identify(c, claims)
+		}
+		c.Next()
+	}
+}
+
+// OptionalStreamAuthMiddleware is OptionalAuthMiddleware for public event
+// streams, identifying the user by the stream token in the token query
+// parameter, as EventSource cannot send an Authorization header.
+func OptionalStreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("token"); token != "" {
+			if claims, err := parseToken(token, config.StreamTokenAudience); err == nil {
+				identify(c, claims)
+			}
+		}
 		c.Next()
 	}
 }
+
+// identify marks the request as made by the user the token was issued to,
+// unless they no longer exist or are suspended.
+func identify(c *gin.Context, claims *jwt.RegisteredClaims) {
+	db := c.MustGet("db").(*gorm.DB)
+	var user models.User
+	if err := db.First(&user, claims.Subject).Error; err == nil && !user.IsSuspended(time.Now()) {
+		c.Set("userID", claims.Subject)
+		c.Set("user", &user)
+	}
+}
+
+// parseToken verifies a token issued for audience. Session tokens have no
+// audience, so tokens issued for one cannot stand in for them.
+func parseToken(tokenString, audience string) (*jwt.RegisteredClaims, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	if (audience == "" && len(claims.Audience) > 0) || (audience != "" && !claims.VerifyAudience(audience, true)) {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
+	return claims, nil
+}
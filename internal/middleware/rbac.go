@@ -5,22 +5,21 @@ import (
 
 	"github.com/Realwale/scribana/internal/models"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func RoleMiddleware(requiredRole models.Role) gin.HandlerFunc {
+// RoleMiddleware allows the request through when the user has one of the
+// given roles. Admins are always allowed.
+func RoleMiddleware(roles ...models.Role) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		db := c.MustGet("db").(*gorm.DB)
-		userID := c.GetString("userID")
-
-		var user models.User
-		if err := db.First(&user, userID).Error; err != nil {
+		value, _ := c.Get("user")
+		user, ok := value.(*models.User)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			c.Abort()
 			return
 		}
 
-		if user.Role != requiredRole && user.Role != models.AdminRole {
+		if !hasRole(user.Role, roles) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 			c.Abort()
 			return
@@ -29,3 +28,15 @@ func RoleMiddleware(requiredRole models.Role) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+func hasRole(role models.Role, allowed []models.Role) bool {
+	if role == models.AdminRole {
+		return true
+	}
+	for _, r := range allowed {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
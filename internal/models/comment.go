@@ -5,13 +5,39 @@ import (
 	"time"
 )
 
+type CommentStatus string
+
+const (
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentRejected CommentStatus = "rejected"
+	CommentSpam     CommentStatus = "spam"
+)
+
+func (s CommentStatus) Valid() bool {
+	switch s {
+	case CommentPending, CommentApproved, CommentRejected, CommentSpam:
+		return true
+	}
+	return false
+}
+
 type Comment struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Content   string         `gorm:"type:text" json:"content"`
-	PostID    uint           `json:"post_id"`
-	UserID    uint           `json:"user_id"`
-	User      User           `json:"user"`
+	ID            uint             `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt   `gorm:"index" json:"-"`
+	Content       string           `gorm:"type:text" json:"content"`
+	PostID        uint             `json:"post_id"`
+	ParentID      *uint            `gorm:"index" json:"parent_id,omitempty"`
+	UserID        uint             `json:"user_id"`
+	User          User             `json:"user"`
+	Status        CommentStatus    `gorm:"type:varchar(20);default:'approved';index" json:"status"`
+	ModeratedByID *uint            `json:"moderated_by_id,omitempty"`
+	ModeratedAt   *time.Time       `json:"moderated_at,omitempty"`
+	SpamScore     float64          `json:"-"`
+	SpamLabel     string           `gorm:"type:varchar(10)" json:"-"`
+	SpamTokens    []string         `gorm:"serializer:json" json:"-"`
+	Reactions     map[string]int64 `gorm:"-" json:"reactions"`
+	MyReactions   []string         `gorm:"-" json:"my_reactions,omitempty"`
 }
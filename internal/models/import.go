@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// ImportRecord maps an item of an external source, such as a WordPress
+// export, to the record it was imported as, so re-running an import skips
+// what is already there.
+type ImportRecord struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Source identifies where the item came from, e.g. "wordpress:https://example.com".
+	Source     string `gorm:"not null;uniqueIndex:idx_import_records_item" json:"source"`
+	Kind       string `gorm:"type:varchar(20);not null;uniqueIndex:idx_import_records_item" json:"kind"`
+	ExternalID string `gorm:"not null;uniqueIndex:idx_import_records_item" json:"external_id"`
+	LocalID    uint   `json:"local_id"`
+	// URL is where the item was stored, for imported files.
+	URL string `json:"url,omitempty"`
+}
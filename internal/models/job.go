@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	// JobDead jobs have used up their attempts and wait for an admin to
+	// retry or discard them.
+	JobDead JobStatus = "dead"
+)
+
+// Job is a unit of background work. Pending jobs are claimed by workers with
+// FOR UPDATE SKIP LOCKED, so any number of replicas can share the table.
+// UniqueKey, when set, is unique among pending and running jobs.
+type Job struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Kind        string     `gorm:"type:varchar(100);not null;index" json:"kind"`
+	Payload     string     `gorm:"type:text;not null" json:"payload"`
+	Status      JobStatus  `gorm:"type:varchar(20);default:'pending';index:idx_jobs_ready" json:"status"`
+	RunAt       time.Time  `gorm:"index:idx_jobs_ready" json:"run_at"`
+	Attempts    int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"default:10" json:"max_attempts"`
+	UniqueKey   *string    `gorm:"type:varchar(255);uniqueIndex:idx_jobs_unique,where:status IN ('pending'\\,'running')" json:"unique_key,omitempty"`
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+}
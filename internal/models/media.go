@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Media is an uploaded file in the media library.
+type Media struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	OwnerID   uint      `gorm:"index" json:"owner_id"`
+	Owner     *User     `json:"owner,omitempty"`
+	// Key locates the stored original; URL is where it is served from.
+	Key      string `gorm:"uniqueIndex;not null" json:"key"`
+	URL      string `gorm:"not null" json:"url"`
+	Filename string `json:"filename"`
+	MimeType string `gorm:"type:varchar(100)" json:"mime_type"`
+	Size     int64  `json:"size"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	// Duration is the playing time of audio, in seconds.
+	Duration int    `json:"duration,omitempty"`
+	AltText  string `gorm:"type:text" json:"alt_text"`
+	Caption  string `gorm:"type:text" json:"caption"`
+	// Checksum is the hex SHA-256 of the uploaded file.
+	Checksum  string         `gorm:"type:varchar(64);index" json:"checksum"`
+	Variants  []MediaVariant `gorm:"serializer:json" json:"variants"`
+	Thumbnail *MediaVariant  `gorm:"serializer:json" json:"thumbnail,omitempty"`
+	SrcSet    string         `gorm:"type:text" json:"srcset"`
+	// StoredSize is the bytes taken by the original, its variants and its
+	// thumbnail together, counted against the owner's quota.
+	StoredSize int64 `json:"stored_size"`
+	// Private media is kept out of the public uploads and served only from
+	// signed, expiring URLs. Its URLs are recorded unsigned.
+	Private bool `gorm:"default:false" json:"private"`
+}
+
+// IsAudio reports whether the media is an audio file.
+func (m *Media) IsAudio() bool {
+	return strings.HasPrefix(m.MimeType, "audio/")
+}
+
+// MediaVariant is a smaller rendition of an image, stored beside it.
+type MediaVariant struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+}
+
+// Keys lists the stored files of the media: the original, its variants and
+// its thumbnail.
+func (m *Media) Keys() []string {
+	keys := []string{m.Key}
+	for _, v := range m.Variants {
+		keys = append(keys, v.Key)
+	}
+	if m.Thumbnail != nil {
+		keys = append(keys, m.Thumbnail.Key)
+	}
+	return keys
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+type NotificationType string
+
+const (
+	NotifyMention       NotificationType = "mention"
+	NotifyCommentReply  NotificationType = "comment_reply"
+	NotifyPostComment   NotificationType = "post_comment"
+	NotifyPostPublished NotificationType = "post_published"
+)
+
+// NotificationTypes lists every notification type users can opt out of.
+var NotificationTypes = []NotificationType{
+	NotifyMention,
+	NotifyCommentReply,
+	NotifyPostComment,
+	NotifyPostPublished,
+}
+
+type Notification struct {
+	ID        uint             `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time        `json:"created_at"`
+	UserID    uint             `gorm:"index;not null" json:"user_id"`
+	ActorID   *uint            `json:"actor_id,omitempty"`
+	Actor     *User            `json:"-"`
+	Type      NotificationType `gorm:"type:varchar(30);not null" json:"type"`
+	PostID    *uint            `json:"post_id,omitempty"`
+	CommentID *uint            `json:"comment_id,omitempty"`
+	Message   string           `json:"message"`
+	ReadAt    *time.Time       `gorm:"index" json:"read_at,omitempty"`
+	// DedupeKey names the recipient, type and subject of the notification,
+	// so events delivered more than once notify once.
+	DedupeKey *string `gorm:"type:varchar(150);uniqueIndex" json:"-"`
+}
+
+// NotificationPreference records a user's choice for one notification
+// type. Types without a row are enabled.
+type NotificationPreference struct {
+	UserID  uint             `gorm:"primarykey" json:"-"`
+	Type    NotificationType `gorm:"primarykey;type:varchar(30)" json:"type"`
+	Enabled bool             `json:"enabled"`
+}
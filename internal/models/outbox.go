@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent is a domain event waiting to be handed to one asynchronous
+// subscriber. Rows are written in the same transaction as the change that
+// caused the event, so events are neither lost nor emitted for rolled-back
+// writes.
+type OutboxEvent struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Name          string     `gorm:"type:varchar(100);not null" json:"name"`
+	Subscriber    string     `gorm:"type:varchar(100);not null" json:"subscriber"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index:idx_outbox_pending" json:"next_attempt_at"`
+	DispatchedAt  *time.Time `gorm:"index:idx_outbox_pending" json:"dispatched_at,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+}
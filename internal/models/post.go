@@ -2,22 +2,138 @@ package models
 
 import (
 	"gorm.io/gorm"
+	"html"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/Realwale/scribana/pkg/markdown"
+)
+
+type PostStatus string
+
+const (
+	PostDraft     PostStatus = "draft"
+	PostPublished PostStatus = "published"
+	// PostScheduled posts are published automatically at PublishedAt.
+	PostScheduled PostStatus = "scheduled"
+)
+
+// PostFormat is the markup a post's content is written in.
+type PostFormat string
+
+const (
+	FormatHTML     PostFormat = "html"
+	FormatMarkdown PostFormat = "markdown"
 )
 
 type Post struct {
-	ID         uint           `gorm:"primarykey" json:"id"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
-	Title      string         `gorm:"not null" json:"title"`
-	Slug       string         `gorm:"unique;not null" json:"slug"`
-	Content    string         `gorm:"type:text" json:"content"`
-	ImageURL   string         `json:"image_url"`
-	AuthorID   uint           `json:"author_id"`
-	Author     User           `json:"author"`
-	CategoryID uint           `json:"category_id"`
-	Category   Category       `json:"category"`
-	Comments   []Comment      `json:"comments,omitempty"`
-	Likes      int            `gorm:"default:0" json:"likes"`
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	Title       string         `gorm:"not null" json:"title"`
+	Slug        string         `gorm:"unique;not null" json:"slug"`
+	Content     string         `gorm:"type:text" json:"content"`
+	Format      PostFormat     `gorm:"type:varchar(10);default:'html'" json:"format"`
+	Excerpt     string         `gorm:"type:text" json:"excerpt"`
+	ImageURL    string         `json:"image_url"`
+	MediaID     *uint          `gorm:"index" json:"media_id"`
+	Media       *Media         `gorm:"constraint:OnDelete:SET NULL" json:"media,omitempty"`
+	AudioID     *uint          `gorm:"index" json:"audio_id"`
+	Audio       *Media         `gorm:"constraint:OnDelete:SET NULL" json:"audio,omitempty"`
+	Episode     *int           `json:"episode,omitempty"`
+	AuthorID    uint           `json:"author_id"`
+	Author      User           `json:"author"`
+	CategoryID  uint           `json:"category_id"`
+	Category    Category       `json:"category"`
+	Tags        []Tag          `gorm:"many2many:post_tags" json:"tags"`
+	Comments    []Comment      `json:"comments,omitempty"`
+	Likes       int            `gorm:"default:0" json:"likes"`
+	Status      PostStatus     `gorm:"type:varchar(20);default:'published';index" json:"status"`
+	PublishedAt *time.Time     `gorm:"index" json:"published_at,omitempty"`
+	// MembersOnly posts are visible to signed-in users only, and are left
+	// out of the public site, feeds and sitemap.
+	MembersOnly bool `gorm:"default:false" json:"members_only"`
+	// Search and social metadata; empty fields fall back to the post's own
+	// title, summary and image.
+	MetaTitle       string           `json:"meta_title"`
+	MetaDescription string           `gorm:"type:text" json:"meta_description"`
+	CanonicalURL    string           `json:"canonical_url"`
+	NoIndex         bool             `gorm:"default:false" json:"noindex"`
+	SocialImage     string           `json:"social_image"`
+	SEO             *SEO             `gorm:"-" json:"seo,omitempty"`
+	Reactions       map[string]int64 `gorm:"-" json:"reactions"`
+	MyReactions     []string         `gorm:"-" json:"my_reactions,omitempty"`
+}
+
+// HTML is the post's content as sanitized HTML, rendering Markdown posts.
+func (p *Post) HTML() string {
+	if p.Format == FormatMarkdown {
+		return markdown.HTML(p.Content)
+	}
+	return markdown.Sanitize(p.Content)
+}
+
+// Path is the path of the post's public page.
+func (p *Post) Path() string {
+	return "/posts/" + p.Slug
+}
+
+// Published limits a post query to published posts, members-only ones
+// included.
+func Published(db *gorm.DB) *gorm.DB {
+	return db.Where("posts.status = ?", PostPublished)
+}
+
+// Public limits a post query to posts visible to the public: published
+// posts that are not members-only.
+func Public(db *gorm.DB) *gorm.DB {
+	return db.Scopes(Published).Where("posts.members_only = ?", false)
+}
+
+// VisibleTo limits a post query to posts the user with the given ID may
+// read, or to public posts for visitors, whose ID is 0.
+func VisibleTo(userID uint) func(*gorm.DB) *gorm.DB {
+	if userID == 0 {
+		return Public
+	}
+	return Published
+}
+
+// Publish marks the post as published, keeping the original publication
+// time if it was published before.
+func (p *Post) Publish() {
+	p.Status = PostPublished
+	now := time.Now()
+	if p.PublishedAt == nil || p.PublishedAt.After(now) {
+		p.PublishedAt = &now
+	}
+}
+
+// Schedule holds the post back until at, when it is published.
+func (p *Post) Schedule(at time.Time) {
+	p.Status = PostScheduled
+	p.PublishedAt = &at
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Summary returns the post's excerpt, or failing that the start of its
+// content as plain text, cut at a word boundary near max characters.
+func (p *Post) Summary(max int) string {
+	if p.Excerpt != "" {
+		return p.Excerpt
+	}
+
+	text := strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(p.HTML(), " "))), " ")
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	cut := string([]rune(text)[:max])
+	if i := strings.LastIndex(cut, " "); i > max/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
 }
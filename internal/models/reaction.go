@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+type Reaction struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint       `gorm:"uniqueIndex:idx_reaction_unique" json:"user_id"`
+	User       User       `json:"user"`
+	TargetType TargetType `gorm:"type:varchar(20);uniqueIndex:idx_reaction_unique;index:idx_reaction_target" json:"target_type"`
+	TargetID   uint       `gorm:"uniqueIndex:idx_reaction_unique;index:idx_reaction_target" json:"target_id"`
+	Kind       string     `gorm:"type:varchar(30);uniqueIndex:idx_reaction_unique" json:"reaction"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type ReportStatus string
+
+const (
+	ReportOpen      ReportStatus = "open"
+	ReportDismissed ReportStatus = "dismissed"
+	ReportResolved  ReportStatus = "resolved"
+)
+
+type Report struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ReporterID   uint           `gorm:"index" json:"reporter_id"`
+	Reporter     User           `json:"reporter"`
+	TargetType   TargetType     `gorm:"type:varchar(20);index:idx_report_target" json:"target_type"`
+	TargetID     uint           `gorm:"index:idx_report_target" json:"target_id"`
+	TargetUserID uint           `json:"target_user_id"`
+	Reason       string         `gorm:"type:varchar(30);not null" json:"reason"`
+	Details      string         `gorm:"type:text" json:"details"`
+	Status       ReportStatus   `gorm:"type:varchar(20);default:'open';index" json:"status"`
+	ResolvedByID *uint          `json:"resolved_by_id,omitempty"`
+	ResolvedAt   *time.Time     `json:"resolved_at,omitempty"`
+	Resolution   string         `gorm:"type:varchar(30)" json:"resolution,omitempty"`
+}
+
+type ModerationActionType string
+
+const (
+	ActionDismiss       ModerationActionType = "dismiss"
+	ActionRemoveContent ModerationActionType = "remove_content"
+	ActionWarn          ModerationActionType = "warn"
+	ActionSuspend       ModerationActionType = "suspend"
+	ActionBan           ModerationActionType = "ban"
+	ActionReinstate     ModerationActionType = "reinstate"
+)
+
+// ModerationAction is the audit log of actions moderators take against users
+// and their content.
+type ModerationAction struct {
+	ID          uint                 `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	ModeratorID uint                 `json:"moderator_id"`
+	UserID      uint                 `gorm:"index" json:"user_id"`
+	ReportID    *uint                `json:"report_id,omitempty"`
+	Action      ModerationActionType `gorm:"type:varchar(20);not null" json:"action"`
+	Reason      string               `gorm:"type:text" json:"reason"`
+	ExpiresAt   *time.Time           `json:"expires_at,omitempty"`
+}
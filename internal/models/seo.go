@@ -0,0 +1,23 @@
+package models
+
+// SEO is the search and social metadata of a page, computed when a post is
+// served rather than stored.
+type SEO struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	CanonicalURL string `json:"canonical_url"`
+	// Robots is the value of the robots meta tag, e.g. "index, follow".
+	Robots string `json:"robots"`
+	Image  string `json:"image,omitempty"`
+	// OpenGraph tags are rendered as <meta property="..." content="...">.
+	OpenGraph []MetaTag `json:"open_graph"`
+	// Twitter tags are rendered as <meta name="..." content="...">.
+	Twitter []MetaTag `json:"twitter"`
+	// JSONLD is a schema.org document for a <script type="application/ld+json"> tag.
+	JSONLD map[string]interface{} `json:"json_ld" swaggertype:"object"`
+}
+
+type MetaTag struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
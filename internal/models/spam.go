@@ -0,0 +1,14 @@
+package models
+
+// SpamToken stores how many spam and ham comments a token appeared in.
+type SpamToken struct {
+	Token string `gorm:"primarykey;size:64" json:"token"`
+	Spam  int    `gorm:"not null;default:0" json:"spam"`
+	Ham   int    `gorm:"not null;default:0" json:"ham"`
+}
+
+// SpamClass stores the number of comments trained as spam or ham.
+type SpamClass struct {
+	Name      string `gorm:"primarykey;size:10" json:"name"`
+	Documents int    `gorm:"not null;default:0" json:"documents"`
+}
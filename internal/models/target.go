@@ -0,0 +1,9 @@
+package models
+
+// TargetType identifies the kind of content a report or reaction refers to.
+type TargetType string
+
+const (
+	TargetPost    TargetType = "post"
+	TargetComment TargetType = "comment"
+)
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Upload is a resumable upload in progress, received in chunks through the
+// tus protocol. Once all Length bytes have arrived the file is added to the
+// media library as Media.
+type Upload struct {
+	ID        string    `gorm:"type:varchar(32);primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	OwnerID   uint      `gorm:"index" json:"owner_id"`
+	Filename  string    `json:"filename"`
+	// Length is the size of the whole file; Offset is how much of it has
+	// been received.
+	Length   int64             `json:"length"`
+	Offset   int64             `json:"offset"`
+	Metadata map[string]string `gorm:"serializer:json" json:"metadata"`
+	// Chunks are the keys of the stored parts received so far, in order.
+	Chunks []string `gorm:"serializer:json" json:"-"`
+	// ExpiresAt is when an unfinished upload is discarded.
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	MediaID   *uint     `json:"media_id,omitempty"`
+	Media     *Media    `gorm:"constraint:OnDelete:SET NULL" json:"media,omitempty"`
+}
+
+// Complete reports whether the whole file has been received.
+func (u *Upload) Complete() bool {
+	return u.Offset == u.Length
+}
@@ -8,20 +8,44 @@ import (
 type Role string
 
 const (
-	AdminRole  Role = "admin"
-	AuthorRole Role = "author"
-	ReaderRole Role = "reader"
+	AdminRole     Role = "admin"
+	ModeratorRole Role = "moderator"
+	AuthorRole    Role = "author"
+	ReaderRole    Role = "reader"
 )
 
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Username  string         `gorm:"unique;not null" json:"username"`
-	Password  string         `json:"-"`
-	Role      Role           `gorm:"type:varchar(20);default:'reader'" json:"role"`
-	Posts     []Post         `gorm:"foreignKey:AuthorID" json:"posts,omitempty"`
-	Comments  []Comment      `gorm:"foreignKey:UserID" json:"comments,omitempty"`
+	ID             uint           `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	Email          string         `gorm:"unique;not null" json:"email"`
+	Username       string         `gorm:"unique;not null" json:"username"`
+	Password       string         `json:"-"`
+	Role           Role           `gorm:"type:varchar(20);default:'reader'" json:"role"`
+	Posts          []Post         `gorm:"foreignKey:AuthorID" json:"posts,omitempty"`
+	Comments       []Comment      `gorm:"foreignKey:UserID" json:"comments,omitempty"`
+	SuspendedUntil *time.Time     `json:"suspended_until,omitempty"`
+	BannedAt       *time.Time     `json:"banned_at,omitempty"`
+}
+
+// CanModerate reports whether the user may moderate other users' content.
+func (u *User) CanModerate() bool {
+	return u.Role == AdminRole || u.Role == ModeratorRole
+}
+
+// IsSuspended reports whether the user is banned or currently suspended.
+func (u *User) IsSuspended(now time.Time) bool {
+	return u.BannedAt != nil || (u.SuspendedUntil != nil && u.SuspendedUntil.After(now))
+}
+
+// PublicUser is what anyone may see of a user.
+type PublicUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// Public returns what anyone may see of the user.
+func (u *User) Public() PublicUser {
+	return PublicUser{ID: u.ID, Username: u.Username}
 }
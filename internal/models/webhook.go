@@ -0,0 +1,70 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	EventPostPublished  = "post.published"
+	EventPostUpdated    = "post.updated"
+	EventPostDeleted    = "post.deleted"
+	EventCommentCreated = "comment.created"
+)
+
+// WebhookEvents lists the events webhooks can subscribe to.
+var WebhookEvents = []string{
+	EventPostPublished,
+	EventPostUpdated,
+	EventPostDeleted,
+	EventCommentCreated,
+}
+
+type Webhook struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	URL         string         `gorm:"not null" json:"url"`
+	Description string         `json:"description"`
+	Secret      string         `gorm:"not null" json:"-"`
+	Events      []string       `gorm:"serializer:json" json:"events"`
+	Active      bool           `gorm:"default:true" json:"active"`
+}
+
+// Subscribes reports whether the webhook wants event.
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one attempt-tracked delivery of an event to a webhook.
+// Deliveries are sent by background jobs; RedeliveryOf points at the
+// delivery a manual redelivery was copied from.
+type WebhookDelivery struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	WebhookID     uint           `gorm:"index;not null" json:"webhook_id"`
+	Event         string         `gorm:"type:varchar(50);not null" json:"event"`
+	Payload       string         `gorm:"type:text;not null" json:"payload"`
+	Status        DeliveryStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	Attempts      int            `gorm:"default:0" json:"attempts"`
+	LastAttemptAt *time.Time     `json:"last_attempt_at,omitempty"`
+	ResponseCode  int            `json:"response_code,omitempty"`
+	ResponseBody  string         `gorm:"type:text" json:"response_body,omitempty"`
+	Error         string         `gorm:"type:text" json:"error,omitempty"`
+	RedeliveryOf  *uint          `json:"redelivery_of,omitempty"`
+}
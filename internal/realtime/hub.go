@@ -0,0 +1,128 @@
+package realtime
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events to it are dropped.
+const subscriberBuffer = 32
+
+// Event is a message delivered to subscribers of a topic.
+type Event struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+	// Truncated is set when Data was too large to relay between replicas;
+	// clients should refetch the resource instead.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Relay forwards events published on this replica to the others.
+type Relay interface {
+	Forward(origin, topic string, event Event) error
+}
+
+// Hub is an in-process pub/sub hub. With a Relay attached, events published
+// on any replica reach subscribers on every replica.
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[chan Event]struct{}
+	origin string
+	relay  Relay
+}
+
+func NewHub() *Hub {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return &Hub{
+		topics: make(map[string]map[chan Event]struct{}),
+		origin: hex.EncodeToString(id),
+	}
+}
+
+// SetRelay attaches the relay used to reach other replicas.
+func (h *Hub) SetRelay(relay Relay) {
+	h.relay = relay
+}
+
+// SiteTopic carries site-wide notices between replicas, such as cached pages
+// going stale.
+const SiteTopic = "site"
+
+func PostTopic(postID uint) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+func UserTopic(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// Subscribe returns a channel receiving events published to topic and a
+// function that must be called to unsubscribe.
+func (h *Hub) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[chan Event]struct{})
+	}
+	h.topics[topic][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.topics[topic], ch)
+			if len(h.topics[topic]) == 0 {
+				delete(h.topics, topic)
+			}
+			close(ch)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Publish sends an event to every subscriber of topic. A nil hub discards
+// events, so callers outside the HTTP server need not provide one.
+func (h *Hub) Publish(topic, eventType string, data interface{}) {
+	if h == nil {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("realtime: failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	event := Event{Type: eventType, Data: payload}
+	h.dispatch(topic, event)
+
+	if h.relay != nil {
+		if err := h.relay.Forward(h.origin, topic, event); err != nil {
+			log.Printf("realtime: failed to relay %s event: %v", eventType, err)
+		}
+	}
+}
+
+// dispatch delivers an event to local subscribers without blocking on
+// slow ones.
+func (h *Hub) dispatch(topic string, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.topics[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
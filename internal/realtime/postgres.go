@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// channel is the Postgres notification channel shared by all replicas.
+const channel = "scribana_events"
+
+// maxPayload stays under Postgres' 8000 byte NOTIFY payload limit.
+const maxPayload = 7900
+
+type envelope struct {
+	Origin string `json:"origin"`
+	Topic  string `json:"topic"`
+	Event  Event  `json:"event"`
+}
+
+// PostgresRelay shares events between replicas through LISTEN/NOTIFY.
+type PostgresRelay struct {
+	db  *gorm.DB
+	dsn string
+	hub *Hub
+}
+
+func NewPostgresRelay(db *gorm.DB, dsn string, hub *Hub) *PostgresRelay {
+	return &PostgresRelay{db: db, dsn: dsn, hub: hub}
+}
+
+// Forward publishes an event for the other replicas to pick up.
+func (r *PostgresRelay) Forward(origin, topic string, event Event) error {
+	payload, err := json.Marshal(envelope{Origin: origin, Topic: topic, Event: event})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayload {
+		event.Data, event.Truncated = nil, true
+		if payload, err = json.Marshal(envelope{Origin: origin, Topic: topic, Event: event}); err != nil {
+			return err
+		}
+	}
+	return r.db.Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error
+}
+
+// Listen receives events from other replicas until ctx is cancelled,
+// reconnecting when the connection drops. The delay between attempts
+// grows while connecting fails and starts over once listening succeeds.
+func (r *PostgresRelay) Listen(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		listened, err := r.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if listened {
+			backoff = time.Second
+		}
+		log.Printf("realtime: listener disconnected, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// listen relays notifications until the connection fails, reporting
+// whether it got as far as listening.
+func (r *PostgresRelay) listen(ctx context.Context) (bool, error) {
+	conn, err := pgx.Connect(ctx, r.dsn)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return false, err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		var msg envelope
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			log.Printf("realtime: ignoring malformed notification: %v", err)
+			continue
+		}
+		if msg.Origin == r.hub.origin {
+			continue
+		}
+		r.hub.dispatch(msg.Topic, msg.Event)
+	}
+}
@@ -0,0 +1,163 @@
+// Package seo computes the search and social metadata of posts: meta tags,
+// Open Graph, Twitter Cards and schema.org JSON-LD.
+package seo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+)
+
+// DescriptionLength is how much of a post's summary is used as its
+// description when none is set.
+const DescriptionLength = 160
+
+type Builder struct {
+	Site config.SiteConfig
+}
+
+// Post computes the metadata of a post. Author, Category and Tags should be
+// preloaded; unset SEO fields fall back to the title, summary and image.
+func (b *Builder) Post(post *models.Post) *models.SEO {
+	title := firstNonEmpty(post.MetaTitle, post.Title)
+	description := firstNonEmpty(post.MetaDescription, post.Summary(DescriptionLength))
+	canonical := b.Site.URL(firstNonEmpty(post.CanonicalURL, post.Path()))
+	var image string
+	if src := firstNonEmpty(post.SocialImage, post.ImageURL); src != "" {
+		image = b.Site.URL(src)
+	}
+
+	robots := "index, follow"
+	if post.NoIndex || post.MembersOnly || post.Status != models.PostPublished {
+		robots = "noindex, follow"
+	}
+
+	meta := &models.SEO{
+		Title:        title,
+		Description:  description,
+		CanonicalURL: canonical,
+		Robots:       robots,
+		Image:        image,
+	}
+
+	var og tags
+	og.add("og:type", "article")
+	og.add("og:site_name", b.Site.Title)
+	og.add("og:locale", strings.ReplaceAll(b.Site.Language, "-", "_"))
+	og.add("og:title", title)
+	og.add("og:description", description)
+	og.add("og:url", canonical)
+	og.add("og:image", image)
+	if post.PublishedAt != nil {
+		og.add("article:published_time", timestamp(*post.PublishedAt))
+	}
+	og.add("article:modified_time", timestamp(post.UpdatedAt))
+	if post.Author.Username != "" {
+		og.add("article:author", b.Site.URL("/authors/"+post.Author.Username))
+	}
+	og.add("article:section", post.Category.Name)
+	for _, tag := range post.Tags {
+		og.add("article:tag", tag.Name)
+	}
+	meta.OpenGraph = og.compact()
+
+	card := "summary"
+	if image != "" {
+		card = "summary_large_image"
+	}
+	var twitter tags
+	twitter.add("twitter:card", card)
+	twitter.add("twitter:site", b.Site.Twitter)
+	twitter.add("twitter:title", title)
+	twitter.add("twitter:description", description)
+	twitter.add("twitter:image", image)
+	meta.Twitter = twitter.compact()
+
+	meta.JSONLD = b.blogPosting(post, meta)
+	return meta
+}
+
+// blogPosting builds the schema.org BlogPosting document of a post.
+func (b *Builder) blogPosting(post *models.Post, meta *models.SEO) map[string]interface{} {
+	doc := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         truncate(meta.Title, 110),
+		"description":      meta.Description,
+		"url":              meta.CanonicalURL,
+		"mainEntityOfPage": map[string]interface{}{"@type": "WebPage", "@id": meta.CanonicalURL},
+		"dateModified":     timestamp(post.UpdatedAt),
+		"publisher": map[string]interface{}{
+			"@type": "Organization",
+			"name":  b.Site.Title,
+			"url":   b.Site.URL("/"),
+		},
+	}
+	if b.Site.Language != "" {
+		doc["inLanguage"] = b.Site.Language
+	}
+	if post.PublishedAt != nil {
+		doc["datePublished"] = timestamp(*post.PublishedAt)
+	}
+	if meta.Image != "" {
+		doc["image"] = []string{meta.Image}
+	}
+	if post.Author.Username != "" {
+		doc["author"] = map[string]interface{}{
+			"@type": "Person",
+			"name":  post.Author.Username,
+			"url":   b.Site.URL("/authors/" + post.Author.Username),
+		}
+	}
+	if post.Category.Name != "" {
+		doc["articleSection"] = post.Category.Name
+	}
+	if len(post.Tags) > 0 {
+		names := make([]string, len(post.Tags))
+		for i, tag := range post.Tags {
+			names[i] = tag.Name
+		}
+		doc["keywords"] = strings.Join(names, ", ")
+	}
+	return doc
+}
+
+type tags []models.MetaTag
+
+func (t *tags) add(name, content string) {
+	*t = append(*t, models.MetaTag{Name: name, Content: content})
+}
+
+// compact drops tags without content.
+func (t tags) compact() []models.MetaTag {
+	out := make([]models.MetaTag, 0, len(t))
+	for _, tag := range t {
+		if tag.Content != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -13,11 +13,11 @@ import (
 )
 
 type AuthService struct {
-	Db *gorm.DB
+	db *gorm.DB
 }
 
 func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{Db: db}
+	return &AuthService{db: db}
 }
 
 func (s *AuthService) GenerateToken(user *models.User) (string, error) {
@@ -31,6 +31,22 @@ func (s *AuthService) GenerateToken(user *models.User) (string, error) {
 	return token.SignedString([]byte(config.JWTSecret))
 }
 
+// GenerateStreamToken returns a token that only opens the user's event
+// stream, along with when it expires.
+func (s *AuthService) GenerateStreamToken(user *models.User) (string, time.Time, error) {
+	expires := time.Now().Add(config.StreamTokenExpiresIn)
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.FormatUint(uint64(user.ID), 10),
+		Audience:  jwt.ClaimStrings{config.StreamTokenAudience},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(expires),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(config.JWTSecret))
+	return signed, expires, err
+}
+
 func (s *AuthService) HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -45,7 +61,7 @@ func (s *AuthService) VerifyPassword(hashedPassword, password string) error {
 
 func (s *AuthService) Authenticate(email, password string) (*models.User, error) {
 	var user models.User
-	if err := s.Db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
 		return nil, errors.New("invalid credentials")
 	}
 
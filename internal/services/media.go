@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/jobs"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/pkg/audio"
+	"github.com/Realwale/scribana/pkg/imaging"
+	"github.com/Realwale/scribana/pkg/storage"
+	"gorm.io/gorm"
+)
+
+var ErrMediaInUse = errors.New("media is used by posts")
+
+type MediaService struct {
+	db      *gorm.DB
+	storage storage.Storage
+	queue   *jobs.Queue
+	images  imaging.Options
+	gc      config.UploadGCConfig
+	uploads config.UploadConfig
+	private config.PrivateMediaConfig
+	signer  *storage.Signer
+	// privateErr explains why private media is unavailable, when it is.
+	privateErr error
+	// privateRefs matches links to private media in post content.
+	privateRefs *regexp.Regexp
+}
+
+func NewMediaService(db *gorm.DB, store storage.Storage, queue *jobs.Queue, cfg config.ImageConfig, gc config.UploadGCConfig, uploads config.UploadConfig, private config.PrivateMediaConfig) *MediaService {
+	s := &MediaService{db: db, storage: store, queue: queue, gc: gc, uploads: uploads, private: private, images: imaging.Options{
+		Widths:        cfg.Widths,
+		ThumbnailSize: cfg.ThumbnailSize,
+		MaxWidth:      cfg.MaxWidth,
+		Quality:       cfg.Quality,
+		MaxDimension:  cfg.MaxDimension,
+		MaxPixels:     cfg.MaxPixels,
+	}}
+	s.signer = storage.NewSigner([]byte(private.SigningKey), "/private")
+	if private.SigningKey == "" {
+		// A key of its own per process would break signed URLs on restarts
+		// and across replicas.
+		s.privateErr = fmt.Errorf("%w: MEDIA_SIGNING_KEY is not set", ErrPrivateUnavailable)
+		log.Printf("media: %v", s.privateErr)
+	}
+	s.privateRefs = privateRefsPattern(s.signer.BaseURL)
+	jobs.Register(queue, s.collectUploads)
+	jobs.Register(queue, s.expireUploads)
+	// The schedule is fixed and valid, so this cannot fail.
+	_ = queue.Schedule("uploads.expire", "@hourly", ExpireUploads{})
+	return s
+}
+
+// Upload stores an uploaded file and records it in the media library as
+// owned by ownerID. Images are stored with their variants; other accepted
+// files, such as audio, are stored as they are. size is the length of r,
+// or -1 when unknown. Private media is stored apart, under PrivatePrefix,
+// and its URLs need signing with SignMedia. It fails with
+// ErrPrivateUnavailable for private media when that is not set up, and with
+// ErrQuotaExceeded when the stored files, variants included, would take the
+// owner past their quota.
+func (s *MediaService) Upload(ctx context.Context, ownerID uint, filename string, r io.Reader, size int64, private bool) (*models.Media, error) {
+	return s.upload(ctx, ownerID, filename, r, size, private, "")
+}
+
+// upload is Upload for the resumable upload exceptUpload, whose reservation
+// the stored files take the place of, or for no upload when it is empty.
+func (s *MediaService) upload(ctx context.Context, ownerID uint, filename string, r io.Reader, size int64, private bool, exceptUpload string) (*models.Media, error) {
+	if private && s.privateErr != nil {
+		return nil, s.privateErr
+	}
+	media := models.Media{OwnerID: ownerID, Filename: path.Base(filename), Variants: []models.MediaVariant{}, Private: private}
+	store, dir := s.storage, ""
+	if private {
+		store, dir = privateStorage{Storage: s.storage, signer: s.signer}, PrivatePrefix
+	}
+	if imaging.FormatOf(path.Ext(filename)) != "" {
+		img, err := storage.SaveImage(ctx, store, dir, filename, r, s.images)
+		if err != nil {
+			return nil, err
+		}
+		setFile(&media, img.File)
+		media.Width = img.Width
+		media.Height = img.Height
+		media.SrcSet = img.SrcSet
+		for _, v := range img.Variants {
+			media.Variants = append(media.Variants, models.MediaVariant(v))
+		}
+		if img.Thumbnail != nil {
+			thumb := models.MediaVariant(*img.Thumbnail)
+			media.Thumbnail = &thumb
+		}
+	} else {
+		file, err := storage.SaveFile(ctx, store, dir, filename, r, size)
+		if err != nil {
+			return nil, err
+		}
+		setFile(&media, *file)
+		if media.IsAudio() {
+			s.probeDuration(ctx, &media)
+		}
+	}
+
+	media.StoredSize = media.Size
+	for _, v := range media.Variants {
+		media.StoredSize += v.Size
+	}
+	if media.Thumbnail != nil {
+		media.StoredSize += media.Thumbnail.Size
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.reserveQuota(tx, ownerID, media.StoredSize, exceptUpload); err != nil {
+			return err
+		}
+		return tx.Create(&media).Error
+	})
+	if err != nil {
+		s.removeFiles(&media)
+		return nil, err
+	}
+	return &media, nil
+}
+
+func setFile(media *models.Media, file storage.File) {
+	media.Key = file.Key
+	media.URL = file.URL
+	media.MimeType = file.ContentType
+	media.Size = file.Size
+	media.Checksum = file.Checksum
+}
+
+// probeDuration reads the playing time of stored audio. Formats whose
+// duration cannot be read are kept without one.
+func (s *MediaService) probeDuration(ctx context.Context, media *models.Media) {
+	r, err := s.storage.Open(ctx, media.Key)
+	if err != nil {
+		log.Printf("media: opening %s: %v", media.Key, err)
+		return
+	}
+	defer r.Close()
+
+	duration, err := audio.Duration(r, media.Size, path.Ext(media.Key))
+	if err != nil {
+		if !errors.Is(err, audio.ErrUnsupported) {
+			log.Printf("media: reading duration of %s: %v", media.Key, err)
+		}
+		return
+	}
+	media.Duration = int(duration.Round(time.Second) / time.Second)
+}
+
+// Delete removes media that no post uses, along with its stored files. A
+// post uses media it is attached to as well as media it links to or embeds,
+// found as the upload collector finds them.
+func (s *MediaService) Delete(ctx context.Context, media *models.Media) error {
+	var posts int64
+	if err := s.db.WithContext(ctx).Model(&models.Post{}).Where("media_id = ? OR audio_id = ?", media.ID, media.ID).Count(&posts).Error; err != nil {
+		return err
+	}
+	if posts > 0 {
+		return ErrMediaInUse
+	}
+
+	// Only posts mentioning the file are scanned for references to it.
+	stem := storage.Stem(media.Key)
+	mention := "%" + stem + "%"
+	referenced := map[string]bool{}
+	query := s.db.WithContext(ctx).Model(&models.Post{}).
+		Where("image_url LIKE ? OR social_image LIKE ? OR excerpt LIKE ? OR content LIKE ?", mention, mention, mention, mention)
+	if err := s.postReferences(query, referenced); err != nil {
+		return err
+	}
+	if referenced[stem] {
+		return ErrMediaInUse
+	}
+
+	if err := s.db.WithContext(ctx).Delete(media).Error; err != nil {
+		return err
+	}
+	s.removeFiles(media)
+	return nil
+}
+
+// removeFiles deletes the stored files of media. Failures are only logged:
+// the record is gone either way and the files are merely orphaned.
+func (s *MediaService) removeFiles(media *models.Media) {
+	for _, key := range media.Keys() {
+		if err := s.storage.Delete(context.Background(), key); err != nil {
+			log.Printf("media: deleting %s: %v", key, err)
+		}
+	}
+}
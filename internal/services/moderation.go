@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidStatus = errors.New("invalid comment status")
+
+type ModerationService struct {
+	db   *gorm.DB
+	cfg  config.ModerationConfig
+	spam *SpamService
+	bus  *events.Bus
+}
+
+func NewModerationService(db *gorm.DB, cfg config.ModerationConfig, spam *SpamService, bus *events.Bus) *ModerationService {
+	return &ModerationService{db: db, cfg: cfg, spam: spam, bus: bus}
+}
+
+// InitialStatus decides whether a comment written by user is published
+// straight away or held in the moderation queue.
+func (s *ModerationService) InitialStatus(user *models.User) (models.CommentStatus, error) {
+	if !s.cfg.RequireApproval {
+		return models.CommentApproved, nil
+	}
+
+	for _, role := range s.cfg.AutoApproveRoles {
+		if string(user.Role) == role {
+			return models.CommentApproved, nil
+		}
+	}
+
+	if s.cfg.TrustedAfter > 0 {
+		var approved int64
+		err := s.db.Model(&models.Comment{}).
+			Where("user_id = ? AND status = ?", user.ID, models.CommentApproved).
+			Count(&approved).Error
+		if err != nil {
+			return "", err
+		}
+		if approved >= int64(s.cfg.TrustedAfter) {
+			return models.CommentApproved, nil
+		}
+	}
+
+	return models.CommentPending, nil
+}
+
+// ListComments returns comments in the given status, oldest first so the
+// queue is worked through in submission order.
+func (s *ModerationService) ListComments(status models.CommentStatus, limit, offset int) ([]models.Comment, int64, error) {
+	var comments []models.Comment
+	var total int64
+
+	query := s.db.Model(&models.Comment{}).Where("status = ?", status)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("User").Order("created_at ASC").
+		Limit(limit).Offset(offset).Find(&comments).Error
+	return comments, total, err
+}
+
+// SetStatus moves the given comments to status on behalf of moderatorID and
+// returns the number of comments updated. Each decision also trains the
+// spam classifier.
+func (s *ModerationService) SetStatus(ids []uint, status models.CommentStatus, moderatorID uint) (int64, error) {
+	if !status.Valid() {
+		return 0, ErrInvalidStatus
+	}
+
+	var updated int64
+	var trained bool
+	err := s.bus.Transaction(func(tx *gorm.DB) error {
+		var comments []models.Comment
+		if err := tx.Where("id IN ?", ids).Find(&comments).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i := range comments {
+			comment := &comments[i]
+			wasApproved := comment.Status == models.CommentApproved
+			err := tx.Model(comment).Updates(map[string]interface{}{
+				"status":          status,
+				"moderated_by_id": moderatorID,
+				"moderated_at":    now,
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			retrained, err := s.spam.Retrain(tx, comment, LabelFor(status))
+			if err != nil {
+				return err
+			}
+			trained = trained || retrained
+
+			var event events.Event
+			if status == models.CommentApproved && !wasApproved {
+				event = events.CommentPublished{CommentID: comment.ID, PostID: comment.PostID}
+			} else if status != models.CommentApproved && wasApproved {
+				event = events.CommentRemoved{CommentID: comment.ID, PostID: comment.PostID}
+			}
+			if event != nil {
+				if err := s.bus.Publish(tx, event); err != nil {
+					return err
+				}
+			}
+			updated++
+		}
+
+		if !trained {
+			return nil
+		}
+		return s.bus.Publish(tx, events.SpamTrained{})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/realtime"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// ParseMentions returns the distinct usernames mentioned as @username in text.
+func ParseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username != "" && !seen[strings.ToLower(username)] {
+			seen[strings.ToLower(username)] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+type NotificationService struct {
+	db  *gorm.DB
+	hub *realtime.Hub
+}
+
+func NewNotificationService(db *gorm.DB, hub *realtime.Hub) *NotificationService {
+	return &NotificationService{db: db, hub: hub}
+}
+
+// CommentPublished notifies the post author, the author of the comment
+// being replied to and anyone mentioned. Each user receives at most one
+// notification per comment.
+func (s *NotificationService) CommentPublished(comment *models.Comment) error {
+	var post models.Post
+	if err := s.db.Select("id", "title", "author_id").First(&post, comment.PostID).Error; err != nil {
+		return err
+	}
+	var actor models.User
+	if err := s.db.First(&actor, comment.UserID).Error; err != nil {
+		return err
+	}
+
+	notified := map[uint]bool{actor.ID: true}
+	notify := func(userID uint, kind models.NotificationType, message string) error {
+		if notified[userID] {
+			return nil
+		}
+		notified[userID] = true
+		return s.notify(models.Notification{
+			UserID:    userID,
+			ActorID:   &actor.ID,
+			Type:      kind,
+			PostID:    &post.ID,
+			CommentID: &comment.ID,
+			Message:   message,
+		})
+	}
+
+	if comment.ParentID != nil {
+		var parent models.Comment
+		if err := s.db.Select("id", "user_id").First(&parent, *comment.ParentID).Error; err == nil {
+			msg := fmt.Sprintf("%s replied to your comment on %q", actor.Username, post.Title)
+			if err := notify(parent.UserID, models.NotifyCommentReply, msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	mentioned, err := s.mentionedUsers(comment.Content)
+	if err != nil {
+		return err
+	}
+	for _, user := range mentioned {
+		msg := fmt.Sprintf("%s mentioned you in a comment on %q", actor.Username, post.Title)
+		if err := notify(user.ID, models.NotifyMention, msg); err != nil {
+			return err
+		}
+	}
+
+	msg := fmt.Sprintf("%s commented on your post %q", actor.Username, post.Title)
+	return notify(post.AuthorID, models.NotifyPostComment, msg)
+}
+
+// PostPublished notifies the author when someone else publishes their post,
+// and anyone mentioned in it. actorID is 0 when the post was published by
+// the system rather than a user.
+func (s *NotificationService) PostPublished(post *models.Post, actorID uint) error {
+	var actor *uint
+	if actorID != 0 {
+		actor = &actorID
+	}
+
+	notified := map[uint]bool{post.AuthorID: true}
+	if actorID != post.AuthorID {
+		err := s.notify(models.Notification{
+			UserID:  post.AuthorID,
+			ActorID: actor,
+			Type:    models.NotifyPostPublished,
+			PostID:  &post.ID,
+			Message: fmt.Sprintf("Your post %q has been published", post.Title),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	mentioned, err := s.mentionedUsers(post.Content)
+	if err != nil {
+		return err
+	}
+	for _, user := range mentioned {
+		if notified[user.ID] {
+			continue
+		}
+		notified[user.ID] = true
+		err := s.notify(models.Notification{
+			UserID:  user.ID,
+			ActorID: &post.AuthorID,
+			Type:    models.NotifyMention,
+			PostID:  &post.ID,
+			Message: fmt.Sprintf("You were mentioned in %q", post.Title),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) mentionedUsers(text string) ([]models.User, error) {
+	usernames := ParseMentions(text)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	lowered := make([]string, len(usernames))
+	for i, username := range usernames {
+		lowered[i] = strings.ToLower(username)
+	}
+
+	var users []models.User
+	err := s.db.Where("LOWER(username) IN ?", lowered).Find(&users).Error
+	return users, err
+}
+
+// notify stores n unless the recipient has turned that type off or already
+// has it, as happens when an event is redelivered.
+func (s *NotificationService) notify(n models.Notification) error {
+	var pref models.NotificationPreference
+	err := s.db.Where("user_id = ? AND type = ?", n.UserID, n.Type).Limit(1).Find(&pref).Error
+	if err != nil {
+		return err
+	}
+	if pref.UserID != 0 && !pref.Enabled {
+		return nil
+	}
+	n.DedupeKey = dedupeKey(&n)
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&n)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	unread, err := s.UnreadCount(n.UserID)
+	if err != nil {
+		return err
+	}
+	s.hub.Publish(realtime.UserTopic(n.UserID), "notification", map[string]interface{}{
+		"notification": n,
+		"unread_count": unread,
+	})
+	return nil
+}
+
+// dedupeKey identifies n among its recipient's notifications by type and
+// the comment or post it is about.
+func dedupeKey(n *models.Notification) *string {
+	var subject string
+	switch {
+	case n.CommentID != nil:
+		subject = fmt.Sprintf("comment:%d", *n.CommentID)
+	case n.PostID != nil:
+		subject = fmt.Sprintf("post:%d", *n.PostID)
+	default:
+		return nil
+	}
+	key := fmt.Sprintf("%d:%s:%s", n.UserID, n.Type, subject)
+	return &key
+}
+
+// NotificationEntry is a notification as listed to its recipient, with
+// only what anyone may see of its actor.
+type NotificationEntry struct {
+	models.Notification
+	Actor *models.PublicUser `json:"actor,omitempty"`
+}
+
+// List returns the user's notifications, newest first.
+func (s *NotificationService) List(userID uint, unreadOnly bool, limit, offset int) ([]NotificationEntry, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	err := query.Preload("Actor").Order("created_at DESC").
+		Limit(limit).Offset(offset).Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]NotificationEntry, len(notifications))
+	for i, n := range notifications {
+		entries[i].Notification = n
+		if n.Actor != nil {
+			actor := n.Actor.Public()
+			entries[i].Actor = &actor
+		}
+	}
+	return entries, nil
+}
+
+func (s *NotificationService) UnreadCount(userID uint) (int64, error) {
+	var count int64
+	err := s.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// MarkRead marks one of the user's notifications as read. It reports false
+// if the notification does not belong to the user.
+func (s *NotificationService) MarkRead(userID, id uint) (bool, error) {
+	result := s.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *NotificationService) MarkAllRead(userID uint) (int64, error) {
+	result := s.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// Preferences returns whether each notification type is enabled for the user.
+func (s *NotificationService) Preferences(userID uint) (map[models.NotificationType]bool, error) {
+	var stored []models.NotificationPreference
+	if err := s.db.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[models.NotificationType]bool, len(models.NotificationTypes))
+	for _, kind := range models.NotificationTypes {
+		prefs[kind] = true
+	}
+	for _, pref := range stored {
+		prefs[pref.Type] = pref.Enabled
+	}
+	return prefs, nil
+}
+
+// SetPreferences stores the user's choices; types not in prefs are unchanged.
+func (s *NotificationService) SetPreferences(userID uint, prefs map[models.NotificationType]bool) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for kind, enabled := range prefs {
+			pref := models.NotificationPreference{UserID: userID, Type: kind, Enabled: enabled}
+			if err := tx.Save(&pref).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/jobs"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PostService struct {
+	db    *gorm.DB
+	bus   *events.Bus
+	queue *jobs.Queue
+}
+
+func NewPostService(db *gorm.DB, bus *events.Bus, queue *jobs.Queue) *PostService {
+	s := &PostService{db: db, bus: bus, queue: queue}
+	jobs.Register(queue, s.publishScheduled)
+	return s
+}
+
+// ResolveTags returns the tags with the given names, creating any that do
+// not exist yet. Names that differ only in case or punctuation map to the
+// same tag.
+func (s *PostService) ResolveTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		tagSlug := slug.Make(name)
+		if tagSlug == "" || seen[tagSlug] {
+			continue
+		}
+		seen[tagSlug] = true
+
+		tag := models.Tag{Name: name, Slug: tagSlug}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("slug = ?", tagSlug).First(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// PublishPost is the job publishing a scheduled post.
+type PublishPost struct {
+	PostID uint `json:"post_id"`
+}
+
+func (PublishPost) JobKind() string { return "posts.publish" }
+
+// SchedulePublish queues the publication of a scheduled post at its
+// PublishedAt time, moving any publication already queued for it. It fails
+// with jobs.ErrJobRunning while the post's publication is running.
+func (s *PostService) SchedulePublish(tx *gorm.DB, post *models.Post) error {
+	_, err := s.queue.Enqueue(tx, PublishPost{PostID: post.ID}, jobs.Options{
+		RunAt:     *post.PublishedAt,
+		UniqueKey: fmt.Sprintf("posts.publish:%d", post.ID),
+		Replace:   true,
+	})
+	return err
+}
+
+// publishScheduled publishes a post whose scheduled time has come. Posts
+// that were unscheduled or rescheduled in the meantime are left alone.
+func (s *PostService) publishScheduled(ctx context.Context, _ *models.Job, p PublishPost) error {
+	return s.bus.Transaction(func(tx *gorm.DB) error {
+		var post models.Post
+		err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, p.PostID).Error
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		if post.Status != models.PostScheduled || post.PublishedAt == nil || post.PublishedAt.After(time.Now()) {
+			return nil
+		}
+
+		post.Status = models.PostPublished
+		if err := tx.Save(&post).Error; err != nil {
+			return err
+		}
+		return s.bus.Publish(tx, events.PostPublished{PostID: post.ID})
+	})
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/pkg/storage"
+)
+
+// PrivatePrefix is where private media is stored. It is kept out of the
+// public uploads route and served only from signed, expiring URLs.
+const PrivatePrefix = "private/"
+
+// ErrPrivateUnavailable is returned for private media when it cannot be
+// served safely, as no signing key is configured.
+var ErrPrivateUnavailable = errors.New("private media is not available")
+
+// privateStorage stores private media, giving it the unsigned URLs of the
+// signer, which are recorded and signed whenever the media is handed out.
+type privateStorage struct {
+	storage.Storage
+	signer *storage.Signer
+}
+
+func (p privateStorage) URL(key string) string {
+	return p.signer.URL(strings.TrimPrefix(key, PrivatePrefix))
+}
+
+// CheckPrivate checks that the storage does not serve private media to
+// anyone itself, as a bucket serving its whole contents would. Private media
+// is refused with ErrPrivateUnavailable unless the check passes.
+func (s *MediaService) CheckPrivate(ctx context.Context) {
+	if s.privateErr != nil {
+		return
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if err := storage.CheckPrivate(ctx, s.storage, PrivatePrefix, client); err != nil {
+		s.privateErr = fmt.Errorf("%w: %v", ErrPrivateUnavailable, err)
+		log.Printf("media: %v", s.privateErr)
+	}
+}
+
+// PrivateURL is the URL private media is served under, as recorded before
+// signing.
+func (s *MediaService) PrivateURL() string {
+	return s.signer.BaseURL
+}
+
+// SignMedia replaces the URLs of private media with signed ones, valid for
+// the configured expiry. Only hand the result to users allowed to see the
+// media. Public media is left as is.
+func (s *MediaService) SignMedia(media *models.Media) {
+	s.signMedia(media, s.urlExpiry(s.private.URLExpiry, time.Minute))
+}
+
+// SignPost signs the URLs of the private media a post uses: its images and
+// audio, and any private media linked from its content. Links signed
+// earlier, such as those copied from the media library, are signed afresh.
+// Only call it for users allowed to see the post.
+func (s *MediaService) SignPost(post *models.Post) {
+	s.signPost(post, s.urlExpiry(s.private.URLExpiry, time.Minute))
+}
+
+// SignFeedPost is SignPost for feeds, whose readers and podcast apps fetch
+// media long after reading the feed. The URLs are valid for the configured
+// feed expiry and change once an hour at most.
+func (s *MediaService) SignFeedPost(post *models.Post) {
+	s.signPost(post, s.urlExpiry(s.private.FeedURLExpiry, time.Hour))
+}
+
+func (s *MediaService) signMedia(media *models.Media, expires time.Time) {
+	if media == nil || !media.Private || s.privateErr != nil {
+		return
+	}
+	sign := func(key string) string {
+		name := strings.TrimPrefix(key, PrivatePrefix)
+		signed := s.signer.Sign(name, expires)
+		media.SrcSet = strings.ReplaceAll(media.SrcSet, s.signer.URL(name)+" ", signed+" ")
+		return signed
+	}
+
+	media.URL = sign(media.Key)
+	for i := range media.Variants {
+		media.Variants[i].URL = sign(media.Variants[i].Key)
+	}
+	if media.Thumbnail != nil {
+		thumb := *media.Thumbnail
+		thumb.URL = sign(thumb.Key)
+		media.Thumbnail = &thumb
+	}
+}
+
+func (s *MediaService) signPost(post *models.Post, expires time.Time) {
+	if s.privateErr != nil {
+		return
+	}
+	s.signMedia(post.Media, expires)
+	s.signMedia(post.Audio, expires)
+	sign := func(match string) string { return s.signURL(match, expires) }
+	post.ImageURL = s.privateRefs.ReplaceAllStringFunc(post.ImageURL, sign)
+	post.SocialImage = s.privateRefs.ReplaceAllStringFunc(post.SocialImage, sign)
+	post.Content = s.privateRefs.ReplaceAllStringFunc(post.Content, sign)
+}
+
+// signURL signs a link to private media matched by privateRefs.
+func (s *MediaService) signURL(match string, expires time.Time) string {
+	i, name := s.privateName(match)
+	return match[:i] + s.signer.Sign(name, expires)
+}
+
+// privateName returns the file name in a link to private media matched by
+// privateRefs, and where the link starts in match.
+func (s *MediaService) privateName(match string) (int, string) {
+	i := strings.Index(match, s.signer.URL(""))
+	name, _, _ := strings.Cut(match[i+len(s.signer.URL("")):], "?")
+	return i, name
+}
+
+// urlExpiry is when URLs signed now to be valid for ttl expire. It is
+// rounded up to round, so URLs signed moments apart match and stay
+// cacheable.
+func (s *MediaService) urlExpiry(ttl, round time.Duration) time.Time {
+	return time.Now().Add(ttl + round).Truncate(round)
+}
+
+// OpenPrivate verifies a signed URL of private media by its file name,
+// expires and signature parameters and opens the file it grants. It fails
+// with storage.ErrBadSignature, storage.ErrURLExpired or
+// storage.ErrNotFound, or with ErrPrivateUnavailable.
+func (s *MediaService) OpenPrivate(ctx context.Context, name, expires, signature string) (io.ReadCloser, *storage.Object, time.Time, error) {
+	if s.privateErr != nil {
+		return nil, nil, time.Time{}, s.privateErr
+	}
+	until, err := s.signer.Verify(name, expires, signature)
+	if err != nil {
+		return nil, nil, until, err
+	}
+	// Private media is stored flat under PrivatePrefix.
+	if strings.Contains(name, "/") {
+		return nil, nil, until, storage.ErrNotFound
+	}
+	obj, err := s.storage.Stat(ctx, PrivatePrefix+name)
+	if err != nil {
+		return nil, nil, until, err
+	}
+	r, err := s.storage.Open(ctx, obj.Key)
+	if err != nil {
+		return nil, nil, until, err
+	}
+	return r, obj, until, nil
+}
+
+// privateRefsPattern matches links to private media served under base,
+// along with any signature, which may be HTML-escaped. Paths of other
+// hosts are told apart by the character before them.
+func privateRefsPattern(base string) *regexp.Regexp {
+	return regexp.MustCompile(`(?:^|[^A-Za-z0-9._~:/\-])` + regexp.QuoteMeta(base+"/") +
+		`[A-Za-z0-9._\-]+(\?expires=[0-9]+&(amp;)?signature=[0-9a-f]+)?`)
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded is returned for uploads that would take a user past
+// their storage quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// storedBytes sums the storage taken by media. Media stored before sizes
+// were recorded count their original only.
+const storedBytes = "COALESCE(SUM(CASE WHEN stored_size > 0 THEN stored_size ELSE size END), 0)"
+
+// StorageUsage is the storage a user fills.
+type StorageUsage struct {
+	UserID   uint        `json:"user_id"`
+	Username string      `json:"username,omitempty"`
+	Role     models.Role `json:"role,omitempty"`
+	Files    int64       `json:"files"`
+	// Used is the bytes stored in the media library; Pending is the bytes
+	// reserved by unfinished resumable uploads.
+	Used    int64 `json:"used"`
+	Pending int64 `json:"pending"`
+	// Quota is 0 for users without one, who have no Remaining either.
+	Quota     int64  `json:"quota"`
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+func (u *StorageUsage) setQuota(quota int64) {
+	u.Quota = quota
+	u.Remaining = nil
+	if quota > 0 {
+		remaining := max(quota-u.Used-u.Pending, 0)
+		u.Remaining = &remaining
+	}
+}
+
+// fits reports whether size more bytes stay within the quota.
+func (u *StorageUsage) fits(size int64) bool {
+	return u.Remaining == nil || size <= *u.Remaining
+}
+
+// StorageReport lists the storage users fill, largest first.
+type StorageReport struct {
+	Users []StorageUsage `gorm:"-" json:"users"`
+	// Total counts the users storing anything.
+	Total   int64 `json:"total"`
+	Files   int64 `json:"files"`
+	Used    int64 `json:"used"`
+	Pending int64 `json:"pending"`
+}
+
+// Quota is the storage each user with the given role may fill, or 0 when
+// it is not limited.
+func (s *MediaService) Quota(role models.Role) int64 {
+	return s.uploads.Quota[string(role)]
+}
+
+// Usage reports the storage a user fills and what remains of their quota.
+func (s *MediaService) Usage(ctx context.Context, user *models.User) (*StorageUsage, error) {
+	return s.usage(s.db.WithContext(ctx), user, "")
+}
+
+// usage is Usage read through db, not counting the reservation of the
+// upload exceptUpload.
+func (s *MediaService) usage(db *gorm.DB, user *models.User, exceptUpload string) (*StorageUsage, error) {
+	var usage StorageUsage
+	err := db.Model(&models.Media{}).Select("COUNT(*) AS files, "+storedBytes+" AS used").
+		Where("owner_id = ?", user.ID).Scan(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	usage.UserID, usage.Username, usage.Role = user.ID, user.Username, user.Role
+	err = pendingUploads(db).Select("COALESCE(SUM(length), 0)").
+		Where("owner_id = ? AND id <> ?", user.ID, exceptUpload).Scan(&usage.Pending).Error
+	if err != nil {
+		return nil, err
+	}
+	usage.setQuota(s.Quota(user.Role))
+	return &usage, nil
+}
+
+// checkQuota fails with ErrQuotaExceeded when size more bytes would take
+// user past their quota. It lets uploads be refused before they are read;
+// reserveQuota enforces the quota as they are stored.
+func (s *MediaService) checkQuota(ctx context.Context, user *models.User, size int64) error {
+	if s.Quota(user.Role) == 0 {
+		return nil
+	}
+	usage, err := s.Usage(ctx, user)
+	if err != nil {
+		return err
+	}
+	if !usage.fits(size) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// reserveQuota fails with ErrQuotaExceeded when storing size more bytes
+// would take the owner past their quota. It locks the owner's row until tx
+// ends, so the uploads of one user are checked and stored one at a time,
+// each seeing what the previous ones took. The reservation of the upload
+// exceptUpload, which is being completed, is not counted.
+func (s *MediaService) reserveQuota(tx *gorm.DB, ownerID uint, size int64, exceptUpload string) error {
+	var owner models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&owner, ownerID).Error; err != nil {
+		return err
+	}
+	if s.Quota(owner.Role) == 0 {
+		return nil
+	}
+	usage, err := s.usage(tx, &owner, exceptUpload)
+	if err != nil {
+		return err
+	}
+	if !usage.fits(size) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// StorageReport reports the storage filled by each user storing anything,
+// optionally only those with the given role.
+func (s *MediaService) StorageReport(ctx context.Context, role models.Role, limit, offset int) (*StorageReport, error) {
+	db := s.db.WithContext(ctx)
+	media := db.Model(&models.Media{}).
+		Select("owner_id, COUNT(*) AS files, " + storedBytes + " AS used").Group("owner_id")
+	pending := pendingUploads(db).Select("owner_id, SUM(length) AS pending").Group("owner_id")
+	query := db.Table("users").
+		Joins("LEFT JOIN (?) AS m ON m.owner_id = users.id", media).
+		Joins("LEFT JOIN (?) AS p ON p.owner_id = users.id", pending).
+		Where("m.owner_id IS NOT NULL OR p.owner_id IS NOT NULL")
+	if role != "" {
+		query = query.Where("users.role = ?", role)
+	}
+	query = query.Session(&gorm.Session{})
+
+	report := StorageReport{Users: []StorageUsage{}}
+	err := query.Select("COUNT(*) AS total, COALESCE(SUM(m.files), 0) AS files, " +
+		"COALESCE(SUM(m.used), 0) AS used, COALESCE(SUM(p.pending), 0) AS pending").
+		Scan(&report).Error
+	if err != nil {
+		return nil, err
+	}
+	err = query.Select("users.id AS user_id, users.username, users.role, " +
+		"COALESCE(m.files, 0) AS files, COALESCE(m.used, 0) AS used, COALESCE(p.pending, 0) AS pending").
+		Order("COALESCE(m.used, 0) + COALESCE(p.pending, 0) DESC, users.id").Limit(limit).Offset(offset).
+		Scan(&report.Users).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Users {
+		report.Users[i].setQuota(s.Quota(report.Users[i].Role))
+	}
+	return &report, nil
+}
+
+// pendingUploads selects the unfinished resumable uploads that have not
+// expired, whose declared length is reserved against their owner's quota.
+func pendingUploads(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Upload{}).
+		Where("media_id IS NULL AND expires_at > ?", time.Now())
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+)
+
+func TestStorageUsageQuota(t *testing.T) {
+	const mb = 1 << 20
+	tests := []struct {
+		name          string
+		used, pending int64
+		quota         int64
+		size          int64
+		// wantRemaining is -1 for no remaining, as for users without a
+		// quota.
+		wantRemaining int64
+		wantFits      bool
+	}{
+		{"no quota", 500 * mb, 100 * mb, 0, 1 << 40, -1, true},
+		{"empty", 0, 0, 10 * mb, 10 * mb, 10 * mb, true},
+		{"room left", 3 * mb, 0, 10 * mb, 7 * mb, 7 * mb, true},
+		{"one byte over", 3 * mb, 0, 10 * mb, 7*mb + 1, 7 * mb, false},
+		{"pending uploads reserved", 3 * mb, 5 * mb, 10 * mb, 3 * mb, 2 * mb, false},
+		{"pending uploads leave room", 3 * mb, 5 * mb, 10 * mb, 2 * mb, 2 * mb, true},
+		{"full", 10 * mb, 0, 10 * mb, 1, 0, false},
+		{"full but empty upload", 10 * mb, 0, 10 * mb, 0, 0, true},
+		{"over after a lowered quota", 12 * mb, 1 * mb, 10 * mb, 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := StorageUsage{Used: tt.used, Pending: tt.pending}
+			usage.setQuota(tt.quota)
+			if usage.Quota != tt.quota {
+				t.Errorf("Quota = %d, want %d", usage.Quota, tt.quota)
+			}
+			switch {
+			case tt.wantRemaining < 0 && usage.Remaining != nil:
+				t.Errorf("Remaining = %d, want none", *usage.Remaining)
+			case tt.wantRemaining >= 0 && usage.Remaining == nil:
+				t.Errorf("Remaining = none, want %d", tt.wantRemaining)
+			case tt.wantRemaining >= 0 && *usage.Remaining != tt.wantRemaining:
+				t.Errorf("Remaining = %d, want %d", *usage.Remaining, tt.wantRemaining)
+			}
+			if got := usage.fits(tt.size); got != tt.wantFits {
+				t.Errorf("fits(%d) = %v, want %v", tt.size, got, tt.wantFits)
+			}
+		})
+	}
+}
+
+func TestStorageUsageQuotaChanged(t *testing.T) {
+	usage := StorageUsage{Used: 4}
+	usage.setQuota(10)
+	usage.setQuota(0)
+	if usage.Remaining != nil || !usage.fits(100) {
+		t.Errorf("lifting the quota kept a limit: %+v", usage)
+	}
+}
+
+func TestQuotaByRole(t *testing.T) {
+	s := &MediaService{uploads: config.UploadConfig{Quota: map[string]int64{
+		string(models.AuthorRole): 1 << 30,
+		string(models.ReaderRole): 50 << 20,
+	}}}
+	tests := map[models.Role]int64{
+		models.AuthorRole: 1 << 30,
+		models.ReaderRole: 50 << 20,
+		models.AdminRole:  0,
+	}
+	for role, want := range tests {
+		if got := s.Quota(role); got != want {
+			t.Errorf("Quota(%q) = %d, want %d", role, got, want)
+		}
+	}
+}
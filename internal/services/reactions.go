@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnknownReaction = errors.New("unknown reaction")
+
+// likeReaction mirrors into Post.Likes so the existing counter stays accurate.
+const likeReaction = "like"
+
+type ReactionService struct {
+	db    *gorm.DB
+	kinds []string
+	bus   *events.Bus
+}
+
+func NewReactionService(db *gorm.DB, kinds []string, bus *events.Bus) *ReactionService {
+	return &ReactionService{db: db, kinds: kinds, bus: bus}
+}
+
+// Kinds returns the configured set of reactions.
+func (s *ReactionService) Kinds() []string {
+	return s.kinds
+}
+
+func (s *ReactionService) isKnown(kind string) bool {
+	for _, k := range s.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Toggle adds the reaction for userID, or removes it if it is already
+// there. It reports whether the reaction is now set along with the updated
+// counts for the target.
+func (s *ReactionService) Toggle(userID uint, target models.TargetType, targetID uint, kind string) (bool, map[string]int64, error) {
+	if !s.isKnown(kind) {
+		return false, nil, ErrUnknownReaction
+	}
+
+	var added bool
+	err := s.bus.Transaction(func(tx *gorm.DB) error {
+		postID, err := targetPost(tx, userID, target, targetID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ? AND target_type = ? AND target_id = ? AND kind = ?", userID, target, targetID, kind).
+			Delete(&models.Reaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			added = true
+			// A concurrent toggle may have added the reaction since; it is
+			// then already set and there is nothing to announce.
+			reaction := models.Reaction{UserID: userID, TargetType: target, TargetID: targetID, Kind: kind}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil
+			}
+		}
+
+		return s.bus.Publish(tx, events.ReactionToggled{
+			UserID:     userID,
+			TargetType: target,
+			TargetID:   targetID,
+			PostID:     postID,
+			Reaction:   kind,
+			Added:      added,
+		})
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	counts, err := s.Counts(target, []uint{targetID})
+	if err != nil {
+		return false, nil, err
+	}
+	return added, counts[targetID], nil
+}
+
+// Counts returns the number of each reaction per target.
+func (s *ReactionService) Counts(target models.TargetType, ids []uint) (map[uint]map[string]int64, error) {
+	var rows []struct {
+		TargetID uint
+		Kind     string
+		Count    int64
+	}
+	err := s.db.Model(&models.Reaction{}).
+		Select("target_id, kind, COUNT(*) AS count").
+		Where("target_type = ? AND target_id IN ?", target, ids).
+		Group("target_id, kind").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]map[string]int64, len(ids))
+	for _, id := range ids {
+		counts[id] = make(map[string]int64)
+	}
+	for _, row := range rows {
+		counts[row.TargetID][row.Kind] = row.Count
+	}
+	return counts, nil
+}
+
+// Mine returns the reactions userID has left on each target.
+func (s *ReactionService) Mine(userID uint, target models.TargetType, ids []uint) (map[uint][]string, error) {
+	var reactions []models.Reaction
+	err := s.db.Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, target, ids).
+		Order("kind").Find(&reactions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mine := make(map[uint][]string)
+	for _, r := range reactions {
+		mine[r.TargetID] = append(mine[r.TargetID], r.Kind)
+	}
+	return mine, nil
+}
+
+// ReactionEntry is a reaction as listed publicly.
+type ReactionEntry struct {
+	ID        uint              `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	User      models.PublicUser `json:"user"`
+	Reaction  string            `json:"reaction"`
+}
+
+// List returns who reacted to a target, optionally filtered to one reaction.
+// Targets that userID, or a visitor when it is 0, may not see fail with
+// ErrTargetNotFound.
+func (s *ReactionService) List(userID uint, target models.TargetType, targetID uint, kind string, limit, offset int) ([]ReactionEntry, int64, error) {
+	if _, err := targetPost(s.db, userID, target, targetID); err != nil {
+		return nil, 0, err
+	}
+
+	var reactions []models.Reaction
+	var total int64
+
+	query := s.db.Model(&models.Reaction{}).Where("target_type = ? AND target_id = ?", target, targetID)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("User").Order("created_at DESC").
+		Limit(limit).Offset(offset).Find(&reactions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]ReactionEntry, len(reactions))
+	for i, r := range reactions {
+		entries[i] = ReactionEntry{ID: r.ID, CreatedAt: r.CreatedAt, User: r.User.Public(), Reaction: r.Kind}
+	}
+	return entries, total, nil
+}
+
+// Decorate fills in reaction counts, and the reactions of userID when it is
+// non-zero, on posts and their loaded comments.
+func (s *ReactionService) Decorate(posts []models.Post, userID uint) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIDs := make([]uint, len(posts))
+	var commentIDs []uint
+	for i, post := range posts {
+		postIDs[i] = post.ID
+		for _, comment := range post.Comments {
+			commentIDs = append(commentIDs, comment.ID)
+		}
+	}
+
+	postCounts, postMine, err := s.lookup(models.TargetPost, postIDs, userID)
+	if err != nil {
+		return err
+	}
+	commentCounts, commentMine, err := s.lookup(models.TargetComment, commentIDs, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		post := &posts[i]
+		post.Reactions = postCounts[post.ID]
+		post.MyReactions = postMine[post.ID]
+		for j := range post.Comments {
+			comment := &post.Comments[j]
+			comment.Reactions = commentCounts[comment.ID]
+			comment.MyReactions = commentMine[comment.ID]
+		}
+	}
+	return nil
+}
+
+func (s *ReactionService) lookup(target models.TargetType, ids []uint, userID uint) (map[uint]map[string]int64, map[uint][]string, error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+
+	counts, err := s.Counts(target, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mine map[uint][]string
+	if userID != 0 {
+		if mine, err = s.Mine(userID, target, ids); err != nil {
+			return nil, nil, err
+		}
+	}
+	return counts, mine, nil
+}
+
+// targetPost checks a reaction target is visible to userID and returns the
+// post it belongs to.
+func targetPost(tx *gorm.DB, userID uint, target models.TargetType, targetID uint) (uint, error) {
+	switch target {
+	case models.TargetPost:
+		var post models.Post
+		if err := tx.Scopes(models.VisibleTo(userID)).Select("id").First(&post, targetID).Error; err != nil {
+			return 0, ErrTargetNotFound
+		}
+		return post.ID, nil
+	case models.TargetComment:
+		var comment models.Comment
+		err := tx.Select("comments.id", "comments.post_id").
+			Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").
+			Scopes(models.VisibleTo(userID)).
+			Where("comments.status = ?", models.CommentApproved).
+			First(&comment, "comments.id = ?", targetID).Error
+		if err != nil {
+			return 0, ErrTargetNotFound
+		}
+		return comment.PostID, nil
+	}
+	return 0, ErrTargetNotFound
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTargetNotFound    = errors.New("content not found")
+	ErrAlreadyReported   = errors.New("content already reported")
+	ErrReportClosed      = errors.New("report is already closed")
+	ErrInvalidAction     = errors.New("invalid moderation action")
+	ErrInvalidDuration   = errors.New("suspension requires a positive duration")
+	ErrCannotSanctionMod = errors.New("only admins can sanction admins and moderators")
+	ErrTargetGone        = errors.New("target no longer exists")
+)
+
+// CreateReport files a report against a post or comment on behalf of reporterID.
+func (s *ModerationService) CreateReport(report *models.Report) error {
+	ownerID, err := s.targetOwner(s.db, report.TargetType, report.TargetID)
+	if err != nil {
+		return err
+	}
+
+	var existing int64
+	err = s.db.Model(&models.Report{}).
+		Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?",
+			report.ReporterID, report.TargetType, report.TargetID, models.ReportOpen).
+		Count(&existing).Error
+	if err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrAlreadyReported
+	}
+
+	report.TargetUserID = ownerID
+	report.Status = models.ReportOpen
+	return s.db.Create(report).Error
+}
+
+// ListReports returns reports in the given status, oldest first.
+func (s *ModerationService) ListReports(status models.ReportStatus, limit, offset int) ([]models.Report, int64, error) {
+	var reports []models.Report
+	var total int64
+
+	query := s.db.Model(&models.Report{}).Where("status = ?", status)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Reporter").Order("created_at ASC").
+		Limit(limit).Offset(offset).Find(&reports).Error
+	return reports, total, err
+}
+
+// Sanction describes an action a moderator takes against a user.
+type Sanction struct {
+	Action   models.ModerationActionType
+	Reason   string
+	Duration time.Duration
+}
+
+// ResolveReport closes a report by applying sanction. Dismissing leaves the
+// content and its author untouched; removing the content also closes every
+// other open report against it.
+func (s *ModerationService) ResolveReport(reportID uint, moderator *models.User, sanction Sanction) (*models.Report, error) {
+	var report models.Report
+	err := s.bus.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&report, reportID).Error; err != nil {
+			return err
+		}
+		if report.Status != models.ReportOpen {
+			return ErrReportClosed
+		}
+
+		status := models.ReportResolved
+		switch sanction.Action {
+		case models.ActionDismiss:
+			status = models.ReportDismissed
+		case models.ActionRemoveContent:
+			if err := s.removeTarget(tx, report.TargetType, report.TargetID, moderator.ID); err != nil {
+				return err
+			}
+		default:
+			err := s.sanctionUser(tx, report.TargetUserID, moderator, sanction, &report.ID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTargetGone
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		query := tx.Model(&models.Report{}).Where("id = ?", report.ID)
+		if sanction.Action == models.ActionRemoveContent {
+			query = tx.Model(&models.Report{}).
+				Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, models.ReportOpen)
+		}
+		err := query.Updates(map[string]interface{}{
+			"status":         status,
+			"resolved_by_id": moderator.ID,
+			"resolved_at":    now,
+			"resolution":     sanction.Action,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.First(&report, report.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SanctionUser applies a warning, suspension, ban or reinstatement directly
+// to a user.
+func (s *ModerationService) SanctionUser(userID uint, moderator *models.User, sanction Sanction) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.sanctionUser(tx, userID, moderator, sanction, nil)
+	})
+}
+
+func (s *ModerationService) sanctionUser(tx *gorm.DB, userID uint, moderator *models.User, sanction Sanction, reportID *uint) error {
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.CanModerate() && moderator.Role != models.AdminRole {
+		return ErrCannotSanctionMod
+	}
+
+	action := models.ModerationAction{
+		ModeratorID: moderator.ID,
+		UserID:      user.ID,
+		ReportID:    reportID,
+		Action:      sanction.Action,
+		Reason:      sanction.Reason,
+	}
+
+	var updates map[string]interface{}
+	switch sanction.Action {
+	case models.ActionWarn:
+	case models.ActionSuspend:
+		if sanction.Duration <= 0 {
+			return ErrInvalidDuration
+		}
+		until := time.Now().Add(sanction.Duration)
+		action.ExpiresAt = &until
+		updates = map[string]interface{}{"suspended_until": until}
+	case models.ActionBan:
+		updates = map[string]interface{}{"banned_at": time.Now()}
+	case models.ActionReinstate:
+		updates = map[string]interface{}{"suspended_until": nil, "banned_at": nil}
+	default:
+		return ErrInvalidAction
+	}
+
+	if updates != nil {
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&action).Error
+}
+
+// targetOwner returns the author of reportable content. Only content the
+// public can see may be reported: published posts, and approved comments on
+// them. Anything else is ErrTargetNotFound, so reports cannot be used to
+// probe for drafts or held comments.
+func (s *ModerationService) targetOwner(tx *gorm.DB, targetType models.TargetType, targetID uint) (uint, error) {
+	switch targetType {
+	case models.TargetPost:
+		var post models.Post
+		if err := tx.Scopes(models.Published).Select("id", "author_id").First(&post, targetID).Error; err != nil {
+			return 0, ErrTargetNotFound
+		}
+		return post.AuthorID, nil
+	case models.TargetComment:
+		var comment models.Comment
+		err := tx.Select("comments.id", "comments.user_id").
+			Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").
+			Scopes(models.Published).
+			Where("comments.status = ?", models.CommentApproved).
+			First(&comment, "comments.id = ?", targetID).Error
+		if err != nil {
+			return 0, ErrTargetNotFound
+		}
+		return comment.UserID, nil
+	}
+	return 0, ErrTargetNotFound
+}
+
+// removeTarget deletes reported content. Content that is already gone, such
+// as a comment its author deleted, counts as removed.
+func (s *ModerationService) removeTarget(tx *gorm.DB, targetType models.TargetType, targetID, moderatorID uint) error {
+	switch targetType {
+	case models.TargetPost:
+		var post models.Post
+		err := tx.First(&post, targetID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&post).Error; err != nil {
+			return err
+		}
+		return s.bus.Publish(tx, events.PostDeleted{
+			PostID:       post.ID,
+			Slug:         post.Slug,
+			ActorID:      moderatorID,
+			WasPublished: post.Status == models.PostPublished,
+		})
+	case models.TargetComment:
+		var comment models.Comment
+		err := tx.First(&comment, targetID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&comment).Error; err != nil {
+			return err
+		}
+		if comment.Status != models.CommentApproved {
+			return nil
+		}
+		return s.bus.Publish(tx, events.CommentRemoved{CommentID: comment.ID, PostID: comment.PostID})
+	}
+	return ErrTargetNotFound
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/realtime"
+	"github.com/Realwale/scribana/pkg/spam"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SpamService scores new comments and trains the classifier from
+// moderation decisions. Classifier counts live in the spam_tokens and
+// spam_classes tables; each replica scores with a copy loaded from them,
+// rebuilt whenever training changes them.
+type SpamService struct {
+	db         *gorm.DB
+	cfg        config.SpamConfig
+	classifier *spam.Classifier
+	filter     *spam.Filter
+}
+
+func NewSpamService(db *gorm.DB, cfg config.SpamConfig) *SpamService {
+	classifier := spam.NewClassifier()
+	return &SpamService{
+		db:         db,
+		cfg:        cfg,
+		classifier: classifier,
+		filter: spam.NewFilter(classifier, spam.Rules{
+			MaxLinks:       cfg.MaxLinks,
+			BlockedDomains: cfg.BlockedDomains,
+			VelocityLimit:  cfg.VelocityLimit,
+		}),
+	}
+}
+
+// Load rebuilds the classifier from the database.
+func (s *SpamService) Load() error {
+	var tokens []models.SpamToken
+	if err := s.db.Find(&tokens).Error; err != nil {
+		return err
+	}
+	var classes []models.SpamClass
+	if err := s.db.Find(&classes).Error; err != nil {
+		return err
+	}
+
+	counts := make(map[string]spam.TokenCount, len(tokens))
+	for _, t := range tokens {
+		counts[t.Token] = spam.TokenCount{Spam: t.Spam, Ham: t.Ham}
+	}
+	docs := make(map[spam.Class]int, len(classes))
+	for _, c := range classes {
+		docs[spam.Class(c.Name)] = c.Documents
+	}
+
+	s.classifier.Load(counts, docs)
+	return nil
+}
+
+// Listen reloads the classifier whenever training changes it on any
+// replica, until ctx is cancelled.
+func (s *SpamService) Listen(ctx context.Context, hub *realtime.Hub) {
+	events, unsubscribe := hub.Subscribe(realtime.SiteTopic)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == "spam.trained" {
+				if err := s.Load(); err != nil {
+					log.Printf("spam: failed to reload classifier: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// Check scores a comment about to be posted by userID.
+func (s *SpamService) Check(userID uint, content, honeypot string) (spam.Result, error) {
+	var recent int64
+	err := s.db.Model(&models.Comment{}).
+		Where("user_id = ? AND created_at > ?", userID, time.Now().Add(-s.cfg.VelocityWindow)).
+		Count(&recent).Error
+	if err != nil {
+		return spam.Result{}, err
+	}
+
+	return s.filter.Check(spam.Submission{
+		Text:              content,
+		Honeypot:          honeypot,
+		RecentSubmissions: int(recent),
+	}), nil
+}
+
+// IsSpam reports whether score is high enough to skip the moderation queue.
+func (s *SpamService) IsSpam(score float64) bool {
+	return score >= s.cfg.Threshold
+}
+
+// LabelFor maps a moderation decision to the class the comment is trained as.
+// Pending comments are not trained.
+func LabelFor(status models.CommentStatus) string {
+	switch status {
+	case models.CommentApproved:
+		return string(spam.Ham)
+	case models.CommentRejected, models.CommentSpam:
+		return string(spam.Spam)
+	}
+	return ""
+}
+
+// Retrain moves comment from its current training label to label,
+// persisting the new counts with tx, and reports whether they changed. The
+// tokens trained are kept with the comment, so they are unlearned exactly
+// even after the comment is edited. Callers publish events.SpamTrained once
+// they are done retraining so that replicas reload the classifier.
+func (s *SpamService) Retrain(tx *gorm.DB, comment *models.Comment, label string) (bool, error) {
+	if comment.SpamLabel == label {
+		return false, nil
+	}
+
+	previous, unlearned := comment.SpamLabel, comment.SpamTokens
+	var learned []string
+	if label != "" {
+		learned = spam.Tokenize(comment.Content)
+	}
+
+	if previous != "" {
+		if err := s.persist(tx, spam.Class(previous), unlearned, -1); err != nil {
+			return false, err
+		}
+	}
+	if label != "" {
+		if err := s.persist(tx, spam.Class(label), learned, 1); err != nil {
+			return false, err
+		}
+	}
+	comment.SpamLabel = label
+	comment.SpamTokens = learned
+	if err := tx.Model(comment).Select("spam_label", "spam_tokens").UpdateColumns(comment).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SpamService) persist(tx *gorm.DB, class spam.Class, tokens []string, delta int) error {
+	column := "ham"
+	if class == spam.Spam {
+		column = "spam"
+	}
+
+	if len(tokens) > 0 {
+		rows := make([]map[string]interface{}, len(tokens))
+		for i, token := range tokens {
+			rows[i] = map[string]interface{}{"token": token, column: max(delta, 0)}
+		}
+		err := tx.Model(&models.SpamToken{}).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "token"}},
+			DoUpdates: clause.Set{{
+				Column: clause.Column{Name: column},
+				Value:  gorm.Expr("GREATEST(spam_tokens."+column+" + ?, 0)", delta),
+			}},
+		}).Create(rows).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.SpamClass{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.Set{{
+			Column: clause.Column{Name: "documents"},
+			Value:  gorm.Expr("GREATEST(spam_classes.documents + ?, 0)", delta),
+		}},
+	}).Create(map[string]interface{}{"name": string(class), "documents": max(delta, 0)}).Error
+}
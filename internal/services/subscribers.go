@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// RegisterSubscribers wires the side effects of domain events: notifications,
+// webhooks, live updates and denormalised counters.
+func RegisterSubscribers(bus *events.Bus, db *gorm.DB, notifications *NotificationService, webhooks *WebhookService, reactions *ReactionService, hub *realtime.Hub) {
+	// Post.Likes mirrors "like" reactions, so it is kept in step inside the
+	// transaction that toggled the reaction.
+	events.Subscribe(bus, func(tx *gorm.DB, e events.ReactionToggled) error {
+		if e.TargetType != models.TargetPost || e.Reaction != likeReaction {
+			return nil
+		}
+		delta := -1
+		if e.Added {
+			delta = 1
+		}
+		return tx.Model(&models.Post{}).Where("id = ?", e.TargetID).
+			UpdateColumn("likes", gorm.Expr("GREATEST(likes + ?, 0)", delta)).Error
+	})
+
+	// Notifications
+	events.SubscribeAsync(bus, "notifications", func(ctx context.Context, e events.PostPublished) error {
+		var post models.Post
+		if err := db.WithContext(ctx).Scopes(models.Published).First(&post, e.PostID).Error; err != nil {
+			return ignoreMissing(err)
+		}
+		return notifications.PostPublished(&post, e.ActorID)
+	})
+	events.SubscribeAsync(bus, "notifications", func(ctx context.Context, e events.CommentPublished) error {
+		var comment models.Comment
+		if err := db.WithContext(ctx).Where("status = ?", models.CommentApproved).First(&comment, e.CommentID).Error; err != nil {
+			return ignoreMissing(err)
+		}
+		return notifications.CommentPublished(&comment)
+	})
+
+	// Webhooks
+	loadPost := func(ctx context.Context, id uint) (*publicPost, error) {
+		var post models.Post
+		err := db.WithContext(ctx).Preload("Author").Preload("Category").Preload("Tags").First(&post, id).Error
+		return &publicPost{Post: &post, Author: post.Author.Public()}, err
+	}
+	events.SubscribeAsync(bus, "webhooks", func(ctx context.Context, e events.PostPublished) error {
+		post, err := loadPost(ctx, e.PostID)
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		return webhooks.Dispatch(models.EventPostPublished, post)
+	})
+	events.SubscribeAsync(bus, "webhooks", func(ctx context.Context, e events.PostUpdated) error {
+		post, err := loadPost(ctx, e.PostID)
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		switch {
+		case post.Status == models.PostPublished:
+			return webhooks.Dispatch(models.EventPostUpdated, post)
+		case e.WasPublished:
+			// Moving a post back to draft takes it down as far as receivers
+			// are concerned.
+			return webhooks.Dispatch(models.EventPostDeleted, map[string]interface{}{"id": post.ID, "slug": post.Slug})
+		}
+		return nil
+	})
+	events.SubscribeAsync(bus, "webhooks", func(ctx context.Context, e events.PostDeleted) error {
+		if !e.WasPublished {
+			return nil
+		}
+		return webhooks.Dispatch(models.EventPostDeleted, map[string]interface{}{"id": e.PostID, "slug": e.Slug})
+	})
+	events.SubscribeAsync(bus, "webhooks", func(ctx context.Context, e events.CommentPublished) error {
+		var comment models.Comment
+		err := db.WithContext(ctx).Preload("User").
+			Where("status = ?", models.CommentApproved).First(&comment, e.CommentID).Error
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		return webhooks.Dispatch(models.EventCommentCreated, newPublicComment(&comment))
+	})
+
+	// Cached public pages such as the sitemap go stale whenever a post
+	// changes; tell every replica.
+	contentChanged := func(postID uint) {
+		hub.Publish(realtime.SiteTopic, "content.changed", map[string]uint{"post_id": postID})
+	}
+	events.SubscribeAsync(bus, "site-cache", func(ctx context.Context, e events.PostPublished) error {
+		contentChanged(e.PostID)
+		return nil
+	})
+	events.SubscribeAsync(bus, "site-cache", func(ctx context.Context, e events.PostUpdated) error {
+		contentChanged(e.PostID)
+		return nil
+	})
+	events.SubscribeAsync(bus, "site-cache", func(ctx context.Context, e events.PostDeleted) error {
+		contentChanged(e.PostID)
+		return nil
+	})
+
+	// Replicas score comments with their own copy of the spam classifier;
+	// have each reload it once moderation retrains it.
+	events.SubscribeAsync(bus, "spam-model", func(ctx context.Context, e events.SpamTrained) error {
+		hub.Publish(realtime.SiteTopic, "spam.trained", nil)
+		return nil
+	})
+
+	// Live updates
+	events.SubscribeAsync(bus, "realtime", func(ctx context.Context, e events.CommentPublished) error {
+		var comment models.Comment
+		err := db.WithContext(ctx).Preload("User").Where("status = ?", models.CommentApproved).
+			First(&comment, e.CommentID).Error
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		hub.Publish(realtime.PostTopic(e.PostID), "comment.created", newPublicComment(&comment))
+		return nil
+	})
+	events.SubscribeAsync(bus, "realtime", func(ctx context.Context, e events.CommentUpdated) error {
+		var comment models.Comment
+		err := db.WithContext(ctx).Preload("User").Where("status = ?", models.CommentApproved).
+			First(&comment, e.CommentID).Error
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		hub.Publish(realtime.PostTopic(e.PostID), "comment.updated", newPublicComment(&comment))
+		return nil
+	})
+	events.SubscribeAsync(bus, "realtime", func(ctx context.Context, e events.CommentRemoved) error {
+		hub.Publish(realtime.PostTopic(e.PostID), "comment.deleted", map[string]uint{"id": e.CommentID})
+		return nil
+	})
+	events.SubscribeAsync(bus, "realtime", func(ctx context.Context, e events.ReactionToggled) error {
+		counts, err := reactions.Counts(e.TargetType, []uint{e.TargetID})
+		if err != nil {
+			return err
+		}
+
+		update := map[string]interface{}{
+			"target_type": e.TargetType,
+			"target_id":   e.TargetID,
+			"reactions":   counts[e.TargetID],
+		}
+		if e.TargetType == models.TargetPost {
+			var post models.Post
+			if err := db.WithContext(ctx).Select("id", "likes").First(&post, e.TargetID).Error; err == nil {
+				update["likes"] = post.Likes
+			}
+		}
+		hub.Publish(realtime.PostTopic(e.PostID), "reactions.updated", update)
+		return nil
+	})
+}
+
+// publicComment is a comment as pushed to everyone following its post and
+// sent to webhook receivers, with only what anyone may see of its author.
+type publicComment struct {
+	ID        uint                 `json:"id"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Content   string               `json:"content"`
+	PostID    uint                 `json:"post_id"`
+	ParentID  *uint                `json:"parent_id,omitempty"`
+	UserID    uint                 `json:"user_id"`
+	User      models.PublicUser    `json:"user"`
+	Status    models.CommentStatus `json:"status"`
+}
+
+func newPublicComment(c *models.Comment) publicComment {
+	return publicComment{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Content:   c.Content,
+		PostID:    c.PostID,
+		ParentID:  c.ParentID,
+		UserID:    c.UserID,
+		User:      c.User.Public(),
+		Status:    c.Status,
+	}
+}
+
+// publicPost is a post as sent to webhook receivers, with only what anyone
+// may see of its author.
+type publicPost struct {
+	*models.Post
+	Author models.PublicUser `json:"author"`
+}
+
+// ignoreMissing treats a record deleted since the event as nothing to do.
+func ignoreMissing(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
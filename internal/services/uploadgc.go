@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// QuarantinePrefix is where unreferenced uploads are held before deletion.
+const QuarantinePrefix = "quarantine/"
+
+// GCOptions control a collection of unreferenced uploads.
+type GCOptions struct {
+	// DryRun reports what would happen without moving or deleting anything.
+	DryRun           bool
+	GracePeriod      time.Duration
+	QuarantinePeriod time.Duration
+}
+
+// GCReport describes a collection of unreferenced uploads.
+type GCReport struct {
+	DryRun bool `json:"dry_run"`
+	// Scanned counts the uploads outside the quarantine; each is either
+	// referenced, recent or quarantined.
+	Scanned    int `json:"scanned"`
+	Referenced int `json:"referenced"`
+	Recent     int `json:"recent"`
+	// Quarantined are the uploads moved aside by this collection.
+	Quarantined []GCItem `json:"quarantined"`
+	// Restored are quarantined uploads that are referenced again.
+	Restored []GCItem `json:"restored"`
+	// Deleted are uploads that stayed unreferenced for the whole
+	// quarantine period.
+	Deleted []GCItem `json:"deleted"`
+	// Held counts quarantined uploads still within the quarantine period.
+	Held       int   `json:"held"`
+	FreedBytes int64 `json:"freed_bytes"`
+}
+
+// GCItem is an upload acted on by a collection.
+type GCItem struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modified_at"`
+}
+
+// Summary describes the report in a few lines.
+func (r *GCReport) Summary() []string {
+	return []string{
+		fmt.Sprintf("%d uploads scanned: %d referenced, %d recent", r.Scanned, r.Referenced, r.Recent),
+		fmt.Sprintf("%d quarantined, %d restored, %d deleted (%d bytes), %d held in quarantine",
+			len(r.Quarantined), len(r.Restored), len(r.Deleted), r.FreedBytes, r.Held),
+	}
+}
+
+// CollectUploads is the job collecting unreferenced uploads.
+type CollectUploads struct{}
+
+func (CollectUploads) JobKind() string { return "uploads.gc" }
+
+// ScheduleGC collects unreferenced uploads on a cron schedule.
+func (s *MediaService) ScheduleGC(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	return s.queue.Schedule("uploads.gc", spec, CollectUploads{})
+}
+
+func (s *MediaService) collectUploads(ctx context.Context, _ *models.Job, _ CollectUploads) error {
+	report, err := s.CollectGarbage(ctx, GCOptions{
+		GracePeriod:      s.gc.GracePeriod,
+		QuarantinePeriod: s.gc.QuarantinePeriod,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("uploads gc: %s", strings.Join(report.Summary(), "; "))
+	return nil
+}
+
+// CollectGarbage finds uploads that no post and no media record refers to.
+// Those older than the grace period are moved into the quarantine; files
+// that stay unreferenced for the quarantine period are deleted, and any
+// referenced again meanwhile are restored. A file and its image variants
+// are kept or collected together.
+func (s *MediaService) CollectGarbage(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	now := time.Now()
+	report := &GCReport{DryRun: opts.DryRun, Quarantined: []GCItem{}, Restored: []GCItem{}, Deleted: []GCItem{}}
+
+	// Files are listed before references are gathered, so a file uploaded
+	// and referenced in between is either missed or seen as referenced.
+	var objects []storage.Object
+	err := s.storage.List(ctx, "", func(obj storage.Object) error {
+		// Chunks of resumable uploads are discarded when the upload expires.
+		if strings.HasPrefix(obj.Key, UploadPrefix) {
+			return nil
+		}
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	referenced, err := s.referencedStems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objects {
+		item := GCItem{Key: obj.Key, Size: obj.Size, ModTime: obj.ModTime}
+		if key, ok := strings.CutPrefix(obj.Key, QuarantinePrefix); ok {
+			switch {
+			case referenced[storage.Stem(key)]:
+				item.Key = key
+				report.Restored = append(report.Restored, item)
+				if !opts.DryRun {
+					err = storage.Move(ctx, s.storage, obj.Key, key)
+				}
+			case obj.ModTime.Before(now.Add(-opts.QuarantinePeriod)):
+				report.Deleted = append(report.Deleted, item)
+				report.FreedBytes += obj.Size
+				if !opts.DryRun {
+					err = s.storage.Delete(ctx, obj.Key)
+				}
+			default:
+				report.Held++
+			}
+		} else {
+			report.Scanned++
+			switch {
+			case referenced[storage.Stem(obj.Key)]:
+				report.Referenced++
+			case obj.ModTime.After(now.Add(-opts.GracePeriod)):
+				report.Recent++
+			default:
+				report.Quarantined = append(report.Quarantined, item)
+				if !opts.DryRun {
+					err = storage.Move(ctx, s.storage, obj.Key, QuarantinePrefix+obj.Key)
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", obj.Key, err)
+		}
+	}
+	return report, nil
+}
+
+// referencedStems returns the stems of every stored file referenced by a
+// media record or by a post's image, social image, excerpt or content.
+// Deleted posts do not count.
+func (s *MediaService) referencedStems(ctx context.Context) (map[string]bool, error) {
+	referenced := map[string]bool{}
+
+	var media []models.Media
+	err := s.db.WithContext(ctx).Select("id", "key", "variants", "thumbnail").
+		FindInBatches(&media, 500, func(*gorm.DB, int) error {
+			for _, m := range media {
+				for _, key := range m.Keys() {
+					referenced[storage.Stem(key)] = true
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.postReferences(s.db.WithContext(ctx), referenced); err != nil {
+		return nil, err
+	}
+	return referenced, nil
+}
+
+// postReferences adds to referenced the stems of stored files, private
+// media included, referenced by the image, social image, excerpt or content
+// of the posts query matches.
+func (s *MediaService) postReferences(query *gorm.DB, referenced map[string]bool) error {
+	refs := storage.References(s.storage)
+	prefix := s.storage.URL("")
+	var posts []models.Post
+	return query.Select("id", "image_url", "social_image", "excerpt", "content").
+		FindInBatches(&posts, 200, func(*gorm.DB, int) error {
+			for _, p := range posts {
+				for _, text := range []string{p.ImageURL, p.SocialImage, p.Excerpt, p.Content} {
+					for _, ref := range refs.FindAllString(text, -1) {
+						referenced[storage.Stem(strings.TrimPrefix(ref, prefix))] = true
+					}
+					for _, ref := range s.privateRefs.FindAllString(text, -1) {
+						_, name := s.privateName(ref)
+						referenced[storage.Stem(PrivatePrefix+name)] = true
+					}
+				}
+			}
+			return nil
+		}).Error
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/pkg/imaging"
+	"github.com/Realwale/scribana/pkg/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UploadPrefix is where the chunks of resumable uploads are kept until the
+// upload completes.
+const UploadPrefix = "tus/"
+
+var (
+	ErrUploadTooLarge = errors.New("upload exceeds the size limit")
+	ErrUploadExpired  = errors.New("upload has expired")
+	ErrOffsetMismatch = errors.New("upload offset does not match")
+	// ErrUploadOverrun is returned for chunks running past the length
+	// declared when the upload was created.
+	ErrUploadOverrun = errors.New("chunk exceeds the upload length")
+)
+
+// UploadLimit is the largest file a user with the given role may upload,
+// or 0 when the role may not upload.
+func (s *MediaService) UploadLimit(role models.Role) int64 {
+	return s.uploads.MaxSize[string(role)]
+}
+
+// CheckUpload reports whether user may upload a file of size bytes with the
+// given name, failing with storage.ErrUnsupportedType, ErrUploadTooLarge or
+// ErrQuotaExceeded.
+func (s *MediaService) CheckUpload(ctx context.Context, user *models.User, filename string, size int64) error {
+	ext := path.Ext(filename)
+	isImage := imaging.FormatOf(ext) != ""
+	if !isImage && !storage.Accepted(ext) {
+		return fmt.Errorf("%w: %s", storage.ErrUnsupportedType, ext)
+	}
+	if size > s.UploadLimit(user.Role) || isImage && size > s.uploads.MaxImageSize {
+		return ErrUploadTooLarge
+	}
+	return s.checkQuota(ctx, user, size)
+}
+
+// CreateUpload starts a resumable upload of a file of length bytes.
+func (s *MediaService) CreateUpload(ctx context.Context, owner *models.User, filename string, length int64, metadata map[string]string) (*models.Upload, error) {
+	if err := s.CheckUpload(ctx, owner, filename, length); err != nil {
+		return nil, err
+	}
+	if private, _ := strconv.ParseBool(metadata["private"]); private && s.privateErr != nil {
+		return nil, s.privateErr
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	upload := models.Upload{
+		ID:        hex.EncodeToString(id),
+		OwnerID:   owner.ID,
+		Filename:  path.Base(filename),
+		Length:    length,
+		Metadata:  metadata,
+		Chunks:    []string{},
+		ExpiresAt: time.Now().Add(s.uploads.Expiry),
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.reserveQuota(tx, owner.ID, length, ""); err != nil {
+			return err
+		}
+		return tx.Create(&upload).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// FindUpload returns an upload, failing with ErrUploadExpired once it has
+// expired even if it has not been discarded yet.
+func (s *MediaService) FindUpload(ctx context.Context, id string) (*models.Upload, error) {
+	var upload models.Upload
+	if err := s.db.WithContext(ctx).Preload("Media").First(&upload, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if upload.ExpiresAt.Before(time.Now()) {
+		return nil, ErrUploadExpired
+	}
+	return &upload, nil
+}
+
+// WriteUpload appends a chunk read from r to an upload at offset, which must
+// be the upload's current offset. Whatever arrives before the client goes
+// away is kept, so an interrupted chunk can be resumed where it stopped.
+// The chunk completing the upload adds the file to the media library.
+func (s *MediaService) WriteUpload(ctx context.Context, upload *models.Upload, offset int64, r io.Reader) (*models.Upload, error) {
+	if offset != upload.Offset {
+		return nil, ErrOffsetMismatch
+	}
+
+	// Chunks are spooled to disk first so their size is known and a broken
+	// connection leaves a usable partial chunk.
+	tmp, err := os.CreateTemp("", "scribana-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	remaining := upload.Length - upload.Offset
+	n, readErr := io.Copy(tmp, io.LimitReader(r, remaining+1))
+	if n > remaining {
+		return nil, ErrUploadOverrun
+	}
+	if readErr != nil {
+		if n == 0 {
+			return nil, readErr
+		}
+		// The client is gone; store what it sent regardless.
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	if n > 0 {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%s%s/%d-%d", UploadPrefix, upload.ID, offset, time.Now().UnixNano())
+		if err := s.storage.Put(ctx, key, tmp, n, "application/octet-stream"); err != nil {
+			return nil, err
+		}
+		if upload, err = s.appendChunk(ctx, upload.ID, offset, key, n); err != nil {
+			if err := s.storage.Delete(context.Background(), key); err != nil {
+				log.Printf("uploads: deleting %s: %v", key, err)
+			}
+			return nil, err
+		}
+	}
+
+	if upload.Complete() && upload.MediaID == nil {
+		return s.completeUpload(ctx, upload)
+	}
+	return upload, nil
+}
+
+// appendChunk records a stored chunk, unless another request moved the
+// upload past offset meanwhile.
+func (s *MediaService) appendChunk(ctx context.Context, id string, offset int64, key string, n int64) (*models.Upload, error) {
+	var upload models.Upload
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&upload, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if upload.Offset != offset {
+			return ErrOffsetMismatch
+		}
+		upload.Offset += n
+		upload.Chunks = append(upload.Chunks, key)
+		upload.ExpiresAt = time.Now().Add(s.uploads.Expiry)
+		return tx.Save(&upload).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// completeUpload adds a fully received upload to the media library and
+// discards its chunks. When that fails the chunks are kept, and an empty
+// chunk at the final offset tries again. The upload stays locked meanwhile,
+// so requests completing it concurrently wait and then find the media made.
+func (s *MediaService) completeUpload(ctx context.Context, upload *models.Upload) (*models.Upload, error) {
+	var chunks []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(upload, "id = ?", upload.ID).Error; err != nil {
+			return err
+		}
+		if upload.MediaID != nil {
+			var media models.Media
+			if err := tx.First(&media, *upload.MediaID).Error; err != nil {
+				return err
+			}
+			upload.Media = &media
+			return nil
+		}
+
+		r := storage.Concat(ctx, s.storage, upload.Chunks)
+		defer r.Close()
+		private, _ := strconv.ParseBool(upload.Metadata["private"])
+		media, err := s.upload(ctx, upload.OwnerID, upload.Filename, r, upload.Length, private, upload.ID)
+		if err != nil {
+			return err
+		}
+
+		chunks = upload.Chunks
+		upload.MediaID = &media.ID
+		upload.Media = media
+		upload.Chunks = []string{}
+		return tx.Select("media_id", "chunks").Save(upload).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.removeChunks(chunks)
+	return upload, nil
+}
+
+// TerminateUpload discards an upload and any chunks received. Media made
+// from a completed upload is kept.
+func (s *MediaService) TerminateUpload(ctx context.Context, upload *models.Upload) error {
+	if err := s.db.WithContext(ctx).Delete(upload).Error; err != nil {
+		return err
+	}
+	s.removeChunks(upload.Chunks)
+	return nil
+}
+
+func (s *MediaService) removeChunks(keys []string) {
+	for _, key := range keys {
+		if err := s.storage.Delete(context.Background(), key); err != nil {
+			log.Printf("uploads: deleting %s: %v", key, err)
+		}
+	}
+}
+
+// ExpireUploads is the job discarding expired uploads.
+type ExpireUploads struct{}
+
+func (ExpireUploads) JobKind() string { return "uploads.expire" }
+
+func (s *MediaService) expireUploads(ctx context.Context, _ *models.Job, _ ExpireUploads) error {
+	var uploads []models.Upload
+	err := s.db.WithContext(ctx).Where("expires_at < ?", time.Now()).
+		FindInBatches(&uploads, 100, func(tx *gorm.DB, _ int) error {
+			for i := range uploads {
+				if err := s.TerminateUpload(ctx, &uploads[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	return err
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/jobs"
+	"github.com/Realwale/scribana/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxResponseBody is how much of a receiver's response is kept in the log.
+const maxResponseBody = 2048
+
+// WebhookPayload is the JSON body POSTed to webhook receivers.
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type WebhookService struct {
+	db     *gorm.DB
+	cfg    config.WebhookConfig
+	client *http.Client
+	queue  *jobs.Queue
+}
+
+func NewWebhookService(db *gorm.DB, cfg config.WebhookConfig, queue *jobs.Queue) *WebhookService {
+	s := &WebhookService{
+		db:     db,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  queue,
+	}
+	jobs.Register(queue, s.deliver)
+	return s
+}
+
+// NewSecret generates a signing secret for a new webhook.
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// Sign computes the signature sent in the X-Scribana-Signature header: an
+// HMAC-SHA256 over "<timestamp>.<body>" keyed with the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch queues a delivery of event to every active webhook subscribed to it.
+func (s *WebhookService) Dispatch(event string, data interface{}) error {
+	var webhooks []models.Webhook
+	if err := s.db.Where("active = ?", true).Find(&webhooks).Error; err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(WebhookPayload{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, webhook := range webhooks {
+		if webhook.Subscribes(event) {
+			deliveries = append(deliveries, models.WebhookDelivery{
+				WebhookID: webhook.ID,
+				Event:     event,
+				Payload:   string(payload),
+				Status:    models.DeliveryPending,
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&deliveries).Error; err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			if err := s.enqueue(tx, delivery.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Redeliver queues a fresh copy of a previous delivery.
+func (s *WebhookService) Redeliver(deliveryID uint) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := s.db.First(&original, deliveryID).Error; err != nil {
+		return nil, err
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookID:    original.WebhookID,
+		Event:        original.Event,
+		Payload:      original.Payload,
+		Status:       models.DeliveryPending,
+		RedeliveryOf: &original.ID,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&delivery).Error; err != nil {
+			return err
+		}
+		return s.enqueue(tx, delivery.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// DeliverWebhook is the job sending one webhook delivery.
+type DeliverWebhook struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+func (DeliverWebhook) JobKind() string { return "webhooks.deliver" }
+
+func (s *WebhookService) enqueue(tx *gorm.DB, deliveryID uint) error {
+	_, err := s.queue.Enqueue(tx, DeliverWebhook{DeliveryID: deliveryID}, jobs.Options{MaxAttempts: s.cfg.MaxAttempts})
+	return err
+}
+
+// deliver sends one delivery and records the outcome. Failures are returned
+// so the job queue retries them with backoff; the delivery is marked failed
+// once the job runs out of attempts.
+func (s *WebhookService) deliver(ctx context.Context, job *models.Job, p DeliverWebhook) error {
+	var delivery models.WebhookDelivery
+	if err := s.db.WithContext(ctx).First(&delivery, p.DeliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+	var webhook models.Webhook
+	if err := s.db.WithContext(ctx).Unscoped().First(&webhook, delivery.WebhookID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseCode, delivery.ResponseBody, delivery.Error = 0, "", ""
+
+	if !webhook.Active || webhook.DeletedAt.Valid {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook is disabled"
+		return s.db.Save(&delivery).Error
+	}
+
+	code, body, err := s.send(ctx, &webhook, &delivery)
+	delivery.ResponseCode, delivery.ResponseBody = code, body
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+	case code < 200 || code >= 300:
+		delivery.Error = fmt.Sprintf("receiver responded with status %d", code)
+	default:
+		delivery.Status = models.DeliverySucceeded
+		return s.db.Save(&delivery).Error
+	}
+
+	delivery.Status = models.DeliveryPending
+	if job.Attempts >= job.MaxAttempts {
+		delivery.Status = models.DeliveryFailed
+	}
+	if err := s.db.Save(&delivery).Error; err != nil {
+		return err
+	}
+	return errors.New(delivery.Error)
+}
+
+func (s *WebhookService) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Scribana-Webhooks/1.0")
+	req.Header.Set("X-Scribana-Event", delivery.Event)
+	req.Header.Set("X-Scribana-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Scribana-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Scribana-Signature", Sign(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(respBody), nil
+}
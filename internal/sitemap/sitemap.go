@@ -0,0 +1,303 @@
+// Package sitemap generates the XML sitemap and robots.txt of the blog.
+//
+// The sitemap lists the home page, every indexable published post, and the category
+// and author pages that have published posts. Up to MaxURLs it is a single
+// document; beyond that /sitemap.xml becomes a sitemap index pointing at
+// numbered pages. Generated documents are cached in memory until content
+// changes or the cache expires.
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// MaxURLs is the most URLs the sitemap protocol allows in one file.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Document is a rendered sitemap file.
+type Document struct {
+	Body         []byte
+	LastModified time.Time
+}
+
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type Generator struct {
+	db    *gorm.DB
+	site  config.SiteConfig
+	ttl   time.Duration
+	mu    sync.Mutex
+	cache *snapshot
+}
+
+type snapshot struct {
+	built time.Time
+	root  *Document
+	pages []*Document
+}
+
+func NewGenerator(db *gorm.DB, site config.SiteConfig, cfg config.SitemapConfig) *Generator {
+	return &Generator{db: db, site: site, ttl: cfg.CacheTTL}
+}
+
+// Sitemap returns /sitemap.xml: the full sitemap, or an index of its pages
+// when there are more than MaxURLs URLs.
+func (g *Generator) Sitemap() (*Document, error) {
+	s, err := g.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return s.root, nil
+}
+
+// Page returns the nth page (counting from 1) of a split sitemap. It
+// reports false when there is no such page.
+func (g *Generator) Page(n int) (*Document, bool, error) {
+	s, err := g.snapshot()
+	if err != nil {
+		return nil, false, err
+	}
+	if n < 1 || n > len(s.pages) {
+		return nil, false, nil
+	}
+	return s.pages[n-1], true, nil
+}
+
+// Invalidate drops the cached sitemap so the next request rebuilds it.
+func (g *Generator) Invalidate() {
+	g.mu.Lock()
+	g.cache = nil
+	g.mu.Unlock()
+}
+
+// Listen invalidates the cache whenever content changes on any replica,
+// until ctx is cancelled.
+func (g *Generator) Listen(ctx context.Context, hub *realtime.Hub) {
+	events, unsubscribe := hub.Subscribe(realtime.SiteTopic)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == "content.changed" {
+				g.Invalidate()
+			}
+		}
+	}
+}
+
+func (g *Generator) snapshot() (*snapshot, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.cache != nil && time.Since(g.cache.built) < g.ttl {
+		return g.cache, nil
+	}
+
+	urls, err := g.urls()
+	if err != nil {
+		return nil, err
+	}
+	s, err := g.render(urls)
+	if err != nil {
+		return nil, err
+	}
+	g.cache = s
+	return s, nil
+}
+
+// urls collects every URL in the sitemap, most recently changed first.
+func (g *Generator) urls() ([]URL, error) {
+	var posts []models.Post
+	err := g.db.Scopes(models.Public).Select("id", "slug", "updated_at").
+		Where("no_index = ?", false).
+		Order("updated_at DESC").Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []struct {
+		Slug    string
+		LastMod time.Time
+	}
+	err = g.db.Table("categories").
+		Select("categories.slug, MAX(posts.updated_at) AS last_mod").
+		Joins("JOIN posts ON posts.category_id = categories.id").
+		Where("posts.status = ? AND NOT posts.members_only AND posts.deleted_at IS NULL AND categories.deleted_at IS NULL", models.PostPublished).
+		Group("categories.slug").Order("last_mod DESC").
+		Scan(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var authors []struct {
+		Username string
+		LastMod  time.Time
+	}
+	err = g.db.Table("users").
+		Select("users.username, MAX(posts.updated_at) AS last_mod").
+		Joins("JOIN posts ON posts.author_id = users.id").
+		Where("posts.status = ? AND NOT posts.members_only AND posts.deleted_at IS NULL AND users.deleted_at IS NULL", models.PostPublished).
+		Group("users.username").Order("last_mod DESC").
+		Scan(&authors).Error
+	if err != nil {
+		return nil, err
+	}
+
+	home := URL{Loc: g.url("/")}
+	if len(posts) > 0 {
+		home.LastMod = posts[0].UpdatedAt
+	}
+
+	urls := make([]URL, 0, 1+len(posts)+len(categories)+len(authors))
+	urls = append(urls, home)
+	for i := range posts {
+		urls = append(urls, URL{Loc: g.url(posts[i].Path()), LastMod: posts[i].UpdatedAt})
+	}
+	for _, category := range categories {
+		urls = append(urls, URL{Loc: g.url("/categories/" + category.Slug), LastMod: category.LastMod})
+	}
+	for _, author := range authors {
+		urls = append(urls, URL{Loc: g.url("/authors/" + author.Username), LastMod: author.LastMod})
+	}
+	return urls, nil
+}
+
+func (g *Generator) url(path string) string {
+	return g.site.URL(path)
+}
+
+type urlset struct {
+	XMLName xml.Name   `xml:"urlset"`
+	XMLNS   string     `xml:"xmlns,attr"`
+	URLs    []urlEntry `xml:"url"`
+}
+
+type urlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name   `xml:"sitemapindex"`
+	XMLNS    string     `xml:"xmlns,attr"`
+	Sitemaps []urlEntry `xml:"sitemap"`
+}
+
+func (g *Generator) render(urls []URL) (*snapshot, error) {
+	s := &snapshot{built: time.Now()}
+	if len(urls) <= MaxURLs {
+		root, err := renderURLs(urls)
+		if err != nil {
+			return nil, err
+		}
+		s.root = root
+		return s, nil
+	}
+
+	index := sitemapIndex{XMLNS: namespace}
+	var lastModified time.Time
+	for start := 0; start < len(urls); start += MaxURLs {
+		end := start + MaxURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		page, err := renderURLs(urls[start:end])
+		if err != nil {
+			return nil, err
+		}
+		s.pages = append(s.pages, page)
+		if page.LastModified.After(lastModified) {
+			lastModified = page.LastModified
+		}
+		index.Sitemaps = append(index.Sitemaps, urlEntry{
+			Loc:     g.url(fmt.Sprintf("/sitemaps/%d.xml", len(s.pages))),
+			LastMod: lastMod(page.LastModified),
+		})
+	}
+
+	body, err := encode(index)
+	if err != nil {
+		return nil, err
+	}
+	s.root = &Document{Body: body, LastModified: lastModified}
+	return s, nil
+}
+
+func renderURLs(urls []URL) (*Document, error) {
+	set := urlset{XMLNS: namespace}
+	var lastModified time.Time
+	for _, u := range urls {
+		set.URLs = append(set.URLs, urlEntry{Loc: u.Loc, LastMod: lastMod(u.LastMod)})
+		if u.LastMod.After(lastModified) {
+			lastModified = u.LastMod
+		}
+	}
+
+	body, err := encode(set)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Body: body, LastModified: lastModified}, nil
+}
+
+func lastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func encode(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// WriteRobots writes robots.txt for the configured rules, pointing crawlers
+// at the sitemap.
+func WriteRobots(w io.Writer, site config.SiteConfig, cfg config.SitemapConfig) error {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	for _, path := range cfg.RobotsAllow {
+		fmt.Fprintf(&b, "Allow: %s\n", path)
+	}
+	for _, path := range cfg.RobotsDisallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	if len(cfg.RobotsAllow) == 0 && len(cfg.RobotsDisallow) == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", site.BaseURL)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
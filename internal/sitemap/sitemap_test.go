@@ -0,0 +1,185 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+)
+
+func testURLs(n int, newest time.Time) []URL {
+	urls := make([]URL, n)
+	for i := range urls {
+		urls[i] = URL{Loc: fmt.Sprintf("https://example.com/posts/%d", i), LastMod: newest.Add(-time.Duration(i) * time.Minute)}
+	}
+	return urls
+}
+
+func TestRender(t *testing.T) {
+	g := &Generator{site: config.SiteConfig{BaseURL: "https://example.com"}}
+	newest := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		urls      []URL
+		wantPages int
+		// wantRoot is how many url entries, or sitemap entries for an
+		// index, the root document has.
+		wantRoot int
+	}{
+		{"home only", []URL{{Loc: "https://example.com/"}}, 0, 1},
+		{"single document", testURLs(3, newest), 0, 3},
+		{"at the limit", testURLs(MaxURLs, newest), 0, MaxURLs},
+		{"just over the limit", testURLs(MaxURLs+1, newest), 2, 2},
+		{"several pages", testURLs(2*MaxURLs+10, newest), 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := g.render(tt.urls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.pages) != tt.wantPages {
+				t.Fatalf("pages = %d, want %d", len(s.pages), tt.wantPages)
+			}
+
+			body := string(s.root.Body)
+			if !strings.HasPrefix(body, xml.Header) {
+				t.Errorf("root does not start with the XML header: %.60q", body)
+			}
+			if tt.wantPages == 0 {
+				var set urlset
+				if err := xml.Unmarshal(s.root.Body, &set); err != nil {
+					t.Fatal(err)
+				}
+				if set.XMLNS != namespace || len(set.URLs) != tt.wantRoot {
+					t.Errorf("urlset has namespace %q and %d URLs, want %q and %d", set.XMLNS, len(set.URLs), namespace, tt.wantRoot)
+				}
+				return
+			}
+
+			var index sitemapIndex
+			if err := xml.Unmarshal(s.root.Body, &index); err != nil {
+				t.Fatal(err)
+			}
+			if len(index.Sitemaps) != tt.wantRoot {
+				t.Fatalf("index lists %d sitemaps, want %d", len(index.Sitemaps), tt.wantRoot)
+			}
+			total := 0
+			for i, entry := range index.Sitemaps {
+				if want := fmt.Sprintf("https://example.com/sitemaps/%d.xml", i+1); entry.Loc != want {
+					t.Errorf("sitemap %d at %q, want %q", i, entry.Loc, want)
+				}
+				var set urlset
+				if err := xml.Unmarshal(s.pages[i].Body, &set); err != nil {
+					t.Fatal(err)
+				}
+				if len(set.URLs) > MaxURLs {
+					t.Errorf("page %d has %d URLs, more than %d", i+1, len(set.URLs), MaxURLs)
+				}
+				if entry.LastMod != lastMod(s.pages[i].LastModified) {
+					t.Errorf("sitemap %d lastmod %q, want %q", i, entry.LastMod, lastMod(s.pages[i].LastModified))
+				}
+				total += len(set.URLs)
+			}
+			if total != len(tt.urls) {
+				t.Errorf("pages list %d URLs, want %d", total, len(tt.urls))
+			}
+			if !s.root.LastModified.Equal(newest) {
+				t.Errorf("index last modified %v, want %v", s.root.LastModified, newest)
+			}
+		})
+	}
+}
+
+func TestRenderURLs(t *testing.T) {
+	older := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	newer := older.Add(time.Hour)
+	doc, err := renderURLs([]URL{
+		{Loc: "https://example.com/"},
+		{Loc: "https://example.com/posts/a?x=1&y=2", LastMod: older},
+		{Loc: "https://example.com/posts/b", LastMod: newer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var set urlset
+	if err := xml.Unmarshal(doc.Body, &set); err != nil {
+		t.Fatal(err)
+	}
+	want := []urlEntry{
+		{Loc: "https://example.com/"},
+		{Loc: "https://example.com/posts/a?x=1&y=2", LastMod: "2024-01-02T02:04:05Z"},
+		{Loc: "https://example.com/posts/b", LastMod: "2024-01-02T03:04:05Z"},
+	}
+	if len(set.URLs) != len(want) {
+		t.Fatalf("got %d URLs, want %d", len(set.URLs), len(want))
+	}
+	for i := range want {
+		if set.URLs[i] != want[i] {
+			t.Errorf("URL %d = %+v, want %+v", i, set.URLs[i], want[i])
+		}
+	}
+	if strings.Contains(string(doc.Body), "<lastmod></lastmod>") {
+		t.Error("empty lastmod written for a URL without one")
+	}
+	if !doc.LastModified.Equal(newer) {
+		t.Errorf("LastModified = %v, want %v", doc.LastModified, newer)
+	}
+}
+
+func TestWriteRobots(t *testing.T) {
+	site := config.SiteConfig{BaseURL: "https://example.com"}
+	tests := []struct {
+		name string
+		cfg  config.SitemapConfig
+		want string
+	}{
+		{
+			"allow everything",
+			config.SitemapConfig{},
+			"User-agent: *\nDisallow:\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			"rules",
+			config.SitemapConfig{RobotsAllow: []string{"/posts/"}, RobotsDisallow: []string{"/api/", "/admin/"}},
+			"User-agent: *\nAllow: /posts/\nDisallow: /api/\nDisallow: /admin/\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			"disallow only",
+			config.SitemapConfig{RobotsDisallow: []string{"/"}},
+			"User-agent: *\nDisallow: /\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteRobots(&b, site, tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("WriteRobots wrote\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	g := &Generator{ttl: time.Hour}
+	g.cache = &snapshot{built: time.Now(), root: &Document{Body: []byte("cached")}}
+	doc, err := g.Sitemap()
+	if err != nil || string(doc.Body) != "cached" {
+		t.Fatalf("Sitemap = %q, %v, want the cached document", doc.Body, err)
+	}
+	if _, ok, _ := g.Page(1); ok {
+		t.Error("Page(1) found a page of an unsplit sitemap")
+	}
+	g.Invalidate()
+	if g.cache != nil {
+		t.Error("Invalidate kept the cache")
+	}
+}
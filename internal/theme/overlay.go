@@ -0,0 +1,54 @@
+package theme
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// overlay is a file system whose upper layer shadows the lower one, file by
+// file. Directory listings are merged.
+type overlay struct {
+	upper, lower fs.FS
+}
+
+func (o overlay) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil {
+		info, statErr := f.Stat()
+		if statErr == nil && !info.IsDir() {
+			return f, nil
+		}
+		f.Close()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err = o.lower.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A directory that only exists in the upper layer.
+		return o.upper.Open(name)
+	}
+	return f, err
+}
+
+func (o overlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, upperErr := fs.ReadDir(o.upper, name)
+	lower, lowerErr := fs.ReadDir(o.lower, name)
+	if upperErr != nil && lowerErr != nil {
+		return nil, lowerErr
+	}
+
+	seen := make(map[string]bool, len(upper))
+	entries := append([]fs.DirEntry(nil), upper...)
+	for _, e := range upper {
+		seen[e.Name()] = true
+	}
+	for _, e := range lower {
+		if !seen[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
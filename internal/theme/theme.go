@@ -0,0 +1,169 @@
+// Package theme renders the public HTML site with html/template.
+//
+// A theme is a directory holding templates/ and static/. The built-in theme
+// is embedded in the binary; a theme directory given in the configuration is
+// laid over it, so a theme only needs the files it changes. Every page is
+// rendered from templates/layout.html, the partials in templates/partials/
+// and its own templates/pages/<name>.html, which defines "content".
+package theme
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/models"
+)
+
+//go:embed all:default
+var builtin embed.FS
+
+// Page names. Every theme renders these, falling back to the built-in
+// templates for those it leaves out.
+const (
+	Home     = "home"
+	Post     = "post"
+	Category = "category"
+	Tag      = "tag"
+	Author   = "author"
+	Search   = "search"
+	NotFound = "404"
+)
+
+var Pages = []string{Home, Post, Category, Tag, Author, Search, NotFound}
+
+// Page is the data a page template is executed with.
+type Page struct {
+	Site config.SiteConfig
+	// Title and Description describe the page; the layout adds the site
+	// title.
+	Title       string
+	Description string
+	// Path is the canonical path of the page.
+	Path string
+	// Feed is the path of the page's RSS feed, if it has one.
+	Feed string
+	// SEO is set on post pages.
+	SEO *models.SEO
+
+	Post       *models.Post
+	Posts      []models.Post
+	Category   *models.Category
+	Tag        *models.Tag
+	Author     *models.User
+	Query      string
+	Pagination Pagination
+}
+
+// Pagination links list pages together. Prev and Next are empty at either
+// end.
+type Pagination struct {
+	Page int
+	Prev string
+	Next string
+}
+
+type Theme struct {
+	files  fs.FS
+	site   config.SiteConfig
+	reload bool
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+}
+
+// New loads the theme, failing if any of its templates does not parse.
+func New(cfg config.ThemeConfig, site config.SiteConfig) (*Theme, error) {
+	files, err := fs.Sub(builtin, "default")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Dir != "" {
+		if _, err := os.Stat(cfg.Dir); err != nil {
+			return nil, fmt.Errorf("theme directory: %w", err)
+		}
+		files = overlay{upper: os.DirFS(cfg.Dir), lower: files}
+	}
+
+	t := &Theme{files: files, site: site, reload: cfg.Reload}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render executes the named page with data. Output is buffered, so nothing
+// is written to w if the template fails.
+func (t *Theme) Render(w io.Writer, name string, data *Page) error {
+	if t.reload {
+		if err := t.load(); err != nil {
+			return err
+		}
+	}
+
+	t.mu.RLock()
+	tmpl, ok := t.pages[name]
+	t.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("theme: no page %q", name)
+	}
+
+	data.Site = t.site
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Static is the theme's static assets, served under /static/.
+func (t *Theme) Static() fs.FS {
+	static, _ := fs.Sub(t.files, "static")
+	return static
+}
+
+func (t *Theme) load() error {
+	partials, err := fs.Glob(t.files, "templates/partials/*.html")
+	if err != nil {
+		return err
+	}
+
+	pages := make(map[string]*template.Template, len(Pages))
+	for _, name := range Pages {
+		files := append([]string{"templates/layout.html"}, partials...)
+		files = append(files, path.Join("templates/pages", name+".html"))
+		tmpl, err := template.New(name).Funcs(t.funcs()).ParseFS(t.files, files...)
+		if err != nil {
+			return fmt.Errorf("theme: page %s: %w", name, err)
+		}
+		pages[name] = tmpl
+	}
+
+	t.mu.Lock()
+	t.pages = pages
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Theme) funcs() template.FuncMap {
+	return template.FuncMap{
+		// url makes a site path absolute.
+		"url": t.site.URL,
+		// asset is the path of a static file.
+		"asset": func(name string) string { return "/static/" + name },
+		"date":  func(ts time.Time) string { return ts.Format("January 2, 2006") },
+		"iso":   func(ts time.Time) string { return ts.UTC().Format(time.RFC3339) },
+		// safeHTML marks HTML that has already been sanitized, such as
+		// Post.HTML, as safe to output unescaped.
+		"safeHTML": func(s string) template.HTML { return template.HTML(s) },
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"github.com/Realwale/scribana/internal/handlers"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -12,9 +19,19 @@ import (
 	"gorm.io/gorm"
 
 	_ "github.com/Realwale/scribana/docs"
+	"github.com/Realwale/scribana/internal/config"
+	"github.com/Realwale/scribana/internal/events"
+	"github.com/Realwale/scribana/internal/export"
+	"github.com/Realwale/scribana/internal/feed"
+	"github.com/Realwale/scribana/internal/importer"
+	"github.com/Realwale/scribana/internal/jobs"
 	"github.com/Realwale/scribana/internal/middleware"
 	"github.com/Realwale/scribana/internal/models"
+	"github.com/Realwale/scribana/internal/realtime"
+	"github.com/Realwale/scribana/internal/seo"
 	"github.com/Realwale/scribana/internal/services"
+	"github.com/Realwale/scribana/internal/sitemap"
+	"github.com/Realwale/scribana/internal/theme"
 	"github.com/Realwale/scribana/pkg/storage"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -45,26 +62,103 @@ func main() {
 	err = db.AutoMigrate(
 		&models.User{},
 		&models.Post{},
+		&models.Media{},
+		&models.Upload{},
 		&models.Comment{},
 		&models.Category{},
+		&models.Tag{},
+		&models.SpamToken{},
+		&models.SpamClass{},
+		&models.Report{},
+		&models.ModerationAction{},
+		&models.Reaction{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.OutboxEvent{},
+		&models.Job{},
+		&models.ImportRecord{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Posts published before publication dates were tracked
+	err = db.Model(&models.Post{}).
+		Where("status = ? AND published_at IS NULL", models.PostPublished).
+		Update("published_at", gorm.Expr("created_at")).Error
+	if err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	cfg := config.Load()
+
+	// Live updates, shared between replicas through Postgres
+	hub := realtime.NewHub()
+	relay := realtime.NewPostgresRelay(db, dsn, hub)
+	hub.SetRelay(relay)
+
+	// Domain events, relayed to asynchronous subscribers through the outbox
+	bus := events.NewBus(db)
+
+	// Background jobs; handlers are registered by the services below
+	queue := jobs.NewQueue(db, cfg.Jobs)
+
 	// Initialize services
 	authService := services.NewAuthService(db)
+	spamService := services.NewSpamService(db, cfg.Spam)
+	if err := spamService.Load(); err != nil {
+		log.Fatal("Failed to load spam classifier:", err)
+	}
+	notificationService := services.NewNotificationService(db, hub)
+	webhookService := services.NewWebhookService(db, cfg.Webhooks, queue)
+	postService := services.NewPostService(db, bus, queue)
+	moderationService := services.NewModerationService(db, cfg.Moderation, spamService, bus)
+	reactionService := services.NewReactionService(db, cfg.Reactions, bus)
+	services.RegisterSubscribers(bus, db, notificationService, webhookService, reactionService, hub)
 
-	// Setup upload directory
-	uploadDir := filepath.Join("uploads")
-	storageService := storage.NewStorage(uploadDir)
-	uploadHandler := handlers.NewUploadHandler(storageService)
+	sitemaps := sitemap.NewGenerator(db, cfg.Site, cfg.Sitemap)
+
+	// Uploaded files
+	storageService, err := newStorage(cfg.Storage)
+	if err != nil {
+		log.Fatal("Failed to set up storage:", err)
+	}
+	mediaService := services.NewMediaService(db, storageService, queue, cfg.Images, cfg.UploadGC, cfg.Uploads, cfg.PrivateMedia)
+	if err := mediaService.ScheduleGC(cfg.UploadGC.Schedule); err != nil {
+		log.Fatal("Invalid UPLOAD_GC_SCHEDULE:", err)
+	}
+	mediaService.CheckPrivate(context.Background())
+	uploadHandler := handlers.NewUploadHandler(db, mediaService)
+	tusHandler := handlers.NewTusHandler(db, mediaService)
+	mediaHandler := handlers.NewMediaHandler(db, mediaService)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
-	postHandler := handlers.NewPostHandler(db)
-	commentHandler := handlers.NewCommentHandler(db)
+	authHandler := handlers.NewAuthHandler(authService, bus)
+	seoBuilder := &seo.Builder{Site: cfg.Site}
+	postHandler := handlers.NewPostHandler(db, postService, reactionService, mediaService, bus, seoBuilder)
+	commentHandler := handlers.NewCommentHandler(db, moderationService, spamService, bus)
+	moderationHandler := handlers.NewModerationHandler(db, moderationService)
+	reportHandler := handlers.NewReportHandler(db, moderationService)
+	reactionHandler := handlers.NewReactionHandler(reactionService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	eventsHandler := handlers.NewEventsHandler(db, hub, authService)
+	webhookHandler := handlers.NewWebhookHandler(db, webhookService)
+	jobHandler := handlers.NewJobHandler(db, queue)
+	markdownImporter := importer.NewMarkdownImporter(db, bus, postService)
+	markdownHandler := handlers.NewMarkdownHandler(markdownImporter)
 	categoryHandler := handlers.NewCategoryHandler(db)
+	feedHandler := handlers.NewFeedHandler(db, mediaService, &feed.Builder{
+		Site:        cfg.Site,
+		FullContent: cfg.Feeds.FullContent,
+	}, cfg.Feeds)
+	sitemapHandler := handlers.NewSitemapHandler(sitemaps, cfg.Site, cfg.Sitemap)
+	siteTheme, err := theme.New(cfg.Theme, cfg.Site)
+	if err != nil {
+		log.Fatal("Failed to load theme:", err)
+	}
+	siteHandler := handlers.NewSiteHandler(db, mediaService, siteTheme, seoBuilder, cfg.Theme)
 
 	// Initialize Gin router
 	r := gin.Default()
@@ -88,8 +182,19 @@ func main() {
 		}
 
 		// Public post routes
-		api.GET("/posts", postHandler.GetPosts)
-		api.GET("/posts/:slug", postHandler.GetPost)
+		public := api.Group("/")
+		public.Use(middleware.OptionalAuthMiddleware())
+		{
+			public.GET("/posts", postHandler.GetPosts)
+			public.GET("/posts/:slug", postHandler.GetPost)
+			public.GET("/posts/:slug/events", middleware.OptionalStreamAuthMiddleware(), eventsHandler.PostEvents)
+			public.GET("/reactions", reactionHandler.ListReactions)
+			public.GET("/reactions/types", reactionHandler.ListTypes)
+			public.OPTIONS("/uploads/tus", tusHandler.Options)
+		}
+
+		// The current user's event stream, opened with a stream token
+		api.GET("/me/events", middleware.StreamAuthMiddleware(), eventsHandler.UserEvents)
 
 		// Protected routes
 		protected := api.Group("/")
@@ -111,6 +216,36 @@ func main() {
 				comments.DELETE("/:id", commentHandler.DeleteComment)
 			}
 
+			// Moderation (Admins and moderators)
+			moderation := protected.Group("/moderation")
+			moderation.Use(middleware.RoleMiddleware(models.ModeratorRole))
+			{
+				moderation.GET("/comments", moderationHandler.ListComments)
+				moderation.POST("/comments/bulk", moderationHandler.BulkModerate)
+				moderation.GET("/reports", moderationHandler.ListReports)
+				moderation.POST("/reports/:id/actions", moderationHandler.ResolveReport)
+				moderation.POST("/users/:id/actions", moderationHandler.SanctionUser)
+			}
+
+			// Reports
+			protected.POST("/reports", reportHandler.CreateReport)
+
+			// Reactions
+			protected.POST("/reactions", reactionHandler.ToggleReaction)
+
+			// Live updates for the current user
+			protected.POST("/me/events/token", eventsHandler.StreamToken)
+
+			// Current user's notifications
+			notifications := protected.Group("/me/notifications")
+			{
+				notifications.GET("", notificationHandler.ListNotifications)
+				notifications.POST("/:id/read", notificationHandler.MarkRead)
+				notifications.POST("/read-all", notificationHandler.MarkAllRead)
+				notifications.GET("/preferences", notificationHandler.GetPreferences)
+				notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
+			}
+
 			// Categories (Admin only)
 			categories := protected.Group("/categories")
 			categories.Use(middleware.RoleMiddleware(models.AdminRole))
@@ -120,20 +255,125 @@ func main() {
 				categories.DELETE("/:id", categoryHandler.DeleteCategory)
 			}
 
+			// Webhooks, background jobs and bulk import/export (Admin only)
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RoleMiddleware(models.AdminRole))
+			{
+				admin.GET("/webhooks", webhookHandler.ListWebhooks)
+				admin.POST("/webhooks", webhookHandler.CreateWebhook)
+				admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+				admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+				admin.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+				admin.POST("/webhook-deliveries/:id/redeliver", webhookHandler.Redeliver)
+				admin.GET("/jobs", jobHandler.ListJobs)
+				admin.GET("/jobs/:id", jobHandler.GetJob)
+				admin.POST("/jobs/:id/retry", jobHandler.RetryJob)
+				admin.POST("/markdown/import", markdownHandler.Import)
+				admin.GET("/markdown/export", markdownHandler.Export)
+				admin.GET("/storage", mediaHandler.StorageReport)
+			}
+
 			// Upload routes (restricted to authors and admins)
 			uploads := protected.Group("/uploads")
 			uploads.Use(middleware.RoleMiddleware(models.AuthorRole))
 			{
 				uploads.POST("/image", uploadHandler.UploadImage)
+				uploads.POST("/file", uploadHandler.UploadFile)
+
+				// Resumable uploads through the tus protocol
+				uploads.POST("/tus", tusHandler.Resumable, tusHandler.CreateUpload)
+				uploads.HEAD("/tus/:id", tusHandler.Resumable, tusHandler.UploadOffset)
+				uploads.PATCH("/tus/:id", tusHandler.Resumable, tusHandler.WriteUpload)
+				uploads.DELETE("/tus/:id", tusHandler.Resumable, tusHandler.TerminateUpload)
+				uploads.GET("/tus/:id", tusHandler.GetUpload)
+			}
+
+			// Media library (authors see their own uploads, admins everyone's)
+			media := protected.Group("/media")
+			media.Use(middleware.RoleMiddleware(models.AuthorRole))
+			{
+				media.GET("", mediaHandler.ListMedia)
+				media.GET("/usage", mediaHandler.StorageUsage)
+				media.GET("/:id", mediaHandler.GetMedia)
+				media.PUT("/:id", mediaHandler.UpdateMedia)
+				media.DELETE("/:id", mediaHandler.DeleteMedia)
 			}
 		}
 	}
 
+	// Syndication feeds
+	for _, format := range feed.Formats {
+		r.GET("/"+format.Filename, feedHandler.Blog(format))
+		r.GET("/categories/:slug/"+format.Filename, feedHandler.Category(format))
+		r.GET("/tags/:slug/"+format.Filename, feedHandler.Tag(format))
+		r.GET("/authors/:username/"+format.Filename, feedHandler.Author(format))
+	}
+	r.GET("/podcast.xml", feedHandler.Podcast)
+
+	// Search engines
+	r.GET("/sitemap.xml", sitemapHandler.Sitemap)
+	r.GET("/sitemaps/:file", sitemapHandler.Page)
+	r.GET("/robots.txt", sitemapHandler.Robots)
+
 	// Add Swagger documentation route
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Serve static files
-	r.Static("/uploads", uploadDir)
+	// Serve uploaded files kept on the local disk, except private media
+	if local, ok := storageService.(*storage.Local); ok && strings.HasPrefix(local.BaseURL, "/") {
+		r.Group(local.BaseURL, mediaHandler.HidePrivate).Static("/", local.Dir)
+	}
+	// Private media, from signed URLs only
+	r.GET(mediaService.PrivateURL()+"/*filepath", mediaHandler.ServePrivate)
+	r.HEAD(mediaService.PrivateURL()+"/*filepath", mediaHandler.ServePrivate)
+
+	// Public HTML site; always needed to export it
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	if cfg.Theme.Enabled || command == "export" {
+		r.GET("/", siteHandler.Home)
+		r.GET("/page/:page", siteHandler.Home)
+		r.GET("/posts/:slug", siteHandler.Post)
+		r.GET("/categories/:slug", siteHandler.Category)
+		r.GET("/categories/:slug/page/:page", siteHandler.Category)
+		r.GET("/tags/:slug", siteHandler.Tag)
+		r.GET("/tags/:slug/page/:page", siteHandler.Tag)
+		r.GET("/authors/:username", siteHandler.Author)
+		r.GET("/authors/:username/page/:page", siteHandler.Author)
+		r.GET("/search", siteHandler.Search)
+		r.GET("/static/*filepath", siteHandler.Static)
+		r.NoRoute(siteHandler.NotFound)
+	}
+
+	switch command {
+	case "":
+	case "export":
+		runExport(r, db, cfg, siteTheme, storageService, mediaService, os.Args[2:])
+		return
+	case "import-wordpress":
+		runImportWordPress(importer.NewWordPressImporter(db, storageService, postService), os.Args[2:])
+		return
+	case "import-markdown":
+		runImportMarkdown(markdownImporter, db, os.Args[2:])
+		return
+	case "export-markdown":
+		runExportMarkdown(markdownImporter, os.Args[2:])
+		return
+	case "gc-uploads":
+		runGCUploads(mediaService, cfg.UploadGC, os.Args[2:])
+		return
+	default:
+		log.Fatalf("Unknown command %q", command)
+	}
+
+	// Background work
+	ctx := context.Background()
+	go relay.Listen(ctx)
+	go bus.Run(ctx, cfg.Events.PollInterval)
+	go queue.Run(ctx)
+	go sitemaps.Listen(ctx, hub)
+	go spamService.Listen(ctx, hub)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -142,3 +382,252 @@ func main() {
 	}
 	r.Run(":" + port)
 }
+
+// newStorage returns the storage backend selected by the configuration.
+func newStorage(cfg config.StorageConfig) (storage.Storage, error) {
+	switch cfg.Driver {
+	case "local":
+		publicURL := cfg.PublicURL
+		if publicURL == "" {
+			publicURL = "/uploads"
+		}
+		return storage.NewLocal(cfg.LocalDir, publicURL)
+	case "s3":
+		return storage.NewS3(storage.S3Options{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			Prefix:          cfg.S3.Prefix,
+			PathStyle:       cfg.S3.PathStyle,
+			PublicURL:       cfg.PublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// runExport implements "export": it renders the public site into a directory
+// of static files.
+func runExport(site http.Handler, db *gorm.DB, cfg *config.Config, siteTheme *theme.Theme, uploads storage.Storage, media *services.MediaService, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	out := flags.String("out", "public", "output directory")
+	full := flags.Bool("full", false, "rewrite every file instead of only the changed ones")
+	flags.Parse(args)
+
+	exporter := &export.Exporter{
+		Handler:       site,
+		DB:            db,
+		Static:        siteTheme.Static(),
+		Uploads:       uploads,
+		PrivateURL:    media.PrivateURL(),
+		PrivatePrefix: services.PrivatePrefix,
+		PageSize:      cfg.Theme.PageSize,
+		Podcast:       cfg.Feeds.Podcast.Category != "",
+	}
+	report, err := exporter.Export(export.Options{Dir: *out, Full: *full})
+	if err != nil {
+		log.Fatal("Export failed:", err)
+	}
+	log.Printf("Exported to %s: %d written, %d unchanged, %d removed, %d uploads",
+		*out, report.Written, report.Unchanged, report.Removed, report.Uploads)
+}
+
+// runImportWordPress implements "import-wordpress": it imports a WordPress
+// WXR export file.
+func runImportWordPress(wordpress *importer.WordPress, args []string) {
+	flags := flag.NewFlagSet("import-wordpress", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without changing anything")
+	skipAttachments := flags.Bool("skip-attachments", false, "link to attachments on the old site instead of downloading them")
+	reportFile := flags.String("report", "", "write the full report as JSON to this file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: scribana import-wordpress [flags] export.xml")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal("Import failed:", err)
+	}
+	defer file.Close()
+
+	report, err := wordpress.Import(file, importer.WordPressOptions{DryRun: *dryRun, SkipAttachments: *skipAttachments})
+	if err != nil {
+		log.Fatal("Import failed:", err)
+	}
+
+	printImportReport(report, *reportFile)
+}
+
+// printImportReport prints the summary and warnings of an import, and writes
+// the full report to reportFile if one is given.
+func printImportReport(report *importer.Report, reportFile string) {
+	if report.DryRun {
+		fmt.Println("Dry run: nothing was changed.")
+	}
+	for _, line := range report.Summary() {
+		fmt.Println(line)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Println("warning:", warning)
+	}
+	if reportFile != "" {
+		body, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(reportFile, body, 0644)
+		}
+		if err != nil {
+			log.Fatal("Failed to write report:", err)
+		}
+	}
+}
+
+// runImportMarkdown implements "import-markdown": it imports a directory or
+// zip archive of Markdown posts.
+func runImportMarkdown(markdown *importer.Markdown, db *gorm.DB, args []string) {
+	flags := flag.NewFlagSet("import-markdown", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without changing anything")
+	authorName := flags.String("author", "", "username owning new posts whose front matter names no author")
+	reportFile := flags.String("report", "", "write the full report as JSON to this file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: scribana import-markdown [flags] <directory|archive.zip>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var author models.User
+	if *authorName != "" {
+		if err := db.Where("username = ?", *authorName).First(&author).Error; err != nil {
+			log.Fatalf("Unknown author %q", *authorName)
+		}
+	}
+
+	source := flags.Arg(0)
+	var files []importer.MarkdownFile
+	var err error
+	if strings.EqualFold(filepath.Ext(source), ".zip") {
+		var archive *os.File
+		if archive, err = os.Open(source); err == nil {
+			defer archive.Close()
+			var info os.FileInfo
+			if info, err = archive.Stat(); err == nil {
+				files, err = importer.ReadMarkdownZip(archive, info.Size())
+			}
+		}
+	} else {
+		files, err = importer.ReadMarkdownDir(source)
+	}
+	if err != nil {
+		log.Fatal("Import failed:", err)
+	}
+
+	report, err := markdown.Import(files, importer.MarkdownOptions{DryRun: *dryRun, AuthorID: author.ID, ActorID: author.ID})
+	if err != nil {
+		log.Fatal("Import failed:", err)
+	}
+	printImportReport(report, *reportFile)
+}
+
+// runExportMarkdown implements "export-markdown": it writes every post as a
+// Markdown file to a directory or zip archive.
+func runExportMarkdown(markdown *importer.Markdown, args []string) {
+	flags := flag.NewFlagSet("export-markdown", flag.ExitOnError)
+	out := flags.String("out", "posts", "output directory, or a .zip archive")
+	status := flags.String("status", "", "only export posts with this status")
+	flags.Parse(args)
+
+	var write func(name string, data []byte) error
+	finish := func() error { return nil }
+	if strings.EqualFold(filepath.Ext(*out), ".zip") {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal("Export failed:", err)
+		}
+		archive := zip.NewWriter(file)
+		write = func(name string, data []byte) error {
+			w, err := archive.Create(name)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		finish = func() error {
+			if err := archive.Close(); err != nil {
+				return err
+			}
+			return file.Close()
+		}
+	} else {
+		if err := os.MkdirAll(*out, 0755); err != nil {
+			log.Fatal("Export failed:", err)
+		}
+		write = func(name string, data []byte) error {
+			return os.WriteFile(filepath.Join(*out, name), data, 0644)
+		}
+	}
+
+	count, err := markdown.Export(write, importer.MarkdownExportOptions{Status: models.PostStatus(*status)})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		log.Fatal("Export failed:", err)
+	}
+	log.Printf("Exported %d posts to %s", count, *out)
+}
+
+// runGCUploads implements "gc-uploads": it quarantines uploads nothing
+// refers to and deletes those quarantined long enough.
+func runGCUploads(media *services.MediaService, cfg config.UploadGCConfig, args []string) {
+	flags := flag.NewFlagSet("gc-uploads", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be collected without changing anything")
+	grace := flags.Duration("grace", cfg.GracePeriod, "spare uploads newer than this")
+	quarantine := flags.Duration("quarantine", cfg.QuarantinePeriod, "delete uploads quarantined for longer than this")
+	reportFile := flags.String("report", "", "write the full report as JSON to this file")
+	flags.Parse(args)
+
+	report, err := media.CollectGarbage(context.Background(), services.GCOptions{
+		DryRun:           *dryRun,
+		GracePeriod:      *grace,
+		QuarantinePeriod: *quarantine,
+	})
+	if err != nil {
+		log.Fatal("Upload collection failed:", err)
+	}
+
+	if report.DryRun {
+		fmt.Println("Dry run: nothing was changed.")
+	}
+	for _, line := range report.Summary() {
+		fmt.Println(line)
+	}
+	for _, item := range report.Quarantined {
+		fmt.Println("quarantine", item.Key)
+	}
+	for _, item := range report.Restored {
+		fmt.Println("restore   ", item.Key)
+	}
+	for _, item := range report.Deleted {
+		fmt.Println("delete    ", item.Key)
+	}
+	if *reportFile != "" {
+		body, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*reportFile, body, 0644)
+		}
+		if err != nil {
+			log.Fatal("Failed to write report:", err)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package audio reads the duration of uploaded audio files without decoding
+// them: MP3 from its frame headers and M4A from its movie header.
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupported is returned for formats whose duration cannot be read.
+	ErrUnsupported = errors.New("audio: unsupported format")
+	// ErrInvalid is returned for files that are not valid audio of their format.
+	ErrInvalid = errors.New("audio: invalid file")
+)
+
+// Duration reads the playing time of an audio file of size bytes, in the
+// format named by its extension, from r. Only as much of r is read as
+// needed, which for M4A files whose index follows the audio is all of it.
+func Duration(r io.Reader, size int64, ext string) (time.Duration, error) {
+	switch strings.ToLower(ext) {
+	case ".mp3":
+		return mp3Duration(bufio.NewReader(r), size)
+	case ".m4a", ".mp4":
+		return mp4Duration(bufio.NewReader(r))
+	}
+	return 0, ErrUnsupported
+}
+
+// mp3Duration counts the frames announced by a Xing, Info or VBRI header
+// in the first frame; files without one are constant bitrate, so their
+// duration follows from the size of the audio and its bitrate.
+func mp3Duration(r *bufio.Reader, size int64) (time.Duration, error) {
+	var offset int64
+	// An ID3v2 tag may precede the audio: "ID3", version, flags and a
+	// synchsafe size that excludes the 10-byte header.
+	header, err := r.Peek(10)
+	if err == nil && string(header[:3]) == "ID3" {
+		tagSize := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+		if header[5]&0x10 != 0 {
+			tagSize += 10 // footer
+		}
+		if _, err := r.Discard(int(10 + tagSize)); err != nil {
+			return 0, ErrInvalid
+		}
+		offset = 10 + tagSize
+	}
+
+	// Skip any padding up to the first frame sync.
+	var frame mp3Frame
+	for skipped := 0; ; skipped++ {
+		if skipped > 64<<10 {
+			return 0, ErrInvalid
+		}
+		header, err := r.Peek(4)
+		if err != nil {
+			return 0, ErrInvalid
+		}
+		if f, ok := parseMP3Frame(header); ok {
+			frame = f
+			break
+		}
+		r.Discard(1)
+		offset++
+	}
+
+	// The Xing or Info header follows the side information; VBRI sits at
+	// a fixed offset.
+	data, _ := r.Peek(frame.length)
+	if frames, ok := xingFrames(data, frame); ok {
+		return frame.duration(frames), nil
+	}
+	if len(data) >= 36+18 && string(data[36:40]) == "VBRI" {
+		return frame.duration(int64(binary.BigEndian.Uint32(data[50:54]))), nil
+	}
+
+	audioBytes := size - offset
+	if audioBytes <= 0 || frame.bitrate == 0 {
+		return 0, ErrInvalid
+	}
+	return seconds(float64(audioBytes*8) / float64(frame.bitrate)), nil
+}
+
+type mp3Frame struct {
+	mpeg1      bool
+	mono       bool
+	bitrate    int // bits per second
+	sampleRate int
+	samples    int // per frame
+	length     int // bytes
+}
+
+func (f mp3Frame) duration(frames int64) time.Duration {
+	return seconds(float64(frames) * float64(f.samples) / float64(f.sampleRate))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+var (
+	// mp3Bitrates are in kbit/s by [MPEG-1][layer][index], layers I to III.
+	mp3Bitrates = [2][3][16]int{
+		{ // MPEG-2 and 2.5
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+		{ // MPEG-1
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+	}
+	// mp3SampleRates are for MPEG-1; MPEG-2 halves them and MPEG-2.5
+	// quarters them.
+	mp3SampleRates = [3]int{44100, 48000, 32000}
+)
+
+// parseMP3Frame decodes a frame header.
+func parseMP3Frame(h []byte) (mp3Frame, bool) {
+	if h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return mp3Frame{}, false
+	}
+	version := h[1] >> 3 & 3 // 0: MPEG-2.5, 2: MPEG-2, 3: MPEG-1
+	layer := 4 - int(h[1]>>1&3)
+	bitrateIndex := h[2] >> 4
+	rateIndex := h[2] >> 2 & 3
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	f := mp3Frame{mpeg1: version == 3, mono: h[3]>>6 == 3}
+	mpeg1 := 0
+	if f.mpeg1 {
+		mpeg1 = 1
+	}
+	f.bitrate = mp3Bitrates[mpeg1][layer-1][bitrateIndex] * 1000
+	f.sampleRate = mp3SampleRates[rateIndex]
+	switch version {
+	case 2:
+		f.sampleRate /= 2
+	case 0:
+		f.sampleRate /= 4
+	}
+
+	padding := int(h[2] >> 1 & 1)
+	switch {
+	case layer == 1:
+		f.samples = 384
+		f.length = (12*f.bitrate/f.sampleRate + padding) * 4
+	case layer == 3 && !f.mpeg1:
+		f.samples = 576
+		f.length = 72*f.bitrate/f.sampleRate + padding
+	default:
+		f.samples = 1152
+		f.length = 144*f.bitrate/f.sampleRate + padding
+	}
+	return f, true
+}
+
+// xingFrames reads the frame count of a Xing or Info header in the first
+// frame.
+func xingFrames(data []byte, f mp3Frame) (int64, bool) {
+	sideInfo := 17
+	switch {
+	case f.mpeg1 && !f.mono:
+		sideInfo = 32
+	case !f.mpeg1 && f.mono:
+		sideInfo = 9
+	}
+	at := 4 + sideInfo
+	if len(data) < at+12 {
+		return 0, false
+	}
+	if tag := string(data[at : at+4]); tag != "Xing" && tag != "Info" {
+		return 0, false
+	}
+	if binary.BigEndian.Uint32(data[at+4:at+8])&1 == 0 {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint32(data[at+8 : at+12])), true
+}
+
+// mp4Duration walks the top-level boxes to the movie header, skipping over
+// the media data, and reads the duration from it.
+func mp4Duration(r *bufio.Reader) (time.Duration, error) {
+	for {
+		name, size, err := mp4Box(r)
+		if err != nil {
+			return 0, err
+		}
+		if name == "moov" {
+			if size < 0 {
+				return mvhdDuration(r)
+			}
+			return mvhdDuration(io.LimitReader(r, size))
+		}
+		if size < 0 {
+			return 0, ErrInvalid
+		}
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return 0, ErrInvalid
+		}
+	}
+}
+
+// mvhdDuration finds the movie header among the boxes in moov.
+func mvhdDuration(r io.Reader) (time.Duration, error) {
+	br := bufio.NewReader(r)
+	for {
+		name, size, err := mp4Box(br)
+		if err != nil {
+			return 0, err
+		}
+		if name != "mvhd" {
+			if size < 0 {
+				return 0, ErrInvalid
+			}
+			if _, err := io.CopyN(io.Discard, br, size); err != nil {
+				return 0, ErrInvalid
+			}
+			continue
+		}
+
+		// Version and flags, then creation and modification times, the
+		// timescale and the duration; times are 64-bit in version 1.
+		var header [32]byte
+		if _, err := io.ReadFull(br, header[:4]); err != nil {
+			return 0, ErrInvalid
+		}
+		var timescale, duration uint64
+		if header[0] == 1 {
+			if _, err := io.ReadFull(br, header[:28]); err != nil {
+				return 0, ErrInvalid
+			}
+			timescale = uint64(binary.BigEndian.Uint32(header[16:20]))
+			duration = binary.BigEndian.Uint64(header[20:28])
+		} else {
+			if _, err := io.ReadFull(br, header[:16]); err != nil {
+				return 0, ErrInvalid
+			}
+			timescale = uint64(binary.BigEndian.Uint32(header[8:12]))
+			duration = uint64(binary.BigEndian.Uint32(header[12:16]))
+		}
+		if timescale == 0 {
+			return 0, ErrInvalid
+		}
+		return seconds(float64(duration) / float64(timescale)), nil
+	}
+}
+
+// mp4Box reads a box header, returning the box type and the size of its
+// contents, or -1 for a box running to the end of the file.
+func mp4Box(r io.Reader) (string, int64, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		return "", 0, ErrInvalid
+	}
+	size := int64(binary.BigEndian.Uint32(header[:4]))
+	name := string(header[4:8])
+	switch size {
+	case 0:
+		return name, -1, nil
+	case 1:
+		if _, err := io.ReadFull(r, header[8:16]); err != nil {
+			return "", 0, ErrInvalid
+		}
+		size = int64(binary.BigEndian.Uint64(header[8:16])) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", 0, ErrInvalid
+	}
+	return name, size, nil
+}
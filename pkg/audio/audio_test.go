@@ -0,0 +1,229 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Frame headers: MPEG-1 layer III at 128 kbit/s, 44.1 kHz, stereo, whose
+// frames are 417 bytes long, and MPEG-2 layer III at 64 kbit/s, 22.05 kHz,
+// mono, whose frames are 208 bytes long.
+var (
+	mpeg1Stereo = []byte{0xFF, 0xFB, 0x90, 0x00}
+	mpeg2Mono   = []byte{0xFF, 0xF3, 0x80, 0xC0}
+)
+
+// mp3Frames returns n frames with the given header and silent content.
+func mp3Frames(header []byte, length, n int) []byte {
+	var out []byte
+	for i := 0; i < n; i++ {
+		frame := make([]byte, length)
+		copy(frame, header)
+		out = append(out, frame...)
+	}
+	return out
+}
+
+// withTag puts tag at offset in the first frame of frames, followed by the
+// flags and a frame count.
+func withTag(frames []byte, offset int, tag string, count uint32) []byte {
+	out := append([]byte(nil), frames...)
+	copy(out[offset:], tag)
+	binary.BigEndian.PutUint32(out[offset+4:], 1)
+	binary.BigEndian.PutUint32(out[offset+8:], count)
+	return out
+}
+
+// id3 returns an ID3v2 tag with size bytes of content.
+func id3(size int, footer bool) []byte {
+	flags := byte(0)
+	if footer {
+		flags = 0x10
+	}
+	tag := []byte{'I', 'D', '3', 4, 0, flags,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	tag = append(tag, make([]byte, size)...)
+	if footer {
+		tag = append(tag, make([]byte, 10)...)
+	}
+	return tag
+}
+
+func TestMP3Duration(t *testing.T) {
+	cbr := mp3Frames(mpeg1Stereo, 417, 100)
+	vbri := append([]byte(nil), cbr...)
+	copy(vbri[36:], "VBRI")
+	binary.BigEndian.PutUint32(vbri[50:], 2000)
+
+	tests := []struct {
+		name string
+		data []byte
+		want time.Duration
+	}{
+		// 41700 bytes at 128 kbit/s.
+		{"constant bitrate", cbr, 2606250 * time.Microsecond},
+		{"id3 tag", append(id3(300, false), cbr...), 2606250 * time.Microsecond},
+		{"id3 tag with footer", append(id3(300, true), cbr...), 2606250 * time.Microsecond},
+		{"padding before the first frame", append(make([]byte, 100), cbr...), 2606250 * time.Microsecond},
+		// 1000 frames of 1152 samples at 44.1 kHz.
+		{"xing", withTag(cbr, 36, "Xing", 1000), 26122448 * time.Microsecond},
+		{"info", withTag(cbr, 36, "Info", 1000), 26122448 * time.Microsecond},
+		// 2000 frames of 1152 samples at 44.1 kHz.
+		{"vbri", vbri, 52244897 * time.Microsecond},
+		// 500 frames of 576 samples at 22.05 kHz.
+		{"mpeg-2 mono xing", withTag(mp3Frames(mpeg2Mono, 208, 10), 13, "Xing", 500), 13061224 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Duration(bytes.NewReader(tt.data), int64(len(tt.data)), ".mp3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := got - tt.want; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("Duration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP3DurationInvalid(t *testing.T) {
+	noFlags := withTag(mp3Frames(mpeg1Stereo, 417, 3), 36, "Xing", 1000)
+	binary.BigEndian.PutUint32(noFlags[40:], 0)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("definitely not audio"), ErrInvalid},
+		{"empty", nil, ErrInvalid},
+		{"no frame sync", make([]byte, 70<<10), ErrInvalid},
+		{"id3 tag longer than the file", id3(300, false)[:100], ErrInvalid},
+		{"bad bitrate", mp3Frames([]byte{0xFF, 0xFB, 0xF0, 0x00}, 417, 2), ErrInvalid},
+		{"bad sample rate", mp3Frames([]byte{0xFF, 0xFB, 0x9C, 0x00}, 417, 2), ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Duration(bytes.NewReader(tt.data), int64(len(tt.data)), ".mp3"); !errors.Is(err, tt.want) {
+				t.Errorf("Duration = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// A Xing header without a frame count falls back to the bitrate.
+	got, err := Duration(bytes.NewReader(noFlags), int64(len(noFlags)), ".mp3")
+	if want := 78187500 * time.Nanosecond; err != nil || got != want {
+		t.Errorf("Duration = %v, %v, want %v", got, err, want)
+	}
+}
+
+// box encodes an MP4 box.
+func box(name string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	out = append(out, name...)
+	return append(out, body...)
+}
+
+// mvhd returns a movie header box of the given version.
+func mvhd(version byte, timescale uint32, duration uint64) []byte {
+	content := []byte{version, 0, 0, 0}
+	if version == 1 {
+		content = append(content, make([]byte, 16)...)
+		content = binary.BigEndian.AppendUint32(content, timescale)
+		content = binary.BigEndian.AppendUint64(content, duration)
+	} else {
+		content = append(content, make([]byte, 8)...)
+		content = binary.BigEndian.AppendUint32(content, timescale)
+		content = binary.BigEndian.AppendUint32(content, uint32(duration))
+	}
+	// Rate, volume, matrix and the rest, which are not read.
+	return box("mvhd", content, make([]byte, 80))
+}
+
+func TestMP4Duration(t *testing.T) {
+	ftyp := box("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	mdat := box("mdat", make([]byte, 5000))
+	trak := box("trak", box("tkhd", make([]byte, 84)))
+
+	// mdat with a 64-bit size.
+	large := binary.BigEndian.AppendUint32(nil, 1)
+	large = append(large, "mdat"...)
+	large = binary.BigEndian.AppendUint64(large, 16+100)
+	large = append(large, make([]byte, 100)...)
+
+	// moov running to the end of the file.
+	open := append([]byte{0, 0, 0, 0}, "moov"...)
+	open = append(open, mvhd(0, 600, 1800)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want time.Duration
+	}{
+		{"index first", bytes.Join([][]byte{ftyp, box("moov", mvhd(0, 1000, 65500), trak), mdat}, nil), 65500 * time.Millisecond},
+		{"index last", bytes.Join([][]byte{ftyp, mdat, box("moov", trak, mvhd(0, 44100, 44100*90))}, nil), 90 * time.Second},
+		{"version 1 header", bytes.Join([][]byte{ftyp, box("moov", mvhd(1, 48000, 48000*3600))}, nil), time.Hour},
+		{"64-bit box size", bytes.Join([][]byte{ftyp, large, box("moov", mvhd(0, 10, 25))}, nil), 2500 * time.Millisecond},
+		{"moov to the end", bytes.Join([][]byte{ftyp, open}, nil), 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Duration(bytes.NewReader(tt.data), int64(len(tt.data)), ".m4a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Duration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMP4DurationInvalid(t *testing.T) {
+	ftyp := box("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	mdat := box("mdat", make([]byte, 5000))
+
+	tests := map[string][]byte{
+		"no moov":            bytes.Join([][]byte{ftyp, mdat}, nil),
+		"no mvhd":            bytes.Join([][]byte{ftyp, box("moov", box("trak"))}, nil),
+		"zero timescale":     bytes.Join([][]byte{ftyp, box("moov", mvhd(0, 0, 100))}, nil),
+		"truncated media":    bytes.Join([][]byte{ftyp, mdat[:1000]}, nil),
+		"truncated header":   bytes.Join([][]byte{ftyp, box("moov", mvhd(0, 1000, 100))[:20]}, nil),
+		"box size too small": append(binary.BigEndian.AppendUint32(nil, 4), "free"...),
+		"empty":              nil,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Duration(bytes.NewReader(data), int64(len(data)), ".m4a"); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Duration = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
+
+func TestDurationFormats(t *testing.T) {
+	cbr := mp3Frames(mpeg1Stereo, 417, 10)
+	m4a := box("moov", mvhd(0, 1, 7))
+	tests := []struct {
+		ext     string
+		data    []byte
+		wantErr error
+	}{
+		{".mp3", cbr, nil},
+		{".MP3", cbr, nil},
+		{".m4a", m4a, nil},
+		{".mp4", m4a, nil},
+		{".ogg", cbr, ErrUnsupported},
+		{".wav", cbr, ErrUnsupported},
+		{"", cbr, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		if _, err := Duration(bytes.NewReader(tt.data), int64(len(tt.data)), tt.ext); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Duration(%q) = %v, want %v", tt.ext, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package cron parses cron expressions and computes when they next fire.
+//
+// Schedules use the standard five fields:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field accepts "*", single values, ranges ("1-5"), steps ("*/15",
+// "0-30/10") and comma separated lists of those. Months and weekdays may be
+// given by their three letter English names, and Sunday is 0 or 7. As in
+// classic cron, when both day fields are restricted a time matches if
+// either of them does.
+//
+// The descriptors @yearly, @annually, @monthly, @weekly, @daily, @midnight
+// and @hourly are accepted as shorthands, as is "@every <duration>" for a
+// fixed interval.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule reports when a recurring job should next run.
+type Schedule interface {
+	// Next returns the first activation time strictly after t, or the zero
+	// time if the schedule never fires again.
+	Next(t time.Time) time.Time
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	dayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, monthNames}
+	// Sunday may be written as 7; it is folded onto 0 after parsing.
+	dows = bounds{0, 7, dayNames}
+)
+
+// Parse parses a cron expression or descriptor.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("cron: invalid interval in %q: %w", spec, err)
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("cron: interval in %q must be at least one second", spec)
+		}
+		return Every(interval), nil
+	}
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields in %q, found %d", spec, len(fields))
+	}
+
+	var s fieldSchedule
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], doms); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dows); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	s.dowStar = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+	return &s, nil
+}
+
+// MustParse is like Parse but panics on an invalid expression. It is meant
+// for schedules fixed at compile time.
+func MustParse(spec string) Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseField turns one field into a bit set of the values it allows.
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, step := b.min, b.max, 1
+
+		rangePart := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("cron: invalid step in %q", part)
+			}
+			step, rangePart = n, part[:i]
+		}
+
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			ends := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = parseValue(ends[0], b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(ends[1], b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("cron: range %q is backwards", rangePart)
+			}
+		default:
+			v, err := parseValue(rangePart, b)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/10" means every 10 starting at 5.
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cron: invalid value %q", s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("cron: value %d out of range %d-%d", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+type fieldSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// searchLimit bounds the search for schedules that can never fire, such as
+// the 30th of February.
+const searchLimit = 5
+
+func (s *fieldSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + searchLimit
+
+	for t.Year() <= yearLimit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *fieldSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Every is a schedule firing at a fixed interval, aligned to multiples of
+// the interval since the Unix epoch so that every replica agrees on the
+// activation times.
+type Every time.Duration
+
+func (e Every) Next(t time.Time) time.Time {
+	// time.Time.Truncate counts from the zero time rather than the epoch,
+	// which differs for intervals that do not divide a day.
+	interval := int64(time.Duration(e))
+	n := t.UnixNano()
+	since := (n%interval + interval) % interval
+	return time.Unix(0, n-since+interval).In(t.Location())
+}
@@ -0,0 +1,117 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"1,,2 * * * *",
+		"@every",
+		"@every soon",
+		"@every 500ms",
+		"@never",
+	}
+	for _, spec := range tests {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"* * * * *", "2024-01-01 10:00:00", "2024-01-01 10:01:00"},
+		{"* * * * *", "2024-01-01 10:00:30", "2024-01-01 10:01:00"},
+		{"*/15 * * * *", "2024-01-01 10:07:00", "2024-01-01 10:15:00"},
+		{"*/15 * * * *", "2024-01-01 10:45:00", "2024-01-01 11:00:00"},
+		{"0-30/10 * * * *", "2024-01-01 10:25:00", "2024-01-01 10:30:00"},
+		{"0-30/10 * * * *", "2024-01-01 10:30:00", "2024-01-01 11:00:00"},
+		{"5/20 * * * *", "2024-01-01 10:30:00", "2024-01-01 10:45:00"},
+		{"0,30 9-17 * * *", "2024-01-01 17:30:00", "2024-01-02 09:00:00"},
+		{"30 2 * * *", "2024-01-01 02:30:00", "2024-01-02 02:30:00"},
+		{"0 0 * * mon-fri", "2024-01-05 12:00:00", "2024-01-08 00:00:00"},
+		{"0 0 * * 7", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"0 0 * * SUN", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"0 0 1 jan *", "2024-03-10 00:00:00", "2025-01-01 00:00:00"},
+		{"0 0 31 * *", "2024-01-31 00:00:00", "2024-03-31 00:00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		// Both day fields restricted: either one matches.
+		{"0 0 15 * fri", "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"0 0 15 * fri", "2024-01-13 00:00:00", "2024-01-15 00:00:00"},
+		// A stepped day field counts as unrestricted.
+		{"0 0 */2 * mon", "2024-01-01 00:00:00", "2024-01-15 00:00:00"},
+		{"@hourly", "2024-01-01 10:00:00", "2024-01-01 11:00:00"},
+		{"@daily", "2024-01-01 10:00:00", "2024-01-02 00:00:00"},
+		{"@weekly", "2024-01-01 10:00:00", "2024-01-07 00:00:00"},
+		{"@monthly", "2024-01-31 10:00:00", "2024-02-01 00:00:00"},
+		{"@yearly", "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+		{"@every 1h", "2024-01-01 10:20:00", "2024-01-01 11:00:00"},
+		{"@every 90s", "2024-01-01 10:00:00", "2024-01-01 10:01:30"},
+		// Multiples of 7h since the epoch, not since year 1.
+		{"@every 7h", "2024-01-01 10:20:00", "2024-01-01 16:00:00"},
+		{"0 0 30 2 *", "2024-01-01 00:00:00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			got := s.Next(at(tt.from))
+			var want time.Time
+			if tt.want != "" {
+				want = at(tt.want)
+			}
+			if !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, want)
+			}
+		})
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	got := MustParse("0 9 * * *").Next(time.Date(2024, 1, 1, 10, 0, 0, 0, loc))
+	want := time.Date(2024, 1, 2, 9, 0, 0, 0, loc)
+	if !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
+
+func TestMustParsePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParse did not panic on an invalid expression")
+		}
+	}()
+	MustParse("not a schedule")
+}
@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// exifTIFF returns TIFF formatted EXIF data whose first IFD holds only an
+// Orientation tag.
+func exifTIFF(order binary.AppendByteOrder, orientation uint16) []byte {
+	var tiff []byte
+	if order == binary.LittleEndian {
+		tiff = []byte("II*\x00")
+	} else {
+		tiff = []byte("MM\x00*")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	return order.AppendUint32(tiff, 0)
+}
+
+// withSegment inserts an APPn segment right after the SOI marker of a JPEG.
+func withSegment(jpegData []byte, marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+len(payload)))
+	segment = append(segment, payload...)
+	out := append([]byte(nil), jpegData[:2]...)
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func withEXIF(jpegData, tiff []byte) []byte {
+	return withSegment(jpegData, 0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := encodeJPEG(t, testImage(8, 8, 255))
+	truncated := withEXIF(plain, exifTIFF(binary.LittleEndian, 6))
+	truncated = truncated[:2+4+10]
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"no exif", plain, 1},
+		{"little endian", withEXIF(plain, exifTIFF(binary.LittleEndian, 6)), 6},
+		{"big endian", withEXIF(plain, exifTIFF(binary.BigEndian, 8)), 8},
+		{"upright", withEXIF(plain, exifTIFF(binary.BigEndian, 1)), 1},
+		{"out of range", withEXIF(plain, exifTIFF(binary.LittleEndian, 9)), 1},
+		{"after another segment", withEXIF(withSegment(plain, 0xE0, []byte("JFIF\x00\x01\x01")), exifTIFF(binary.LittleEndian, 3)), 3},
+		{"xmp app1", withSegment(plain, 0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x/>")), 1},
+		{"bad byte order", withEXIF(plain, append([]byte("XX"), exifTIFF(binary.LittleEndian, 6)[2:]...)), 1},
+		{"ifd out of range", withEXIF(plain, []byte("II*\x00\xff\x00\x00\x00")), 1},
+		{"truncated", truncated, 1},
+		{"not a jpeg", encodePNG(t, testImage(8, 8, 255)), 1},
+		{"empty", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	const w, h = 3, 2
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+
+	// For each orientation, the source pixels that end up at the top-left
+	// and top-right corners.
+	tests := []struct {
+		orientation       int
+		topLeft, topRight image.Point
+	}{
+		{1, image.Pt(0, 0), image.Pt(w-1, 0)},
+		{2, image.Pt(w-1, 0), image.Pt(0, 0)},
+		{3, image.Pt(w-1, h-1), image.Pt(0, h-1)},
+		{4, image.Pt(0, h-1), image.Pt(w-1, h-1)},
+		{5, image.Pt(0, 0), image.Pt(0, h-1)},
+		{6, image.Pt(0, h-1), image.Pt(0, 0)},
+		{7, image.Pt(w-1, h-1), image.Pt(w-1, 0)},
+		{8, image.Pt(w-1, 0), image.Pt(w-1, h-1)},
+	}
+	for _, tt := range tests {
+		got := orient(src, tt.orientation)
+		b := got.Bounds()
+		wantW, wantH := w, h
+		if tt.orientation >= 5 {
+			wantW, wantH = h, w
+		}
+		if b.Dx() != wantW || b.Dy() != wantH {
+			t.Errorf("orientation %d: got %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), wantW, wantH)
+			continue
+		}
+		for _, corner := range []struct{ at, from image.Point }{
+			{image.Pt(0, 0), tt.topLeft},
+			{image.Pt(wantW-1, 0), tt.topRight},
+		} {
+			if got, want := color.RGBAModel.Convert(got.At(corner.at.X, corner.at.Y)), src.At(corner.from.X, corner.from.Y); got != want {
+				t.Errorf("orientation %d: pixel at %v is %v, want %v from %v", tt.orientation, corner.at, got, want, corner.from)
+			}
+		}
+	}
+}
+
+func TestProcessAppliesAndDropsEXIF(t *testing.T) {
+	data := withEXIF(encodeJPEG(t, testImage(60, 30, 255)), exifTIFF(binary.LittleEndian, 6))
+	res, err := Process(bytes.NewReader(data), Options{Widths: []int{20}, ThumbnailSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Original.Width != 30 || res.Original.Height != 60 {
+		t.Errorf("original is %dx%d, want the rotated 30x60", res.Original.Width, res.Original.Height)
+	}
+	for _, v := range append([]Variant{res.Original, *res.Thumbnail}, res.Variants...) {
+		if bytes.Contains(v.Data, []byte("Exif\x00\x00")) {
+			t.Errorf("variant %q kept its EXIF data", v.Name)
+		}
+	}
+}
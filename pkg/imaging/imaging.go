@@ -0,0 +1,206 @@
+// Package imaging resizes uploaded images into the variants served to
+// clients: a bounded original, responsive widths and a square thumbnail.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrUnsupported is returned for data that is not a supported image.
+var ErrUnsupported = errors.New("imaging: unsupported image format")
+
+// Options control the variants generated for an image.
+type Options struct {
+	// Widths are the responsive variants generated, in pixels. Widths not
+	// smaller than the original are skipped; images are never upscaled.
+	Widths []int
+	// ThumbnailSize is the side of the square thumbnail; 0 disables it.
+	ThumbnailSize int
+	// MaxWidth bounds the stored original; wider images are scaled down.
+	// 0 keeps the original size.
+	MaxWidth int
+	// Quality is the JPEG quality, 1 to 100.
+	Quality int
+	// MaxDimension and MaxPixels bound the width or height and the area of
+	// accepted images; 0 disables the limit.
+	MaxDimension int
+	MaxPixels    int64
+}
+
+// Variant is one encoded rendition of an image.
+type Variant struct {
+	// Name is "" for the original, "<width>w" for responsive variants and
+	// "thumb" for the thumbnail.
+	Name   string
+	Width  int
+	Height int
+	// Ext is the file extension of the encoding, with the dot.
+	Ext  string
+	Data []byte
+}
+
+// Result is the processed image: the original first, then the responsive
+// variants by increasing width, then the thumbnail.
+type Result struct {
+	Original  Variant
+	Variants  []Variant
+	Thumbnail *Variant
+}
+
+// Process validates and decodes an image and encodes its variants. The
+// format is sniffed from the content and the dimensions are checked before
+// the pixels are decoded. JPEG and PNG keep their format. GIF and WebP
+// originals are stored as uploaded, so animations survive, unless they must
+// be scaled down; their variants are PNG when transparent and JPEG
+// otherwise. Embedded metadata such as EXIF and GPS positions is dropped,
+// with the EXIF orientation applied to the pixels first.
+func Process(r io.Reader, opts Options) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sniffed := Sniff(data)
+	if sniffed == "" {
+		return nil, ErrUnsupported
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != sniffed {
+		return nil, ErrUnsupported
+	}
+	if err := checkSize(config.Width, config.Height, opts); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	enc := encoder{format: format, quality: opts.Quality}
+	switch format {
+	case "jpeg", "png":
+	case "gif", "webp":
+		enc.format = "jpeg"
+		if !opaque(img) {
+			enc.format = "png"
+		}
+	default:
+		return nil, ErrUnsupported
+	}
+
+	res := &Result{}
+	bounds := img.Bounds()
+	original := img
+	if opts.MaxWidth > 0 && bounds.Dx() > opts.MaxWidth {
+		original = resize(img, opts.MaxWidth)
+	}
+	if original == img && (format == "gif" || format == "webp") {
+		stripped, err := strip(format, data)
+		if err != nil {
+			return nil, err
+		}
+		res.Original = Variant{Width: bounds.Dx(), Height: bounds.Dy(), Ext: "." + format, Data: stripped}
+	} else if res.Original, err = enc.encode("", original); err != nil {
+		return nil, err
+	}
+
+	widths := append([]int(nil), opts.Widths...)
+	sort.Ints(widths)
+	for i, width := range widths {
+		if width <= 0 || width >= res.Original.Width || i > 0 && width == widths[i-1] {
+			continue
+		}
+		v, err := enc.encode(fmt.Sprintf("%dw", width), resize(img, width))
+		if err != nil {
+			return nil, err
+		}
+		res.Variants = append(res.Variants, v)
+	}
+
+	if opts.ThumbnailSize > 0 {
+		thumb, err := enc.encode("thumb", thumbnail(img, opts.ThumbnailSize))
+		if err != nil {
+			return nil, err
+		}
+		res.Thumbnail = &thumb
+	}
+	return res, nil
+}
+
+func strip(format string, data []byte) ([]byte, error) {
+	if format == "gif" {
+		return stripGIF(data)
+	}
+	return stripWebP(data)
+}
+
+// resize scales an image to the given width, keeping its aspect ratio.
+func resize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	height := max(1, (b.Dy()*width+b.Dx()/2)/b.Dx())
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// thumbnail scales an image to cover a size x size square and crops the
+// centre.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(b.Min).
+		Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+	size = min(size, side)
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+type encoder struct {
+	format  string
+	quality int
+}
+
+func (e encoder) encode(name string, img image.Image) (Variant, error) {
+	var buf bytes.Buffer
+	v := Variant{Name: name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	switch e.format {
+	case "jpeg":
+		v.Ext = ".jpg"
+		quality := e.quality
+		if quality < 1 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return v, err
+		}
+	case "png":
+		v.Ext = ".png"
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := enc.Encode(&buf, img); err != nil {
+			return v, err
+		}
+	}
+	v.Data = buf.Bytes()
+	return v, nil
+}
@@ -0,0 +1,262 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+// testImage returns a w x h image split into vertical thirds of red, green
+// and blue, transparent when alpha is below 255.
+func testImage(w, h int, alpha uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		c := color.NRGBA{R: 255, A: alpha}
+		switch {
+		case x >= 2*w/3:
+			c = color.NRGBA{B: 255, A: alpha}
+		case x >= w/3:
+			c = color.NRGBA{G: 255, A: alpha}
+		}
+		for y := 0; y < h; y++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// size is the width and height of a variant.
+type size struct{ w, h int }
+
+func sizes(vs []Variant) []size {
+	var s []size
+	for _, v := range vs {
+		s = append(s, size{v.Width, v.Height})
+	}
+	return s
+}
+
+func TestProcessVariants(t *testing.T) {
+	tests := []struct {
+		name         string
+		w            int
+		h            int
+		opts         Options
+		wantOriginal size
+		wantVariants []size
+		wantNames    []string
+		wantThumb    *size
+	}{
+		{
+			name:         "widths and thumbnail",
+			w:            1200,
+			h:            600,
+			opts:         Options{Widths: []int{320, 640}, ThumbnailSize: 150},
+			wantOriginal: size{1200, 600},
+			wantVariants: []size{{320, 160}, {640, 320}},
+			wantNames:    []string{"320w", "640w"},
+			wantThumb:    &size{150, 150},
+		},
+		{
+			name:         "widths sorted and deduplicated",
+			w:            1000,
+			h:            500,
+			opts:         Options{Widths: []int{640, 320, 640, 0, -5}},
+			wantOriginal: size{1000, 500},
+			wantVariants: []size{{320, 160}, {640, 320}},
+			wantNames:    []string{"320w", "640w"},
+		},
+		{
+			name:         "no upscaling",
+			w:            400,
+			h:            300,
+			opts:         Options{Widths: []int{320, 400, 640}},
+			wantOriginal: size{400, 300},
+			wantVariants: []size{{320, 240}},
+			wantNames:    []string{"320w"},
+		},
+		{
+			name:         "original bounded",
+			w:            3000,
+			h:            1000,
+			opts:         Options{Widths: []int{640, 2000, 2400}, MaxWidth: 2000},
+			wantOriginal: size{2000, 667},
+			wantVariants: []size{{640, 213}},
+			wantNames:    []string{"640w"},
+		},
+		{
+			name:         "thumbnail of a portrait image",
+			w:            300,
+			h:            900,
+			opts:         Options{ThumbnailSize: 100},
+			wantOriginal: size{300, 900},
+			wantThumb:    &size{100, 100},
+		},
+		{
+			name:         "thumbnail not upscaled",
+			w:            80,
+			h:            60,
+			opts:         Options{ThumbnailSize: 150},
+			wantOriginal: size{80, 60},
+			wantThumb:    &size{60, 60},
+		},
+		{
+			name:         "tiny image keeps a height",
+			w:            1000,
+			h:            1,
+			opts:         Options{Widths: []int{100}},
+			wantOriginal: size{1000, 1},
+			wantVariants: []size{{100, 1}},
+			wantNames:    []string{"100w"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Process(bytes.NewReader(encodePNG(t, testImage(tt.w, tt.h, 255))), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := (size{res.Original.Width, res.Original.Height}); got != tt.wantOriginal {
+				t.Errorf("original %v, want %v", got, tt.wantOriginal)
+			}
+			if res.Original.Name != "" {
+				t.Errorf("original named %q", res.Original.Name)
+			}
+			if got := sizes(res.Variants); !reflect.DeepEqual(got, tt.wantVariants) {
+				t.Errorf("variants %v, want %v", got, tt.wantVariants)
+			}
+			var names []string
+			for _, v := range res.Variants {
+				names = append(names, v.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("variant names %q, want %q", names, tt.wantNames)
+			}
+			switch {
+			case tt.wantThumb == nil && res.Thumbnail != nil:
+				t.Errorf("thumbnail %dx%d, want none", res.Thumbnail.Width, res.Thumbnail.Height)
+			case tt.wantThumb != nil && res.Thumbnail == nil:
+				t.Errorf("no thumbnail, want %v", *tt.wantThumb)
+			case tt.wantThumb != nil:
+				if got := (size{res.Thumbnail.Width, res.Thumbnail.Height}); got != *tt.wantThumb {
+					t.Errorf("thumbnail %v, want %v", got, *tt.wantThumb)
+				}
+				if res.Thumbnail.Name != "thumb" {
+					t.Errorf("thumbnail named %q", res.Thumbnail.Name)
+				}
+			}
+
+			// Every rendition decodes to the dimensions it reports.
+			all := append([]Variant{res.Original}, res.Variants...)
+			if res.Thumbnail != nil {
+				all = append(all, *res.Thumbnail)
+			}
+			for _, v := range all {
+				config, _, err := image.DecodeConfig(bytes.NewReader(v.Data))
+				if err != nil {
+					t.Fatalf("variant %q: %v", v.Name, err)
+				}
+				if config.Width != v.Width || config.Height != v.Height {
+					t.Errorf("variant %q decodes as %dx%d, reports %dx%d", v.Name, config.Width, config.Height, v.Width, v.Height)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessKeepsFormat(t *testing.T) {
+	opts := Options{Widths: []int{100}, ThumbnailSize: 50}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantExt string
+		wantFmt string
+	}{
+		{"png", encodePNG(t, testImage(300, 200, 128)), ".png", "png"},
+		{"jpeg", encodeJPEG(t, testImage(300, 200, 255)), ".jpg", "jpeg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Process(bytes.NewReader(tt.data), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range append([]Variant{res.Original, *res.Thumbnail}, res.Variants...) {
+				if v.Ext != tt.wantExt {
+					t.Errorf("variant %q has extension %q, want %q", v.Name, v.Ext, tt.wantExt)
+				}
+				if _, format, err := image.DecodeConfig(bytes.NewReader(v.Data)); err != nil || format != tt.wantFmt {
+					t.Errorf("variant %q decodes as %q, %v, want %q", v.Name, format, err, tt.wantFmt)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessQuality(t *testing.T) {
+	data := encodeJPEG(t, testImage(400, 400, 255))
+	low, err := Process(bytes.NewReader(data), Options{Quality: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := Process(bytes.NewReader(data), Options{Quality: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(low.Original.Data) >= len(high.Original.Data) {
+		t.Errorf("quality 10 gave %d bytes, quality 95 %d", len(low.Original.Data), len(high.Original.Data))
+	}
+	// Out of range qualities fall back to the default.
+	if _, err := Process(bytes.NewReader(data), Options{Quality: 500}); err != nil {
+		t.Errorf("quality 500: %v", err)
+	}
+}
+
+func TestThumbnailCropsCentre(t *testing.T) {
+	thumb := thumbnail(testImage(300, 100, 255), 50)
+	if b := thumb.Bounds(); b.Dx() != 50 || b.Dy() != 50 {
+		t.Fatalf("thumbnail is %v, want 50x50", b)
+	}
+	r, g, b, _ := thumb.At(25, 25).RGBA()
+	if g < 0xF000 || r > 0x1000 || b > 0x1000 {
+		t.Errorf("thumbnail centre is %v, want the green middle third", thumb.At(25, 25))
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":     nil,
+		"text":      []byte("hello, world"),
+		"truncated": encodePNG(t, testImage(50, 50, 255))[:40],
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Process(bytes.NewReader(data), Options{}); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Process = %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// stripGIF removes comments and application metadata, such as XMP, from a
+// GIF without decoding its frames. The looping extensions animations need
+// are kept.
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 {
+		return nil, ErrUnsupported
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+
+	// Header, logical screen descriptor and global colour table.
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << (flags&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, ErrUnsupported
+	}
+	out.Write(data[:i])
+
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case 0x3B: // trailer
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x21: // extension
+			if i+2 > len(data) {
+				return nil, ErrUnsupported
+			}
+			label := data[i+1]
+			end, ok := skipSubBlocks(data, i+2)
+			if !ok {
+				return nil, ErrUnsupported
+			}
+			i = end
+			if label == 0xFE || label == 0xFF && !loopExtension(data[start+2:end]) {
+				continue
+			}
+		case 0x2C: // image descriptor
+			i += 10
+			if i > len(data) {
+				return nil, ErrUnsupported
+			}
+			if flags := data[i-1]; flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data.
+			end, ok := skipSubBlocks(data, i+1)
+			if !ok {
+				return nil, ErrUnsupported
+			}
+			i = end
+		default:
+			return nil, ErrUnsupported
+		}
+		out.Write(data[start:i])
+	}
+	return nil, ErrUnsupported
+}
+
+// skipSubBlocks returns the offset just past the data sub-blocks starting
+// at i, including their terminator.
+func skipSubBlocks(data []byte, i int) (int, bool) {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, true
+		}
+		i += size
+	}
+	return 0, false
+}
+
+// loopExtension reports whether application extension sub-blocks belong
+// to the extensions that control animation looping.
+func loopExtension(blocks []byte) bool {
+	if len(blocks) < 12 || blocks[0] != 11 {
+		return false
+	}
+	id := string(blocks[1:12])
+	return id == "NETSCAPE2.0" || id == "ANIMEXTS1.0"
+}
+
+// stripWebP removes the EXIF and XMP chunks from a WebP file, clearing
+// their flags in the extended header. Colour profiles are kept.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrUnsupported
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrUnsupported
+		}
+		id := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if size < 0 || end > len(data) && i+8+size != len(data) {
+			return nil, ErrUnsupported
+		}
+		end = min(end, len(data))
+
+		switch id {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
@@ -0,0 +1,220 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// encodeGIF returns a 40x20 GIF with the given number of frames that loops
+// forever, with a transparent colour in its palette when asked.
+func encodeGIF(t *testing.T, frames int, transparent bool) []byte {
+	t.Helper()
+	palette := color.Palette{color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}}
+	if transparent {
+		palette = append(palette, color.RGBA{})
+	}
+	anim := &gif.GIF{LoopCount: 0}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 40, 20), palette)
+		for x := 0; x < 40; x++ {
+			for y := 0; y < 20; y++ {
+				frame.SetColorIndex(x, y, uint8((x+i)%len(palette)))
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withGIFMetadata inserts a comment and an XMP application extension
+// before the trailer of a GIF.
+func withGIFMetadata(data []byte) []byte {
+	comment := append([]byte{0x21, 0xFE, 6}, "secret\x00"...)
+	xmp := append([]byte{0x21, 0xFF, 11}, "XMP DataXMP"...)
+	xmp = append(xmp, 4)
+	xmp = append(xmp, "<x/>\x00"...)
+
+	out := append([]byte(nil), data[:len(data)-1]...)
+	out = append(out, comment...)
+	out = append(out, xmp...)
+	return append(out, 0x3B)
+}
+
+func TestStripGIF(t *testing.T) {
+	data := withGIFMetadata(encodeGIF(t, 3, false))
+	stripped, err := stripGIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{"secret", "XMP DataXMP"} {
+		if bytes.Contains(stripped, []byte(gone)) {
+			t.Errorf("stripped GIF still contains %q", gone)
+		}
+	}
+	if !bytes.Contains(stripped, []byte("NETSCAPE2.0")) {
+		t.Error("stripped GIF lost its looping extension")
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 || anim.LoopCount != 0 {
+		t.Errorf("stripped GIF has %d frames and loop count %d, want 3 and 0", len(anim.Image), anim.LoopCount)
+	}
+	if got, _ := stripGIF(encodeGIF(t, 3, false)); !bytes.Equal(got, encodeGIF(t, 3, false)) {
+		t.Error("stripping a GIF without metadata changed it")
+	}
+}
+
+func TestStripGIFInvalid(t *testing.T) {
+	data := encodeGIF(t, 1, false)
+	tests := map[string][]byte{
+		"short":          data[:10],
+		"no trailer":     data[:len(data)-1],
+		"unknown block":  append(append([]byte(nil), data[:len(data)-1]...), 0x99, 0x3B),
+		"cut in a block": data[:len(data)-5],
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := stripGIF(data); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("stripGIF = %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		opts         Options
+		wantOriginal string
+		wantVariants string
+	}{
+		{"kept as uploaded", withGIFMetadata(encodeGIF(t, 2, false)), Options{Widths: []int{20}}, ".gif", ".jpg"},
+		{"transparent variants", encodeGIF(t, 2, true), Options{Widths: []int{20}}, ".gif", ".png"},
+		{"scaled down", encodeGIF(t, 2, false), Options{Widths: []int{20}, MaxWidth: 30}, ".jpg", ".jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Process(bytes.NewReader(tt.data), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Original.Ext != tt.wantOriginal {
+				t.Errorf("original extension %q, want %q", res.Original.Ext, tt.wantOriginal)
+			}
+			if res.Original.Ext == ".gif" {
+				if bytes.Contains(res.Original.Data, []byte("secret")) {
+					t.Error("original kept its comment")
+				}
+				anim, err := gif.DecodeAll(bytes.NewReader(res.Original.Data))
+				if err != nil || len(anim.Image) != 2 {
+					t.Errorf("original is not the animation: %v", err)
+				}
+			}
+			if len(res.Variants) != 1 || res.Variants[0].Ext != tt.wantVariants {
+				t.Errorf("variants %+v, want one %s", sizes(res.Variants), tt.wantVariants)
+			}
+		})
+	}
+}
+
+// chunk encodes a RIFF chunk, padded to an even length.
+func chunk(id string, data []byte) []byte {
+	out := []byte(id)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// riff wraps chunks in a WebP RIFF container.
+func riff(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	out := []byte("RIFF")
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	return append(out, body...)
+}
+
+func TestStripWebP(t *testing.T) {
+	// VP8X flags: ICC profile 0x20, EXIF 0x08, XMP 0x04.
+	vp8x := func(flags byte) []byte { return chunk("VP8X", []byte{flags, 0, 0, 0, 9, 0, 0, 9, 0, 0}) }
+	iccp := chunk("ICCP", []byte("profile"))
+	bitstream := chunk("VP8L", []byte{0x2f, 1, 2, 3, 4})
+	// The last chunk may lack its padding byte.
+	unpadded := func(data []byte) []byte { return data[:len(data)-1] }
+	alpha := chunk("ALPH", []byte("abc"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{
+			"metadata removed",
+			riff(vp8x(0x2C), iccp, bitstream, chunk("EXIF", []byte("Exif\x00\x00gps")), chunk("XMP ", []byte("<x/>"))),
+			riff(vp8x(0x20), iccp, bitstream),
+		},
+		{
+			"metadata before the image",
+			riff(vp8x(0x0C), chunk("EXIF", []byte("e")), bitstream),
+			riff(vp8x(0x00), bitstream),
+		},
+		{"simple file unchanged", riff(bitstream), riff(bitstream)},
+		{
+			"unpadded last chunk",
+			unpadded(riff(bitstream, chunk("XMP ", []byte("x")), alpha)),
+			unpadded(riff(bitstream, alpha)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stripWebP(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append([]byte(nil), tt.want...)
+			binary.LittleEndian.PutUint32(want[4:], uint32(len(want)-8))
+			if !bytes.Equal(got, want) {
+				t.Errorf("stripWebP =\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestStripWebPInvalid(t *testing.T) {
+	bitstream := chunk("VP8L", []byte{0x2f, 1, 2, 3, 4})
+	overlong := riff(bitstream)
+	binary.LittleEndian.PutUint32(overlong[16:], 1000)
+	tests := map[string][]byte{
+		"not riff":       append([]byte("RIFX"), riff(bitstream)[4:]...),
+		"not webp":       append(append([]byte(nil), riff(bitstream)[:8]...), "WAVE"...),
+		"short":          []byte("RIFF"),
+		"truncated head": riff(bitstream)[:16],
+		"chunk too long": overlong,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := stripWebP(data); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("stripWebP = %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrTooLarge is returned for images whose dimensions exceed the limits.
+	ErrTooLarge = errors.New("imaging: image dimensions too large")
+	// ErrMismatch is returned when a file's extension does not match its content.
+	ErrMismatch = errors.New("imaging: file extension does not match its content")
+)
+
+// extFormats maps the accepted file extensions to the format their
+// content must be in.
+var extFormats = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".webp": "webp",
+}
+
+// sniffedFormats maps the content types detected from magic bytes to formats.
+var sniffedFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// FormatOf returns the format files with the given extension must be in,
+// or "" for extensions that are not accepted.
+func FormatOf(ext string) string {
+	return extFormats[strings.ToLower(ext)]
+}
+
+// Sniff returns the format of an image from its magic bytes, or "" when
+// it is not a supported image.
+func Sniff(data []byte) string {
+	return sniffedFormats[http.DetectContentType(data)]
+}
+
+// Check sniffs an image's format and verifies that it is accepted and
+// matches the file extension.
+func Check(data []byte, ext string) (string, error) {
+	format := Sniff(data)
+	if format == "" {
+		return "", ErrUnsupported
+	}
+	if FormatOf(ext) != format {
+		return "", ErrMismatch
+	}
+	return format, nil
+}
+
+// checkSize enforces the dimension limits before an image is decoded, so
+// a small file cannot expand into an enormous bitmap.
+func checkSize(width, height int, opts Options) error {
+	if width <= 0 || height <= 0 {
+		return ErrUnsupported
+	}
+	if opts.MaxDimension > 0 && (width > opts.MaxDimension || height > opts.MaxDimension) {
+		return ErrTooLarge
+	}
+	if opts.MaxPixels > 0 && int64(width)*int64(height) > opts.MaxPixels {
+		return ErrTooLarge
+	}
+	return nil
+}
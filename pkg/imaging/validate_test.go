@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		".jpg":  "jpeg",
+		".JPEG": "jpeg",
+		".png":  "png",
+		".Gif":  "gif",
+		".webp": "webp",
+		".bmp":  "",
+		".svg":  "",
+		"":      "",
+		"png":   "",
+	}
+	for ext, want := range tests {
+		if got := FormatOf(ext); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", ext, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	pngData := encodePNG(t, testImage(10, 10, 255))
+	jpegData := encodeJPEG(t, testImage(10, 10, 255))
+	gifData := encodeGIF(t, 1, false)
+	webpData := riff(chunk("VP8L", []byte{0x2f, 0, 0, 0, 0}))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		ext     string
+		want    string
+		wantErr error
+	}{
+		{"png", pngData, ".png", "png", nil},
+		{"upper case extension", pngData, ".PNG", "png", nil},
+		{"jpeg", jpegData, ".jpg", "jpeg", nil},
+		{"jpeg long extension", jpegData, ".jpeg", "jpeg", nil},
+		{"gif", gifData, ".gif", "gif", nil},
+		{"webp", webpData, ".webp", "webp", nil},
+		{"png named jpg", pngData, ".jpg", "", ErrMismatch},
+		{"jpeg named gif", jpegData, ".gif", "", ErrMismatch},
+		{"png named bmp", pngData, ".bmp", "", ErrMismatch},
+		{"html named png", []byte("<!DOCTYPE html><script>alert(1)</script>"), ".png", "", ErrUnsupported},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ".png", "", ErrUnsupported},
+		{"empty", nil, ".png", "", ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Check(tt.data, tt.ext)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Check = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessSizeLimits(t *testing.T) {
+	data := encodePNG(t, testImage(100, 50, 255))
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr error
+	}{
+		{"no limits", Options{}, nil},
+		{"within dimension", Options{MaxDimension: 100}, nil},
+		{"too wide", Options{MaxDimension: 99}, ErrTooLarge},
+		{"within pixels", Options{MaxPixels: 5000}, nil},
+		{"too many pixels", Options{MaxPixels: 4999}, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(bytes.NewReader(data), tt.opts); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Process = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		opts          Options
+		wantErr       error
+	}{
+		{"zero width", 0, 10, Options{}, ErrUnsupported},
+		{"zero height", 10, 0, Options{}, ErrUnsupported},
+		{"tall", 10, 5000, Options{MaxDimension: 4000}, ErrTooLarge},
+		{"area overflowing int32", 1 << 20, 1 << 20, Options{MaxPixels: 1 << 30}, ErrTooLarge},
+		{"within limits", 4000, 4000, Options{MaxDimension: 4000, MaxPixels: 16000000}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkSize(tt.width, tt.height, tt.opts); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkSize = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
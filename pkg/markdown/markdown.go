@@ -0,0 +1,44 @@
+// Package markdown renders GitHub Flavored Markdown to HTML.
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Raw HTML in the source is passed through to the output, which is then
+// sanitized like HTML posts are.
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, extension.Footnote),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// policy allows the markup people write posts with, including responsive
+// images, but no scripts, event handlers, styles or frames.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(false)
+	p.AllowAttrs("srcset", "sizes", "loading").OnElements("img")
+	p.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("a", "code", "div", "sup")
+	return p
+}()
+
+// HTML renders Markdown source as sanitized HTML.
+func HTML(src string) string {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(src), &buf); err != nil {
+		// Rendering only fails when writing to the buffer fails.
+		return ""
+	}
+	return Sanitize(buf.String())
+}
+
+// Sanitize strips markup that could run script or restyle the page from
+// HTML, such as imported posts, so it can be served as part of the site.
+func Sanitize(src string) string {
+	return policy.Sanitize(src)
+}
@@ -0,0 +1,44 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		notWant []string
+	}{
+		{"emphasis", "Hi *there*", []string{"<p>Hi <em>there</em></p>"}, nil},
+		{"table", "| a | b |\n|---|---|\n| 1 | 2 |", []string{"<table>", "<td>1</td>"}, nil},
+		{"strikethrough", "~~old~~", []string{"<del>old</del>"}, nil},
+		{"autolink", "see https://example.com", []string{`<a href="https://example.com"`}, []string{"nofollow"}},
+		{"footnote", "Text[^1]\n\n[^1]: Note", []string{`class="footnote-ref"`, "Note"}, nil},
+		{"code class", "```go\nx := 1\n```", []string{`<code class="language-go">`}, nil},
+		{"responsive image", `<img src="/a.jpg" srcset="/a-640w.jpg 640w" sizes="100vw" loading="lazy">`,
+			[]string{`srcset="/a-640w.jpg 640w"`, `sizes="100vw"`, `loading="lazy"`}, nil},
+		{"script", "Hi <script>alert(1)</script>", []string{"Hi"}, []string{"<script", "alert(1)"}},
+		{"event handler", `<img src="/a.jpg" onerror="alert(1)">`, []string{`src="/a.jpg"`}, []string{"onerror"}},
+		{"javascript link", "[x](javascript:alert(1))", nil, []string{"javascript:"}},
+		{"style", `<p style="color:red">red</p>`, []string{"red"}, []string{"style="}},
+		{"iframe", `<iframe src="https://example.com"></iframe>`, nil, []string{"<iframe"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTML(tt.src)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("HTML(%q) = %q, want it to contain %q", tt.src, got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("HTML(%q) = %q, want no %q", tt.src, got, notWant)
+				}
+			}
+		})
+	}
+}
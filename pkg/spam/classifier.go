@@ -0,0 +1,110 @@
+package spam
+
+import (
+	"math"
+	"sync"
+)
+
+type Class string
+
+const (
+	Spam Class = "spam"
+	Ham  Class = "ham"
+)
+
+// minTrainingDocs is how many documents each class needs before the
+// classifier's opinion is taken into account.
+const minTrainingDocs = 10
+
+// Classifier is a naive Bayes classifier over the set of tokens in a text.
+// Token counts are document frequencies: a token is counted at most once
+// per trained text.
+type Classifier struct {
+	mu     sync.RWMutex
+	tokens map[string]*TokenCount
+	docs   map[Class]int
+}
+
+type TokenCount struct {
+	Spam int
+	Ham  int
+}
+
+func NewClassifier() *Classifier {
+	return &Classifier{
+		tokens: make(map[string]*TokenCount),
+		docs:   make(map[Class]int),
+	}
+}
+
+// Load replaces the classifier state with previously persisted counts.
+func (c *Classifier) Load(tokens map[string]TokenCount, docs map[Class]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokens = make(map[string]*TokenCount, len(tokens))
+	for token, count := range tokens {
+		count := count
+		c.tokens[token] = &count
+	}
+	c.docs = make(map[Class]int, len(docs))
+	for class, n := range docs {
+		c.docs[class] = n
+	}
+}
+
+// Learn adds tokens as one document of the given class.
+func (c *Classifier) Learn(class Class, tokens []string) {
+	c.adjust(class, tokens, 1)
+}
+
+// Unlearn removes a document previously added with Learn.
+func (c *Classifier) Unlearn(class Class, tokens []string) {
+	c.adjust(class, tokens, -1)
+}
+
+func (c *Classifier) adjust(class Class, tokens []string, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.docs[class] = max(c.docs[class]+delta, 0)
+	for _, token := range tokens {
+		count, ok := c.tokens[token]
+		if !ok {
+			count = &TokenCount{}
+			c.tokens[token] = count
+		}
+		if class == Spam {
+			count.Spam = max(count.Spam+delta, 0)
+		} else {
+			count.Ham = max(count.Ham+delta, 0)
+		}
+	}
+}
+
+// Probability returns the probability that tokens come from a spam
+// document. ok is false while the classifier has too little training data
+// to have an opinion.
+func (c *Classifier) Probability(tokens []string) (p float64, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	spamDocs, hamDocs := c.docs[Spam], c.docs[Ham]
+	if spamDocs < minTrainingDocs || hamDocs < minTrainingDocs {
+		return 0, false
+	}
+
+	// Work in log-odds to avoid underflow, with Laplace smoothing so unseen
+	// tokens are neutral rather than decisive.
+	logOdds := math.Log(float64(spamDocs)) - math.Log(float64(hamDocs))
+	for _, token := range tokens {
+		var spam, ham int
+		if count, found := c.tokens[token]; found {
+			spam, ham = count.Spam, count.Ham
+		}
+		logOdds += math.Log(float64(spam+1)/float64(spamDocs+2)) -
+			math.Log(float64(ham+1)/float64(hamDocs+2))
+	}
+
+	return 1 / (1 + math.Exp(-logOdds)), true
+}
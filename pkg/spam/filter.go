@@ -0,0 +1,98 @@
+package spam
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules configures the heuristic checks applied on top of the classifier.
+type Rules struct {
+	// MaxLinks is the number of links a text may carry before it looks suspicious.
+	MaxLinks int
+	// BlockedDomains are hosts (and their subdomains) that are always spam.
+	BlockedDomains []string
+	// VelocityLimit is how many recent submissions an author may make before
+	// further ones look suspicious (0 disables the check).
+	VelocityLimit int
+}
+
+// Submission is a piece of user content to score.
+type Submission struct {
+	Text string
+	// Honeypot is the value of a form field hidden from humans; bots fill it in.
+	Honeypot string
+	// RecentSubmissions is how many submissions the author made in the
+	// velocity window, not counting this one.
+	RecentSubmissions int
+}
+
+// Result is the outcome of scoring a submission.
+type Result struct {
+	// Score is the combined spam likelihood between 0 and 1.
+	Score float64 `json:"score"`
+	// Reasons describes the signals that contributed to the score.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Weights of the individual heuristics. Signals are combined as a noisy-OR,
+// so each one independently raises the score towards 1.
+const (
+	tooManyLinksWeight = 0.4
+	velocityWeight     = 0.5
+)
+
+type Filter struct {
+	classifier *Classifier
+	rules      Rules
+}
+
+func NewFilter(classifier *Classifier, rules Rules) *Filter {
+	return &Filter{classifier: classifier, rules: rules}
+}
+
+// Check scores a submission against the classifier and the heuristics.
+func (f *Filter) Check(s Submission) Result {
+	var result Result
+	notSpam := 1.0
+	raise := func(weight float64, reason string) {
+		notSpam *= 1 - weight
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	if strings.TrimSpace(s.Honeypot) != "" {
+		raise(1, "honeypot field filled in")
+	}
+
+	for _, host := range LinkedDomains(s.Text) {
+		if f.isBlocked(host) {
+			raise(1, "links to blocked domain "+host)
+			break
+		}
+	}
+
+	if links := len(Links(s.Text)); links > f.rules.MaxLinks {
+		raise(tooManyLinksWeight, fmt.Sprintf("contains %d links", links))
+	}
+
+	if f.rules.VelocityLimit > 0 && s.RecentSubmissions >= f.rules.VelocityLimit {
+		raise(velocityWeight, fmt.Sprintf("%d recent submissions", s.RecentSubmissions))
+	}
+
+	if p, ok := f.classifier.Probability(Tokenize(s.Text)); ok {
+		notSpam *= 1 - p
+		result.Reasons = append(result.Reasons, fmt.Sprintf("classifier spam probability %.2f", p))
+	}
+
+	result.Score = 1 - notSpam
+	return result
+}
+
+func (f *Filter) isBlocked(host string) bool {
+	for _, domain := range f.rules.BlockedDomains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
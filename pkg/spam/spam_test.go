@@ -0,0 +1,194 @@
+package spam
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"lower-cased and distinct", "Buy buy BUY now", []string{"buy", "now"}},
+		{"short words dropped", "a b cd", []string{"cd"}},
+		{"apostrophes kept inside words", "don't 'quote'", []string{"don't", "quote"}},
+		{"punctuation splits", "cheap,pills!now", []string{"cheap", "pills", "now"}},
+		{"unicode letters", "Grüße naïve", []string{"grüße", "naïve"}},
+		{
+			"linked domains first",
+			"see https://www.Example.com/x",
+			[]string{"domain:example.com", "see", "https", "www", "example", "com"},
+		},
+		{"overlong words dropped", strings.Repeat("x", 41) + " ok", []string{"ok"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShorten(t *testing.T) {
+	long := "domain:" + strings.Repeat("a", 100) + ".com"
+	other := "domain:" + strings.Repeat("a", 100) + ".net"
+	multibyte := strings.Repeat("é", 40)
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"short", "hello"},
+		{"exactly max", strings.Repeat("a", MaxTokenLength)},
+		{"long", long},
+		{"multibyte", multibyte},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shorten(tt.token)
+			if len(got) > MaxTokenLength {
+				t.Errorf("len(shorten) = %d, want at most %d", len(got), MaxTokenLength)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("shorten(%q) = %q, not valid UTF-8", tt.token, got)
+			}
+			if len(tt.token) <= MaxTokenLength && got != tt.token {
+				t.Errorf("shorten(%q) = %q, want it unchanged", tt.token, got)
+			}
+		})
+	}
+	if shorten(long) == shorten(other) {
+		t.Errorf("distinct long tokens shortened alike: %q", shorten(long))
+	}
+}
+
+func TestLinkedDomains(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"no links here", nil},
+		{"go to http://Example.COM/path", []string{"example.com"}},
+		{"www.shop.example.org and https://a.b.c:8080/", []string{"shop.example.org", "a.b.c"}},
+		{`<a href="https://x.io/">`, []string{"x.io"}},
+	}
+	for _, tt := range tests {
+		if got := LinkedDomains(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LinkedDomains(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// trained returns a classifier trained on n spam documents of spamTokens
+// and n ham documents of hamTokens.
+func trained(n int, spamTokens, hamTokens []string) *Classifier {
+	c := NewClassifier()
+	for i := 0; i < n; i++ {
+		c.Learn(Spam, spamTokens)
+		c.Learn(Ham, hamTokens)
+	}
+	return c
+}
+
+func TestProbability(t *testing.T) {
+	spam, ham := []string{"viagra"}, []string{"hello"}
+	tests := []struct {
+		name       string
+		classifier *Classifier
+		tokens     []string
+		want       float64
+		wantOK     bool
+	}{
+		{"too little training", trained(minTrainingDocs-1, spam, ham), spam, 0, false},
+		// Laplace smoothing: (10+1)/(10+2) against (0+1)/(10+2).
+		{"spam token", trained(minTrainingDocs, spam, ham), spam, 11.0 / 12, true},
+		{"ham token", trained(minTrainingDocs, spam, ham), ham, 1.0 / 12, true},
+		{"unseen token is neutral", trained(minTrainingDocs, spam, ham), []string{"other"}, 0.5, true},
+		{"no tokens is neutral", trained(minTrainingDocs, spam, ham), nil, 0.5, true},
+		{"tokens combine", trained(minTrainingDocs, spam, ham), []string{"viagra", "hello"}, 0.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.classifier.Probability(tt.tokens)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Probability(%q) = %v, %v; want %v, %v", tt.tokens, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestUnlearn(t *testing.T) {
+	c := trained(minTrainingDocs, []string{"viagra"}, []string{"hello"})
+	c.Unlearn(Spam, []string{"viagra"})
+	if _, ok := c.Probability(nil); ok {
+		t.Error("Probability ok after unlearning below the training minimum")
+	}
+
+	c = NewClassifier()
+	c.Unlearn(Ham, []string{"hello"})
+	if c.docs[Ham] != 0 || c.tokens["hello"].Ham != 0 {
+		t.Errorf("counts went negative: docs %d, token %+v", c.docs[Ham], *c.tokens["hello"])
+	}
+}
+
+func TestLoad(t *testing.T) {
+	c := NewClassifier()
+	c.Load(map[string]TokenCount{"viagra": {Spam: 10}, "hello": {Ham: 10}},
+		map[Class]int{Spam: minTrainingDocs, Ham: minTrainingDocs})
+	want := trained(minTrainingDocs, []string{"viagra"}, []string{"hello"})
+	for _, token := range []string{"viagra", "hello", "other"} {
+		got, _ := c.Probability([]string{token})
+		expected, _ := want.Probability([]string{token})
+		if math.Abs(got-expected) > 1e-9 {
+			t.Errorf("loaded Probability(%q) = %v, want %v", token, got, expected)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rules := Rules{MaxLinks: 1, BlockedDomains: []string{"Spam.example"}, VelocityLimit: 3}
+	tests := []struct {
+		name       string
+		classifier *Classifier
+		submission Submission
+		want       float64
+		reasons    int
+	}{
+		{"clean", NewClassifier(), Submission{Text: "Nice post"}, 0, 0},
+		{"honeypot", NewClassifier(), Submission{Text: "hi", Honeypot: "x"}, 1, 1},
+		{"blank honeypot", NewClassifier(), Submission{Text: "hi", Honeypot: "  "}, 0, 0},
+		{"blocked domain", NewClassifier(), Submission{Text: "see http://spam.example"}, 1, 1},
+		{"blocked subdomain", NewClassifier(), Submission{Text: "see http://www.a.spam.example"}, 1, 1},
+		{"similar domain", NewClassifier(), Submission{Text: "see http://notspam.example"}, 0, 0},
+		{"too many links", NewClassifier(), Submission{Text: "http://a.io http://b.io"}, tooManyLinksWeight, 1},
+		{"velocity", NewClassifier(), Submission{Text: "hi", RecentSubmissions: 3}, velocityWeight, 1},
+		{
+			"noisy-OR of heuristics",
+			NewClassifier(),
+			Submission{Text: "http://a.io http://b.io", RecentSubmissions: 5},
+			1 - (1-tooManyLinksWeight)*(1-velocityWeight),
+			2,
+		},
+		{
+			"classifier",
+			trained(minTrainingDocs, []string{"viagra"}, []string{"hello"}),
+			Submission{Text: "viagra"},
+			11.0 / 12,
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewFilter(tt.classifier, rules).Check(tt.submission)
+			if math.Abs(got.Score-tt.want) > 1e-9 || len(got.Reasons) != tt.reasons {
+				t.Errorf("Check = %v %q, want score %v with %d reasons", got.Score, got.Reasons, tt.want, tt.reasons)
+			}
+		})
+	}
+}
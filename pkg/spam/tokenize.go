@@ -0,0 +1,87 @@
+package spam
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+// MaxTokenLength is the longest token Tokenize returns, in bytes. Longer
+// tokens, such as the domains of long host names, are shortened to a
+// prefix and a hash of the whole token.
+const MaxTokenLength = 64
+
+// Tokenize splits text into the distinct lower-cased tokens the classifier
+// works on. Linked domains are added as "domain:<host>" tokens so that
+// repeat offenders are learned independently of the surrounding text.
+func Tokenize(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(token string) {
+		token = shorten(token)
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, host := range LinkedDomains(text) {
+		add("domain:" + host)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	for _, word := range words {
+		word = strings.Trim(word, "'")
+		if len(word) < 2 || len(word) > 40 {
+			continue
+		}
+		add(word)
+	}
+
+	return tokens
+}
+
+// shorten fits a token into MaxTokenLength bytes, keeping distinct long
+// tokens apart by a hash of the whole token.
+func shorten(token string) string {
+	if len(token) <= MaxTokenLength {
+		return token
+	}
+	sum := sha256.Sum256([]byte(token))
+	hash := "#" + hex.EncodeToString(sum[:8])
+	prefix := token[:MaxTokenLength-len(hash)]
+	// Do not split a multi-byte character.
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix + hash
+}
+
+// Links returns every URL-looking substring of text.
+func Links(text string) []string {
+	return linkPattern.FindAllString(text, -1)
+}
+
+// LinkedDomains returns the lower-cased host of every link in text.
+func LinkedDomains(text string) []string {
+	var hosts []string
+	for _, link := range Links(text) {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+	}
+	return hosts
+}
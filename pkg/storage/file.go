@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedType is returned for files of a type that is not accepted.
+	ErrUnsupportedType = errors.New("storage: unsupported file type")
+	// ErrTypeMismatch is returned when a file's extension does not match its content.
+	ErrTypeMismatch = errors.New("storage: file extension does not match its content")
+)
+
+// File is a stored file.
+type File struct {
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	// Checksum is the hex SHA-256 of the uploaded file.
+	Checksum string `json:"checksum"`
+}
+
+// fileType is a kind of file accepted besides images.
+type fileType struct {
+	contentType string
+	// sniffed is the type http.DetectContentType finds in such files.
+	sniffed string
+}
+
+// fileTypes are the accepted files other than images, by extension.
+var fileTypes = map[string]fileType{
+	".mp3":  {"audio/mpeg", "audio/mpeg"},
+	".m4a":  {"audio/mp4", "video/mp4"},
+	".ogg":  {"audio/ogg", "application/ogg"},
+	".oga":  {"audio/ogg", "application/ogg"},
+	".wav":  {"audio/wav", "audio/wave"},
+	".mp4":  {"video/mp4", "video/mp4"},
+	".webm": {"video/webm", "video/webm"},
+	".pdf":  {"application/pdf", "application/pdf"},
+}
+
+// Accepted reports whether files with the given extension may be uploaded
+// with SaveFile.
+func Accepted(ext string) bool {
+	_, ok := fileTypes[strings.ToLower(ext)]
+	return ok
+}
+
+// SaveFile validates an uploaded file other than an image by its content
+// and stores it as is under a new key in dir, which is empty or ends in a
+// slash. Keys in a dir are random. size is the length of r, or -1 when
+// unknown.
+func SaveFile(ctx context.Context, s Storage, dir, filename string, r io.Reader, size int64) (*File, error) {
+	ext := strings.ToLower(path.Ext(filename))
+	kind, ok := fileTypes[ext]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, ext)
+	}
+
+	buffered := bufio.NewReaderSize(r, 512)
+	header, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	sniffed, _, _ := strings.Cut(http.DetectContentType(header), ";")
+	if sniffed != kind.sniffed {
+		if sniffed == "application/octet-stream" || sniffed == "text/plain" {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, sniffed)
+		}
+		return nil, ErrTypeMismatch
+	}
+
+	h := sha256.New()
+	counted := &countingReader{r: io.TeeReader(buffered, h)}
+	key := newName(dir) + ext
+	if err := s.Put(ctx, key, counted, size, kind.contentType); err != nil {
+		return nil, err
+	}
+	return &File{
+		Key:         key,
+		URL:         s.URL(key),
+		Size:        counted.n,
+		ContentType: kind.contentType,
+		Checksum:    hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/Realwale/scribana/pkg/imaging"
+)
+
+// Image is a stored image with its responsive variants. The File is the
+// stored original; variants are stored beside it.
+type Image struct {
+	File
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Variants are the smaller renditions, by increasing width.
+	Variants  []ImageVariant `json:"variants"`
+	Thumbnail *ImageVariant  `json:"thumbnail,omitempty"`
+	// SrcSet lists the variants and the original for an img srcset attribute.
+	SrcSet string `json:"srcset"`
+}
+
+// ImageVariant is one rendition of a stored image.
+type ImageVariant struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+}
+
+// SaveImage validates an uploaded image by its content and stores it,
+// scaled down to the configured maximum and stripped of metadata, along
+// with its responsive variants and thumbnail, under new keys in dir, which
+// is empty or ends in a slash; keys in a dir are random. Variants are
+// stored beside the original as <name>-<width>w.<ext> and
+// <name>-thumb.<ext>.
+func SaveImage(ctx context.Context, s Storage, dir, filename string, r io.Reader, opts imaging.Options) (*Image, error) {
+	ext := path.Ext(filename)
+	if imaging.FormatOf(ext) == "" {
+		return nil, fmt.Errorf("%w: %s", imaging.ErrUnsupported, ext)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := imaging.Check(data, ext); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+
+	processed, err := imaging.Process(bytes.NewReader(data), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	name := newName(dir)
+	var stored []string
+	put := func(v imaging.Variant) (ImageVariant, error) {
+		key := name + v.Ext
+		if v.Name != "" {
+			key = name + "-" + v.Name + v.Ext
+		}
+		if err := s.Put(ctx, key, bytes.NewReader(v.Data), int64(len(v.Data)), contentType(key)); err != nil {
+			return ImageVariant{}, err
+		}
+		stored = append(stored, key)
+		return ImageVariant{Key: key, URL: s.URL(key), Width: v.Width, Height: v.Height, Size: int64(len(v.Data))}, nil
+	}
+	img, err := saveVariants(processed, put)
+	if err != nil {
+		// Leave nothing half-stored behind.
+		for _, key := range stored {
+			s.Delete(context.WithoutCancel(ctx), key)
+		}
+		return nil, err
+	}
+	img.Size = int64(len(processed.Original.Data))
+	img.ContentType = contentType(img.Key)
+	img.Checksum = hex.EncodeToString(sum[:])
+	return img, nil
+}
+
+func saveVariants(processed *imaging.Result, put func(imaging.Variant) (ImageVariant, error)) (*Image, error) {
+	original, err := put(processed.Original)
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{
+		File:     File{Key: original.Key, URL: original.URL},
+		Width:    original.Width,
+		Height:   original.Height,
+		Variants: []ImageVariant{},
+	}
+
+	var srcset []string
+	for _, v := range processed.Variants {
+		variant, err := put(v)
+		if err != nil {
+			return nil, err
+		}
+		img.Variants = append(img.Variants, variant)
+		srcset = append(srcset, fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+	}
+	img.SrcSet = strings.Join(append(srcset, fmt.Sprintf("%s %dw", img.URL, img.Width)), ", ")
+
+	if processed.Thumbnail != nil {
+		thumb, err := put(*processed.Thumbnail)
+		if err != nil {
+			return nil, err
+		}
+		img.Thumbnail = &thumb
+	}
+	return img, nil
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Options configures an S3-compatible object store: AWS S3 itself, or
+// MinIO, Ceph, R2 and the like.
+type S3Options struct {
+	// Endpoint is the service URL, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://localhost:9000.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Prefix is prepended to every key, to share a bucket with other data.
+	Prefix string
+	// PathStyle addresses the bucket as endpoint/bucket rather than as
+	// bucket.endpoint; most self-hosted stores need it.
+	PathStyle bool
+	// PublicURL is where stored files are publicly served from, such as a
+	// CDN in front of the bucket. Defaults to the bucket's own URL.
+	PublicURL string
+	Client    *http.Client
+}
+
+// S3 keeps files in a bucket of an S3-compatible object store. Requests are
+// signed with AWS Signature Version 4.
+type S3 struct {
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3 returns an S3 storage.
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("storage: S3 bucket is required")
+	}
+	if opts.Endpoint == "" {
+		region := opts.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		opts.Endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	endpoint, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("storage: invalid S3 endpoint %q", opts.Endpoint)
+	}
+	opts.Prefix = strings.Trim(opts.Prefix, "/")
+
+	s := &S3{opts: opts, endpoint: endpoint, client: opts.Client}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	if s.opts.PublicURL == "" {
+		s.opts.PublicURL = s.bucketURL().String()
+	}
+	s.opts.PublicURL = strings.TrimRight(s.opts.PublicURL, "/")
+	return s, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	body, hash, size, err := payload(r, size)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, hash, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, emptyHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*Object, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, emptyHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	obj := &Object{Key: key, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = modified
+	}
+	return obj, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, emptyHash, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	objectPrefix := s.objectKey(prefix)
+	token := ""
+	for {
+		query := map[string]string{"list-type": "2", "prefix": objectPrefix}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+		u := s.bucketURL()
+		u.Path += "/"
+		u.RawQuery = encodeQuery(query)
+		resp, err := s.send(ctx, http.MethodGet, u, nil, 0, emptyHash, nil)
+		if err != nil {
+			return err
+		}
+
+		var page struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, c := range page.Contents {
+			key := c.Key
+			if s.opts.Prefix != "" {
+				key = strings.TrimPrefix(key, s.opts.Prefix+"/")
+			}
+			if err := fn(Object{Key: key, Size: c.Size, ContentType: contentType(key), ModTime: c.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3) URL(key string) string {
+	return s.opts.PublicURL + "/" + escapePath(s.objectKey(key))
+}
+
+func (s *S3) objectKey(key string) string {
+	if s.opts.Prefix == "" {
+		return key
+	}
+	return s.opts.Prefix + "/" + key
+}
+
+func (s *S3) bucketURL() *url.URL {
+	u := *s.endpoint
+	if s.opts.PathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + s.opts.Bucket
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+	}
+	u.RawPath = ""
+	return &u
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, hash string, header http.Header) (*http.Response, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	u := s.bucketURL()
+	u.Path += "/" + s.objectKey(key)
+	return s.send(ctx, method, u, body, size, hash, header)
+}
+
+// send signs and sends a request, turning error responses into errors.
+func (s *S3) send(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, hash string, header http.Header) (*http.Response, error) {
+	u.RawPath = escapePath(u.Path)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	signV4(req, hash, s.opts.AccessKeyID, s.opts.SecretAccessKey, s.opts.Region, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return nil, s3Error(resp)
+}
+
+// s3Error describes a failed request from its XML error document.
+func s3Error(resp *http.Response) error {
+	var doc struct {
+		Code    string
+		Message string
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&doc); err != nil || doc.Code == "" {
+		return fmt.Errorf("storage: S3 request failed: %s", resp.Status)
+	}
+	return fmt.Errorf("storage: S3 request failed: %s: %s", doc.Code, doc.Message)
+}
+
+// payload prepares a request body, hashing it for the signature. Seekable
+// readers of known size are hashed in place, and other readers of known
+// size are streamed unsigned so large files need not fit in memory; the
+// rest are buffered.
+func payload(r io.Reader, size int64) (io.Reader, string, int64, error) {
+	h := sha256.New()
+	if _, ok := r.(io.ReadSeeker); !ok && size >= 0 {
+		return io.LimitReader(r, size), unsignedPayload, size, nil
+	}
+	if seeker, ok := r.(io.ReadSeeker); ok && size >= 0 {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		n, err := io.Copy(h, io.LimitReader(seeker, size))
+		if err != nil {
+			return nil, "", 0, err
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, "", 0, err
+		}
+		return io.LimitReader(seeker, n), hex.EncodeToString(h.Sum(nil)), n, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(io.MultiWriter(&buf, h), r); err != nil {
+		return nil, "", 0, err
+	}
+	return &buf, hex.EncodeToString(h.Sum(nil)), int64(buf.Len()), nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBadSignature is returned for signed URLs that were altered or
+	// signed with another key.
+	ErrBadSignature = errors.New("storage: invalid URL signature")
+	// ErrURLExpired is returned for signed URLs past their expiry.
+	ErrURLExpired = errors.New("storage: signed URL has expired")
+	// ErrPubliclyReadable is returned by CheckPrivate for backends that
+	// serve files to anyone.
+	ErrPubliclyReadable = errors.New("storage: files are publicly readable")
+)
+
+// Signer makes and checks expiring URLs for files that are not served
+// publicly. A URL carries its expiry and the HMAC-SHA256 of the file's name
+// and that expiry, so it grants access to that one file until it expires.
+type Signer struct {
+	key []byte
+	// BaseURL is the URL signed files are served under, without a trailing
+	// slash.
+	BaseURL string
+}
+
+func NewSigner(key []byte, baseURL string) *Signer {
+	return &Signer{key: key, BaseURL: baseURL}
+}
+
+// URL is the unsigned URL of the named file, which is refused until signed.
+func (s *Signer) URL(name string) string {
+	return s.BaseURL + "/" + name
+}
+
+// Sign returns the URL of the named file, valid until expires.
+func (s *Signer) Sign(name string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return s.URL(name) + "?expires=" + exp + "&signature=" + hex.EncodeToString(s.mac(name, exp))
+}
+
+// Verify checks the expires and signature parameters of a URL of the named
+// file, returning when it expires.
+func (s *Signer) Verify(name, expires, signature string) (time.Time, error) {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.mac(name, expires)) {
+		return time.Time{}, ErrBadSignature
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrBadSignature
+	}
+	at := time.Unix(unix, 0)
+	if time.Now().After(at) {
+		return at, ErrURLExpired
+	}
+	return at, nil
+}
+
+func (s *Signer) mac(name, expires string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(name + "\n" + expires))
+	return mac.Sum(nil)
+}
+
+// CheckPrivate checks that files stored in dir are not served to anyone by
+// the backend itself. It stores a probe file in dir and fetches its public
+// URL without credentials, failing unless that is refused. Backends serving
+// files from a path, such as the local disk, are served by this server,
+// which keeps dir private, and are not checked.
+func CheckPrivate(ctx context.Context, s Storage, dir string, client *http.Client) error {
+	key := newName(dir) + ".txt"
+	if !strings.HasPrefix(s.URL(key), "http://") && !strings.HasPrefix(s.URL(key), "https://") {
+		return nil
+	}
+	if err := s.Put(ctx, key, strings.NewReader("private"), 7, "text/plain"); err != nil {
+		return err
+	}
+	defer s.Delete(context.WithoutCancel(ctx), key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode < 300:
+		return fmt.Errorf("%w: %s", ErrPubliclyReadable, s.URL(dir))
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return nil
+	default:
+		return fmt.Errorf("storage: unexpected status %s from %s", resp.Status, s.URL(dir))
+	}
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedParams splits a signed URL into its file name, expires and
+// signature.
+func signedParams(t *testing.T, signed string) (name, expires, signature string) {
+	t.Helper()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimPrefix(u.Path, "/private/"), u.Query().Get("expires"), u.Query().Get("signature")
+}
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner([]byte("key"), "/private")
+	other := NewSigner([]byte("other key"), "/private")
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+	past := time.Now().Add(-time.Second).Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		file   string
+		// tamper alters the name, expires and signature of the signed URL.
+		tamper  func(name, expires, signature string) (string, string, string)
+		expires time.Time
+		wantErr error
+	}{
+		{"valid", signer, "a.png", nil, future, nil},
+		{"expired", signer, "a.png", nil, past, ErrURLExpired},
+		{"other key", other, "a.png", nil, future, ErrBadSignature},
+		{"other file", signer, "a.png", func(n, e, s string) (string, string, string) {
+			return "b.png", e, s
+		}, future, ErrBadSignature},
+		{"extended expiry", signer, "a.png", func(n, e, s string) (string, string, string) {
+			unix, _ := strconv.ParseInt(e, 10, 64)
+			return n, strconv.FormatInt(unix+3600, 10), s
+		}, future, ErrBadSignature},
+		{"altered signature", signer, "a.png", func(n, e, s string) (string, string, string) {
+			return n, e, strings.Repeat("0", len(s))
+		}, future, ErrBadSignature},
+		{"truncated signature", signer, "a.png", func(n, e, s string) (string, string, string) {
+			return n, e, s[:len(s)-2]
+		}, future, ErrBadSignature},
+		{"signature not hex", signer, "a.png", func(n, e, s string) (string, string, string) {
+			return n, e, "zz" + s[2:]
+		}, future, ErrBadSignature},
+		{"missing signature", signer, "a.png", func(n, e, s string) (string, string, string) {
+			return n, e, ""
+		}, future, ErrBadSignature},
+		{"missing expires", signer, "a.png", func(n, e, s string) (string, string, string) {
+			return n, "", s
+		}, future, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := tt.signer.Sign(tt.file, tt.expires)
+			if !strings.HasPrefix(signed, signer.URL(tt.file)+"?") {
+				t.Fatalf("Sign = %q, want it to start with %q", signed, signer.URL(tt.file))
+			}
+			name, expires, signature := signedParams(t, signed)
+			if tt.tamper != nil {
+				name, expires, signature = tt.tamper(name, expires, signature)
+			}
+			until, err := signer.Verify(name, expires, signature)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !until.Equal(tt.expires) {
+				t.Errorf("Verify expiry = %v, want %v", until, tt.expires)
+			}
+		})
+	}
+}
+
+func TestSignerURL(t *testing.T) {
+	signer := NewSigner([]byte("key"), "https://cdn.example.com/private")
+	if got, want := signer.URL("a.png"), "https://cdn.example.com/private/a.png"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+	expires := time.Unix(1700000000, 0)
+	if a, b := signer.Sign("a.png", expires), signer.Sign("a.png", expires); a != b {
+		t.Errorf("signing twice gave %q and %q", a, b)
+	}
+	if a, b := signer.Sign("a.png", expires), signer.Sign("a.png", expires.Add(time.Second)); a == b {
+		t.Error("URLs with different expiries are alike")
+	}
+}
+
+func TestCheckPrivate(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		// wantErr is ErrPubliclyReadable, any other error to expect some
+		// error, or nil.
+		wantErr error
+	}{
+		{"served publicly", http.StatusOK, ErrPubliclyReadable},
+		{"refused", http.StatusForbidden, nil},
+		{"not found", http.StatusNotFound, nil},
+		{"server error", http.StatusInternalServerError, errors.New("any error")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = r.URL.Path
+				if r.Header.Get("Authorization") != "" {
+					t.Error("probe sent credentials")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			store, err := NewLocal(dir, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = CheckPrivate(context.Background(), store, "private/", server.Client())
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Errorf("CheckPrivate = %v, want nil", err)
+			case tt.wantErr != nil && err == nil:
+				t.Errorf("CheckPrivate = nil, want %v", tt.wantErr)
+			case errors.Is(tt.wantErr, ErrPubliclyReadable) && !errors.Is(err, ErrPubliclyReadable):
+				t.Errorf("CheckPrivate = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(requested, "/private/") {
+				t.Errorf("probe fetched %q, want a file under /private/", requested)
+			}
+
+			var left []string
+			store.List(context.Background(), "", func(obj Object) error {
+				left = append(left, obj.Key)
+				return nil
+			})
+			if len(left) > 0 {
+				t.Errorf("probe files left behind: %q", left)
+			}
+		})
+	}
+}
+
+func TestCheckPrivateServedHere(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("probe fetched a file served by this server")
+		return nil, errors.New("unexpected request")
+	})}
+	if err := CheckPrivate(context.Background(), store, "private/", client); err != nil {
+		t.Errorf("CheckPrivate = %v, want nil", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestNewNameIsRandomInDir(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		name := newName("private/")
+		if !strings.HasPrefix(name, "private/") || len(name) != len("private/")+32 {
+			t.Fatalf("newName = %q, want private/ and 32 hex digits", name)
+		}
+		if seen[name] {
+			t.Fatalf("newName repeated %q", name)
+		}
+		seen[name] = true
+	}
+	if name := newName(""); strings.Contains(name, "/") {
+		t.Errorf("newName(\"\") = %q, want a name in the root", name)
+	}
+}
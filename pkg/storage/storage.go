@@ -1,68 +1,204 @@
+// Package storage keeps uploaded files on a pluggable backend: the local
+// disk or an S3-compatible object store.
 package storage
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"os"
-	"path/filepath"
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
-type Storage struct {
-	UploadDir string
+// ErrNotFound is returned for keys that are not stored.
+var ErrNotFound = errors.New("storage: file not found")
+
+// Storage is a backend files are kept in. Keys are slash-separated relative
+// paths such as "1700000000000000000.jpg".
+type Storage interface {
+	// Put stores the contents of r under key, replacing any file already
+	// there. size is the length of r, or -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the contents of a stored file.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat describes a stored file.
+	Stat(ctx context.Context, key string) (*Object, error)
+	// Delete removes a stored file. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every stored file whose key starts with prefix.
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	// URL is the public URL a stored file is served from. URL("") is the
+	// prefix every stored file's URL starts with.
+	URL(key string) string
+}
+
+// Object describes a stored file.
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
 }
 
-func NewStorage(uploadDir string) *Storage {
-	// Create upload directory if it doesn't exist
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+// Save stores the contents of r under a new key with the given extension,
+// returning the key. size is the length of r, or -1 when unknown.
+func Save(ctx context.Context, s Storage, r io.Reader, size int64, ext string) (string, error) {
+	ext = strings.ToLower(ext)
+	key := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	if err := s.Put(ctx, key, r, size, contentType(key)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// newName returns the base name, without an extension, of a new key in dir.
+// Keys in the root are named after the time. Keys in a dir, which may be
+// kept apart from the public, such as private media, are random so that
+// they cannot be guessed.
+func newName(dir string) string {
+	if dir == "" {
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
 		panic(err)
 	}
-	return &Storage{UploadDir: uploadDir}
+	return dir + hex.EncodeToString(b)
 }
 
-func (s *Storage) SaveImage(file *multipart.FileHeader) (string, error) {
-	// Open the uploaded file
-	src, err := file.Open()
+// KeyOf returns the key of the stored file a URL points to. URLs made
+// absolute against the site, such as those in feeds, are recognised as well
+// when the backend serves files from a path.
+func KeyOf(s Storage, url string) (string, bool) {
+	prefix := s.URL("")
+	if strings.HasPrefix(prefix, "/") {
+		if i := strings.Index(url, "://"); i >= 0 {
+			if j := strings.Index(url[i+3:], "/"); j >= 0 {
+				url = url[i+3+j:]
+			}
+		}
+	}
+	key, ok := strings.CutPrefix(url, prefix)
+	if !ok || key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+// References returns a pattern matching links to stored files, relative or
+// absolute, in text such as post content. The key is what follows URL("").
+func References(s Storage) *regexp.Regexp {
+	return regexp.MustCompile(regexp.QuoteMeta(s.URL("")) + `[A-Za-z0-9._\-/]+`)
+}
+
+// Move moves a stored file to another key.
+func Move(ctx context.Context, s Storage, from, to string) error {
+	obj, err := s.Stat(ctx, from)
 	if err != nil {
-		return "", err
+		return err
+	}
+	r, err := s.Open(ctx, from)
+	if err != nil {
+		return err
 	}
-	defer src.Close()
+	defer r.Close()
+	if err := s.Put(ctx, to, r, obj.Size, contentType(to)); err != nil {
+		return err
+	}
+	return s.Delete(ctx, from)
+}
 
-	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	if !isAllowedImageType(ext) {
-		return "", fmt.Errorf("unsupported file type: %s", ext)
+// Stem is the part of a key shared by a file and its variants: the key
+// without its extension or a -<width>w or -thumb suffix.
+func Stem(key string) string {
+	stem := strings.TrimSuffix(key, path.Ext(key))
+	if i := strings.LastIndex(stem, "-"); i >= 0 && i > strings.LastIndex(stem, "/") {
+		suffix := stem[i+1:]
+		if suffix == "thumb" || len(suffix) > 1 && strings.HasSuffix(suffix, "w") && digits(suffix[:len(suffix)-1]) {
+			stem = stem[:i]
+		}
 	}
+	return stem
+}
 
-	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-	filepath := filepath.Join(s.UploadDir, filename)
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
 
-	// Create destination file
-	dst, err := os.Create(filepath)
-	if err != nil {
-		return "", err
+// cleanKey validates a key, rejecting ones that would escape the storage root.
+func cleanKey(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if key == "" || clean != key || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("storage: invalid key %q", key)
 	}
-	defer dst.Close()
+	return clean, nil
+}
 
-	// Copy uploaded file to destination
-	if _, err = io.Copy(dst, src); err != nil {
-		return "", err
+func contentType(key string) string {
+	if kind, ok := fileTypes[strings.ToLower(path.Ext(key))]; ok {
+		return kind.contentType
 	}
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
 
-	// Return the relative path
-	return filename, nil
+// Concat reads the stored files under keys one after another, as a single
+// stream. Each file is opened only once the previous one is exhausted.
+func Concat(ctx context.Context, s Storage, keys []string) io.ReadCloser {
+	return &concatReader{ctx: ctx, s: s, keys: keys}
 }
 
-func isAllowedImageType(ext string) bool {
-	ext = strings.ToLower(ext)
-	allowedTypes := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
+type concatReader struct {
+	ctx  context.Context
+	s    Storage
+	keys []string
+	cur  io.ReadCloser
+}
+
+func (r *concatReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			f, err := r.s.Open(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", r.keys[0], err)
+			}
+			r.cur, r.keys = f, r.keys[1:]
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *concatReader) Close() error {
+	if r.cur == nil {
+		return nil
 	}
-	return allowedTypes[ext]
+	err := r.cur.Close()
+	r.cur = nil
+	return err
 }